@@ -2,31 +2,87 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/Zisimopoulou/platform-go-challenge/internal/api"
+	"github.com/Zisimopoulou/platform-go-challenge/internal/auth"
 	"github.com/Zisimopoulou/platform-go-challenge/internal/core"
 	"github.com/Zisimopoulou/platform-go-challenge/internal/data"
+	"github.com/Zisimopoulou/platform-go-challenge/internal/models"
+	"github.com/Zisimopoulou/platform-go-challenge/internal/schema"
 )
 
 func main() {
-	if os.Getenv("JWT_SECRET") == "" && os.Getenv("APP_JWT_SECRET") == "" {
-		log.Fatal("JWT_SECRET or APP_JWT_SECRET environment variable must be set")
+	keys, err := loadKeySet()
+	if err != nil {
+		log.Fatalf("load signing keys: %v", err)
 	}
 
-	store := data.NewInMemoryStore()
-	svc := core.NewService(store)
-	h := api.NewHandler(svc)
+	store, apiKeyStore, closeStore, err := openStore()
+	if err != nil {
+		log.Fatalf("open store: %v", err)
+	}
+	defer closeStore()
+
+	var opts []core.Option
+	if secret := os.Getenv("CURSOR_SECRET"); secret != "" {
+		opts = append(opts, core.WithCursorSecret([]byte(secret)))
+	}
+	opts = append(opts, core.WithIdempotencyWindow(envDuration("IDEMPOTENCY_WINDOW", core.DefaultIdempotencyWindow)))
+	svc := core.NewService(store, opts...)
+	apiKeys := auth.NewAPIKeys(apiKeyStore)
+	apiOpts := []api.Option{api.WithKeySet(keys), api.WithAPIKeys(apiKeys)}
+	if issuer, err := loadIssuer(); err != nil {
+		log.Fatalf("load external issuer: %v", err)
+	} else if issuer != nil {
+		log.Printf("accepting tokens from %s", issuer.Issuer())
+		apiOpts = append(apiOpts, api.WithExternalIssuer(issuer))
+	}
+	models.SetStrictPayloads(envBool("STRICT_PAYLOADS", false))
+	if dir := os.Getenv("ASSET_TYPES_DIR"); dir != "" {
+		loader := schema.NewLoader(dir)
+		types, err := loader.Load()
+		if err != nil {
+			log.Fatalf("load asset types: %v", err)
+		}
+		log.Printf("loaded %d asset types from %s", len(types), dir)
+		apiOpts = append(apiOpts, api.WithSchemaLoader(loader))
+		go reloadOnHangup(loader)
+	}
+	h := api.NewHandler(svc, apiOpts...)
+	users := auth.NewService(auth.NewInMemoryCredentialStore(),
+		auth.WithLockout(envInt("LOGIN_MAX_ATTEMPTS", auth.DefaultMaxFailedAttempts), envDuration("LOGIN_LOCKOUT", auth.DefaultLockoutDuration)))
+	if err := bootstrapAdmin(users); err != nil {
+		log.Fatalf("create admin: %v", err)
+	}
+	authHandler := api.NewAuthHandler(
+		users,
+		auth.NewSessions(auth.NewInMemoryRefreshStore(), envDuration("REFRESH_TTL", auth.DefaultRefreshTTL)),
+		api.WithKeySet(keys),
+	)
 
 	mux := http.NewServeMux()
 	mux.Handle("/users/", http.StripPrefix("/users", h))
+	mux.Handle("/admin/", http.StripPrefix("/admin", api.NewAdminHandler(svc, users, apiOpts...)))
+	mux.Handle("/asset-types", api.NewAssetTypesHandler(apiOpts...))
 	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK); w.Write([]byte("ok")) })
-	mux.HandleFunc("/auth/login", api.LoginHandler)
-	mux.HandleFunc("/auth/refresh", api.RefreshHandler)
+	mux.HandleFunc("/auth/register", authHandler.Register)
+	mux.HandleFunc("/auth/login", authHandler.Login)
+	mux.HandleFunc("/auth/refresh", authHandler.Refresh)
+	mux.HandleFunc("/auth/logout", authHandler.Logout)
+	apiKeyHandler := http.StripPrefix("/auth/api-keys", api.NewAPIKeyHandler(apiKeys, apiOpts...))
+	mux.Handle("/auth/api-keys", apiKeyHandler)
+	mux.Handle("/auth/api-keys/", apiKeyHandler)
+	mux.HandleFunc("/.well-known/jwks.json", api.JWKSHandler(keys))
 
 	addr := ":8080"
 	srv := &http.Server{
@@ -41,10 +97,16 @@ func main() {
 		}
 	}()
 
+	purgeCtx, stopPurger := context.WithCancel(context.Background())
+	go svc.RunTrashPurger(purgeCtx,
+		envDuration("TRASH_RETENTION", core.DefaultTrashRetention),
+		envDuration("TRASH_PURGE_INTERVAL", core.DefaultTrashPurgeInterval))
+
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, os.Interrupt)
 	<-quit
 	log.Println("shutting down server...")
+	stopPurger()
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
@@ -53,3 +115,169 @@ func main() {
 	}
 	log.Println("server stopped")
 }
+
+// loadKeySet signs with JWT_SIGNING_KEY (an RSA or Ed25519 PEM file) when
+// set, and with the shared JWT_SECRET otherwise. JWT_VERIFY_KEYS lists extra
+// verification-only keys as comma separated paths, each optionally prefixed
+// with "kid=", for tokens signed by keys that were rotated out.
+func loadKeySet() (*auth.KeySet, error) {
+	var shared *auth.SigningKey
+	secret := os.Getenv("JWT_SECRET")
+	if secret == "" {
+		secret = os.Getenv("APP_JWT_SECRET")
+	}
+	if secret != "" {
+		shared = auth.NewHMACKey("", []byte(secret))
+	}
+
+	path := os.Getenv("JWT_SIGNING_KEY")
+	if path == "" {
+		if shared == nil {
+			return nil, fmt.Errorf("JWT_SIGNING_KEY, JWT_SECRET or APP_JWT_SECRET must be set")
+		}
+		return auth.NewKeySet(shared)
+	}
+	signing, err := auth.LoadKeyFile(path, os.Getenv("JWT_SIGNING_KID"))
+	if err != nil {
+		return nil, err
+	}
+
+	var verify []*auth.SigningKey
+	for _, entry := range strings.Split(os.Getenv("JWT_VERIFY_KEYS"), ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		kid, file, found := strings.Cut(entry, "=")
+		if !found {
+			kid, file = "", entry
+		}
+		k, err := auth.LoadKeyFile(file, kid)
+		if err != nil {
+			return nil, err
+		}
+		verify = append(verify, k)
+	}
+	// Keep accepting tokens signed with the shared secret while it is still
+	// configured, so moving to asymmetric keys does not log everyone out.
+	if shared != nil {
+		verify = append(verify, shared)
+	}
+	log.Printf("signing tokens with %s key %s", signing.Method.Alg(), signing.ID)
+	return auth.NewKeySet(signing, verify...)
+}
+
+// bootstrapAdmin registers ADMIN_USERNAME with ADMIN_PASSWORD and the admin
+// role, so there is someone to hand out roles to everybody else.
+func bootstrapAdmin(users *auth.Service) error {
+	username := os.Getenv("ADMIN_USERNAME")
+	if username == "" {
+		return nil
+	}
+	if _, err := users.Register(username, os.Getenv("ADMIN_PASSWORD")); err != nil {
+		return err
+	}
+	_, err := users.SetRoles(username, []string{string(core.RoleAdmin)})
+	return err
+}
+
+// loadIssuer configures an external OIDC issuer when OIDC_JWKS_FILE or
+// OIDC_DISCOVERY_URL is set.
+func loadIssuer() (*auth.Verifier, error) {
+	cfg := auth.IssuerConfig{
+		Issuer:       os.Getenv("OIDC_ISSUER"),
+		Audience:     os.Getenv("OIDC_AUDIENCE"),
+		JWKSFile:     os.Getenv("OIDC_JWKS_FILE"),
+		DiscoveryURL: os.Getenv("OIDC_DISCOVERY_URL"),
+		UserClaim:    os.Getenv("OIDC_USER_CLAIM"),
+		Leeway:       envDuration("OIDC_LEEWAY", 30*time.Second),
+	}
+	if cfg.JWKSFile == "" && cfg.DiscoveryURL == "" {
+		return nil, nil
+	}
+	return auth.NewVerifier(cfg)
+}
+
+// reloadOnHangup reloads the schema-defined asset types on SIGHUP. A broken
+// schema is logged and the previous types stay in place.
+func reloadOnHangup(loader *schema.Loader) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	for range hup {
+		types, err := loader.Load()
+		if err != nil {
+			log.Printf("reload asset types: %v", err)
+			continue
+		}
+		log.Printf("reloaded %d asset types", len(types))
+	}
+}
+
+// openStore also returns where API keys are kept: with the store's data, so
+// they last exactly as long as the favorites do.
+func openStore() (data.Store, auth.APIKeyStore, func() error, error) {
+	switch driver := os.Getenv("STORE_DRIVER"); driver {
+	case "", "memory":
+		dir := os.Getenv("JOURNAL_DIR")
+		if dir == "" {
+			return data.NewInMemoryStore(), auth.NewInMemoryAPIKeyStore(), func() error { return nil }, nil
+		}
+		store, err := data.NewJournaledInMemoryStore(data.JournalConfig{
+			Dir:              dir,
+			SnapshotEvery:    envInt("SNAPSHOT_EVERY", 1000),
+			SnapshotInterval: envDuration("SNAPSHOT_INTERVAL", 5*time.Minute),
+		})
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		keys, err := auth.NewFileAPIKeyStore(filepath.Join(dir, "api_keys.json"))
+		if err != nil {
+			store.Close()
+			return nil, nil, nil, err
+		}
+		return store, keys, store.Close, nil
+	case "sqlite":
+		path := os.Getenv("SQLITE_PATH")
+		if path == "" {
+			path = "favorites.db"
+		}
+		store, err := data.NewSQLiteStore(path)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		log.Printf("using sqlite store at %s", path)
+		return store, auth.NewSQLiteAPIKeyStore(store.DB()), store.Close, nil
+	default:
+		return nil, nil, nil, fmt.Errorf("unknown STORE_DRIVER %q", driver)
+	}
+}
+
+func envInt(key string, def int) int {
+	if v := os.Getenv(key); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			return n
+		}
+		log.Printf("ignoring invalid %s=%q", key, v)
+	}
+	return def
+}
+
+func envBool(key string, def bool) bool {
+	if v := os.Getenv(key); v != "" {
+		if b, err := strconv.ParseBool(v); err == nil {
+			return b
+		}
+		log.Printf("ignoring invalid %s=%q", key, v)
+	}
+	return def
+}
+
+func envDuration(key string, def time.Duration) time.Duration {
+	if v := os.Getenv(key); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			return d
+		}
+		log.Printf("ignoring invalid %s=%q", key, v)
+	}
+	return def
+}
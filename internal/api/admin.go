@@ -0,0 +1,122 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/Zisimopoulou/platform-go-challenge/internal/auth"
+	"github.com/Zisimopoulou/platform-go-challenge/internal/core"
+	"github.com/Zisimopoulou/platform-go-challenge/internal/models"
+	"github.com/Zisimopoulou/platform-go-challenge/internal/schema"
+)
+
+type RolesRequest struct {
+	Roles []core.Role `json:"roles"`
+}
+
+type RolesResponse struct {
+	UserID string      `json:"userId"`
+	Roles  []core.Role `json:"roles"`
+}
+
+type AdminHandler struct {
+	svc     *core.Service
+	users   *auth.Service
+	schemas *schema.Loader
+}
+
+// NewAdminHandler serves the user management endpoints, meant to be mounted
+// under /admin. Every request needs core.ActionManage, so only admins get in.
+func NewAdminHandler(svc *core.Service, users *auth.Service, opts ...Option) http.Handler {
+	h := &AdminHandler{svc: svc, users: users, schemas: newOptions(opts).schemas}
+	return AuthMiddleware(http.HandlerFunc(h.handle), opts...)
+}
+
+func (h *AdminHandler) handle(w http.ResponseWriter, r *http.Request) {
+	principal, ok := FromContextPrincipal(r.Context())
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+
+	var owner string
+	if len(parts) == 3 {
+		owner = parts[1]
+	}
+	if err := h.svc.Authorize(principal, owner, core.ActionManage, r.RequestURI); err != nil {
+		writeAuthzError(w, err)
+		return
+	}
+
+	switch {
+	case len(parts) == 1 && parts[0] == "users" && r.Method == http.MethodGet:
+		users, err := h.svc.ListUsers()
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"users": users})
+
+	case len(parts) == 3 && parts[0] == "users" && parts[2] == "roles" && r.Method == http.MethodGet:
+		u, err := h.users.User(owner)
+		if err != nil {
+			writeUserError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, rolesResponse(u))
+
+	case len(parts) == 3 && parts[0] == "users" && parts[2] == "roles" && r.Method == http.MethodPut:
+		h.handleSetRoles(w, r, owner)
+
+	case len(parts) == 2 && parts[0] == "asset-types" && parts[1] == "reload" && r.Method == http.MethodPost && h.schemas != nil:
+		if _, err := h.schemas.Load(); err != nil {
+			writeError(w, http.StatusUnprocessableEntity, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"assetTypes": models.AssetTypes()})
+
+	default:
+		writeError(w, http.StatusNotFound, "not found")
+	}
+}
+
+func (h *AdminHandler) handleSetRoles(w http.ResponseWriter, r *http.Request, username string) {
+	var req RolesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+	roles := make([]string, 0, len(req.Roles))
+	for _, role := range req.Roles {
+		if !role.Valid() {
+			writeError(w, http.StatusBadRequest, "unknown role "+string(role))
+			return
+		}
+		roles = append(roles, string(role))
+	}
+	u, err := h.users.SetRoles(username, roles)
+	if err != nil {
+		writeUserError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, rolesResponse(u))
+}
+
+func rolesResponse(u auth.User) RolesResponse {
+	res := RolesResponse{UserID: u.Username, Roles: []core.Role{}}
+	for _, role := range u.Roles {
+		res.Roles = append(res.Roles, core.Role(role))
+	}
+	return res
+}
+
+func writeUserError(w http.ResponseWriter, err error) {
+	if errors.Is(err, auth.ErrUserNotFound) {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	writeError(w, http.StatusInternalServerError, err.Error())
+}
@@ -0,0 +1,155 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Zisimopoulou/platform-go-challenge/internal/auth"
+	"github.com/Zisimopoulou/platform-go-challenge/internal/core"
+)
+
+type CreateAPIKeyRequest struct {
+	Name   string       `json:"name"`
+	Scopes []core.Scope `json:"scopes"`
+}
+
+// APIKeyResponse describes a key. Key holds the secret and is only set in
+// the response to its creation.
+type APIKeyResponse struct {
+	ID         string       `json:"id"`
+	Name       string       `json:"name"`
+	Key        string       `json:"key,omitempty"`
+	Scopes     []core.Scope `json:"scopes"`
+	CreatedAt  time.Time    `json:"createdAt"`
+	LastUsedAt *time.Time   `json:"lastUsedAt,omitempty"`
+}
+
+func apiKeyResponse(k auth.APIKey) APIKeyResponse {
+	res := APIKeyResponse{ID: k.ID, Name: k.Name, Scopes: scopesOf(k.Scopes), CreatedAt: k.CreatedAt}
+	if !k.LastUsedAt.IsZero() {
+		res.LastUsedAt = &k.LastUsedAt
+	}
+	return res
+}
+
+// scopesOf never returns nil: a key without valid scopes may do nothing,
+// not everything.
+func scopesOf(stored []string) []core.Scope {
+	scopes := make([]core.Scope, 0, len(stored))
+	for _, s := range stored {
+		if core.Scope(s).Valid() {
+			scopes = append(scopes, core.Scope(s))
+		}
+	}
+	return scopes
+}
+
+func (o options) authenticateAPIKey(key string) (core.Principal, error) {
+	if o.apiKeys == nil {
+		return core.Principal{}, errors.New("API keys are not accepted here")
+	}
+	k, err := o.apiKeys.Authenticate(key)
+	if err != nil {
+		return core.Principal{}, auth.ErrAPIKeyInvalid
+	}
+	return core.Principal{UserID: k.UserID, Roles: []core.Role{core.RoleUser}, Scopes: scopesOf(k.Scopes)}, nil
+}
+
+type APIKeyHandler struct {
+	keys *auth.APIKeys
+}
+
+// NewAPIKeyHandler lets signed-in users manage their own API keys. It is
+// meant to be mounted under /auth/api-keys and only takes bearer tokens, so
+// a leaked key cannot be used to mint more.
+func NewAPIKeyHandler(keys *auth.APIKeys, opts ...Option) http.Handler {
+	h := &APIKeyHandler{keys: keys}
+	return AuthMiddleware(http.HandlerFunc(h.handle), opts...)
+}
+
+func (h *APIKeyHandler) handle(w http.ResponseWriter, r *http.Request) {
+	principal, ok := FromContextPrincipal(r.Context())
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	if principal.Scopes != nil {
+		writeError(w, http.StatusForbidden, "API keys cannot manage API keys")
+		return
+	}
+
+	id := strings.Trim(r.URL.Path, "/")
+	switch {
+	case id == "" && r.Method == http.MethodGet:
+		keys, err := h.keys.List(principal.UserID)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		res := make([]APIKeyResponse, 0, len(keys))
+		for _, k := range keys {
+			res = append(res, apiKeyResponse(k))
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"keys": res})
+
+	case id == "" && r.Method == http.MethodPost:
+		h.handleCreate(w, r, principal.UserID)
+
+	case id != "" && !strings.Contains(id, "/") && r.Method == http.MethodDelete:
+		err := h.keys.Revoke(principal.UserID, id)
+		switch {
+		case err == nil:
+			w.WriteHeader(http.StatusNoContent)
+		case errors.Is(err, auth.ErrAPIKeyNotFound):
+			writeError(w, http.StatusNotFound, err.Error())
+		default:
+			writeError(w, http.StatusInternalServerError, err.Error())
+		}
+
+	default:
+		writeError(w, http.StatusNotFound, "not found")
+	}
+}
+
+func (h *APIKeyHandler) handleCreate(w http.ResponseWriter, r *http.Request, userID string) {
+	var req CreateAPIKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+	if len(req.Scopes) == 0 {
+		writeError(w, http.StatusBadRequest, "at least one scope is required")
+		return
+	}
+	scopes := make([]string, 0, len(req.Scopes))
+	for _, s := range req.Scopes {
+		if !s.Valid() {
+			writeError(w, http.StatusBadRequest, "unknown scope "+string(s))
+			return
+		}
+		if !slices.Contains(scopes, string(s)) {
+			scopes = append(scopes, string(s))
+		}
+	}
+
+	k, secret, err := h.keys.Create(userID, req.Name, scopes)
+	switch {
+	case err == nil:
+	case errors.Is(err, auth.ErrInvalidKeyName):
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	case errors.Is(err, auth.ErrTooManyAPIKeys):
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	default:
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	res := apiKeyResponse(k)
+	res.Key = secret
+	writeJSON(w, http.StatusCreated, res)
+}
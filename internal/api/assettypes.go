@@ -0,0 +1,21 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/Zisimopoulou/platform-go-challenge/internal/models"
+)
+
+// NewAssetTypesHandler lists the registered asset types, so clients can
+// offer every kind of favorite the server accepts.
+func NewAssetTypesHandler(opts ...Option) http.Handler {
+	return AuthMiddleware(http.HandlerFunc(handleAssetTypes), opts...)
+}
+
+func handleAssetTypes(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"assetTypes": models.AssetTypes()})
+}
@@ -6,14 +6,22 @@ import (
 	"errors"
 	"net/http"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/Zisimopoulou/platform-go-challenge/internal/auth"
+	"github.com/Zisimopoulou/platform-go-challenge/internal/core"
 	"github.com/golang-jwt/jwt/v5"
 )
 
 type ctxKeyUserID struct{}
 
+type ctxKeyPrincipal struct{}
+
+// APIKeyHeader carries an API key as an alternative to a bearer token.
+const APIKeyHeader = "X-API-Key"
+
 type TokenResponse struct {
 	AccessToken  string `json:"accessToken"`
 	RefreshToken string `json:"refreshToken"`
@@ -24,21 +32,19 @@ type RefreshRequest struct {
 	RefreshToken string `json:"refreshToken"`
 }
 
-func GenerateTokens(userID string) (string, string, int64, error) {
-	secret := getJWTSecret()
+// GenerateTokens signs an access token for userID carrying its roles and a
+// refresh token that carries the server-side record's ID as its jti.
+func GenerateTokens(ks *auth.KeySet, userID string, roles []string, refresh auth.RefreshToken) (string, string, int64, error) {
 	expiresAt := time.Now().Add(time.Hour * 1).Unix()
-	accessClaims := jwt.MapClaims{"sub": userID, "exp": expiresAt, "type": "access"}
-	accessToken := jwt.NewWithClaims(jwt.SigningMethodHS256, accessClaims)
-	accessString, err := accessToken.SignedString(secret)
+	accessClaims := jwt.MapClaims{"sub": userID, "exp": expiresAt, "type": "access", "roles": roles}
+	accessString, err := ks.Sign(accessClaims)
 
 	if err != nil {
 		return "", "", 0, err
 	}
 
-	refreshExpiresAt := time.Now().Add(time.Hour * 24 * 7).Unix()
-	refreshClaims := jwt.MapClaims{"sub": userID, "exp": refreshExpiresAt, "type": "refresh"}
-	refreshToken := jwt.NewWithClaims(jwt.SigningMethodHS256, refreshClaims)
-	refreshString, err := refreshToken.SignedString(secret)
+	refreshClaims := jwt.MapClaims{"sub": userID, "exp": refresh.ExpiresAt.Unix(), "type": "refresh", "jti": refresh.ID}
+	refreshString, err := ks.Sign(refreshClaims)
 
 	if err != nil {
 		return "", "", 0, err
@@ -67,8 +73,46 @@ func FromContextUserID(ctx context.Context) (string, bool) {
 	return s, ok
 }
 
-func AuthMiddleware(next http.Handler) http.Handler {
+// FromContextPrincipal returns the authenticated user with its roles and,
+// for API keys, scopes.
+func FromContextPrincipal(ctx context.Context) (core.Principal, bool) {
+	p, ok := ctx.Value(ctxKeyPrincipal{}).(core.Principal)
+	return p, ok
+}
+
+func withPrincipal(r *http.Request, p core.Principal) *http.Request {
+	ctx := context.WithValue(r.Context(), ctxKeyUserID{}, p.UserID)
+	return r.WithContext(context.WithValue(ctx, ctxKeyPrincipal{}, p))
+}
+
+// rolesClaim reads the roles of a local access token. Tokens from before
+// roles existed, and external ones, get the plain user role.
+func rolesClaim(claims jwt.MapClaims) []core.Role {
+	raw, _ := claims["roles"].([]interface{})
+	var roles []core.Role
+	for _, v := range raw {
+		if s, ok := v.(string); ok && core.Role(s).Valid() {
+			roles = append(roles, core.Role(s))
+		}
+	}
+	if len(roles) == 0 {
+		return []core.Role{core.RoleUser}
+	}
+	return roles
+}
+
+func AuthMiddleware(next http.Handler, opts ...Option) http.Handler {
+	o := newOptions(opts)
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if key := r.Header.Get(APIKeyHeader); key != "" {
+			p, err := o.authenticateAPIKey(key)
+			if err != nil {
+				writeError(w, http.StatusUnauthorized, err.Error())
+				return
+			}
+			next.ServeHTTP(w, withPrincipal(r, p))
+			return
+		}
 		auth := r.Header.Get("Authorization")
 		if auth == "" {
 			writeError(w, http.StatusUnauthorized, "missing authorization header")
@@ -80,20 +124,18 @@ func AuthMiddleware(next http.Handler) http.Handler {
 			return
 		}
 		tokenStr := parts[1]
-		secret := getJWTSecret()
-		token, err := jwt.Parse(tokenStr, func(t *jwt.Token) (interface{}, error) {
-			if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, errors.New("unexpected signing method")
+		if o.issuer != nil && o.issuer.Issues(tokenStr) {
+			userID, err := o.issuer.Verify(tokenStr)
+			if err != nil {
+				writeError(w, http.StatusUnauthorized, "invalid token")
+				return
 			}
-			return secret, nil
-		})
-		if err != nil || !token.Valid {
-			writeError(w, http.StatusUnauthorized, "invalid token")
+			next.ServeHTTP(w, withPrincipal(r, core.Principal{UserID: userID, Roles: []core.Role{core.RoleUser}}))
 			return
 		}
-		claims, ok := token.Claims.(jwt.MapClaims)
-		if !ok {
-			writeError(w, http.StatusUnauthorized, "invalid token claims")
+		claims, err := o.keySet().Parse(tokenStr)
+		if err != nil {
+			writeError(w, http.StatusUnauthorized, "invalid token")
 			return
 		}
 		if claims["type"] != "access" {
@@ -105,67 +147,161 @@ func AuthMiddleware(next http.Handler) http.Handler {
 			writeError(w, http.StatusUnauthorized, "invalid token subject")
 			return
 		}
-		r = r.WithContext(context.WithValue(r.Context(), ctxKeyUserID{}, sub))
-		next.ServeHTTP(w, r)
+		next.ServeHTTP(w, withPrincipal(r, core.Principal{UserID: sub, Roles: rolesClaim(claims)}))
 	})
 }
 
-func LoginHandler(w http.ResponseWriter, r *http.Request) {
+type AuthHandler struct {
+	users    *auth.Service
+	sessions *auth.Sessions
+	opts     options
+}
+
+func NewAuthHandler(users *auth.Service, sessions *auth.Sessions, opts ...Option) *AuthHandler {
+	return &AuthHandler{users: users, sessions: sessions, opts: newOptions(opts)}
+}
+
+type Credentials struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
 		return
 	}
-	var body struct {
-		UserID string `json:"userId"`
-	}
-	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.UserID == "" {
+	var body Credentials
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
 		writeError(w, http.StatusBadRequest, "invalid request body")
 		return
 	}
-	accessToken, refreshToken, expiresAt, err := GenerateTokens(body.UserID)
-	if err != nil {
+	u, err := h.users.Register(body.Username, body.Password)
+	switch {
+	case err == nil:
+		writeJSON(w, http.StatusCreated, map[string]string{"userId": u.Username})
+	case errors.Is(err, auth.ErrUsernameTaken):
+		writeError(w, http.StatusConflict, err.Error())
+	case errors.Is(err, auth.ErrInvalidUsername), errors.Is(err, auth.ErrInvalidPassword):
+		writeError(w, http.StatusBadRequest, err.Error())
+	default:
 		writeError(w, http.StatusInternalServerError, err.Error())
-		return
 	}
-	writeJSON(w, http.StatusOK, TokenResponse{
-		AccessToken:  accessToken,
-		RefreshToken: refreshToken,
-		ExpiresIn:    expiresAt,
-	})
 }
 
-func RefreshHandler(w http.ResponseWriter, r *http.Request) {
+func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
 		return
 	}
-	var body RefreshRequest
-	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.RefreshToken == "" {
+	var body Credentials
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Username == "" {
 		writeError(w, http.StatusBadRequest, "invalid request body")
 		return
 	}
-	secret := getJWTSecret()
-	token, err := jwt.Parse(body.RefreshToken, func(t *jwt.Token) (interface{}, error) {
-		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, errors.New("unexpected signing method")
-		}
-		return secret, nil
-	})
-	if err != nil || !token.Valid {
-		writeError(w, http.StatusUnauthorized, "invalid refresh token")
+	u, err := h.users.Login(body.Username, body.Password)
+	var locked *auth.LockedError
+	switch {
+	case err == nil:
+	case errors.As(err, &locked):
+		w.Header().Set("Retry-After", strconv.Itoa(int(time.Until(locked.Until).Seconds())+1))
+		writeError(w, http.StatusLocked, err.Error())
+		return
+	case errors.Is(err, auth.ErrInvalidCredentials):
+		writeError(w, http.StatusUnauthorized, err.Error())
+		return
+	default:
+		writeError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
-	claims, ok := token.Claims.(jwt.MapClaims)
-	if !ok || claims["type"] != "refresh" {
+
+	refresh, err := h.sessions.Start(u.Username)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	h.writeTokens(w, u.Username, refresh)
+}
+
+func (h *AuthHandler) Refresh(w http.ResponseWriter, r *http.Request) {
+	userID, tokenID, ok := h.readRefreshToken(w, r)
+	if !ok {
+		return
+	}
+	refresh, err := h.sessions.Rotate(tokenID, userID)
+	switch {
+	case err == nil:
+	case errors.Is(err, auth.ErrRefreshTokenInvalid), errors.Is(err, auth.ErrRefreshTokenReused):
 		writeError(w, http.StatusUnauthorized, "invalid refresh token")
 		return
+	default:
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	h.writeTokens(w, userID, refresh)
+}
+
+// Logout revokes the session of the posted refresh token. Access tokens
+// already issued stay valid until they expire.
+func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	_, tokenID, ok := h.readRefreshToken(w, r)
+	if !ok {
+		return
+	}
+	err := h.sessions.Revoke(tokenID)
+	switch {
+	case err == nil:
+		w.WriteHeader(http.StatusNoContent)
+	case errors.Is(err, auth.ErrRefreshTokenInvalid):
+		writeError(w, http.StatusUnauthorized, "invalid refresh token")
+	default:
+		writeError(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+// readRefreshToken verifies the refresh JWT in the request body and returns
+// its subject and jti, writing the error response itself when it fails.
+func (h *AuthHandler) readRefreshToken(w http.ResponseWriter, r *http.Request) (string, string, bool) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return "", "", false
+	}
+	var body RefreshRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.RefreshToken == "" {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return "", "", false
+	}
+	claims, err := h.opts.keySet().Parse(body.RefreshToken)
+	if err != nil || claims["type"] != "refresh" {
+		writeError(w, http.StatusUnauthorized, "invalid refresh token")
+		return "", "", false
 	}
 	userID, ok := claims["sub"].(string)
 	if !ok || userID == "" {
+		writeError(w, http.StatusUnauthorized, "invalid token subject")
+		return "", "", false
+	}
+	tokenID, ok := claims["jti"].(string)
+	if !ok || tokenID == "" {
+		writeError(w, http.StatusUnauthorized, "invalid refresh token")
+		return "", "", false
+	}
+	return userID, tokenID, true
+}
+
+// writeTokens looks the user up again so role changes reach the next access
+// token.
+func (h *AuthHandler) writeTokens(w http.ResponseWriter, userID string, refresh auth.RefreshToken) {
+	u, err := h.users.User(userID)
+	if err != nil {
 		writeError(w, http.StatusUnauthorized, "invalid token subject")
 		return
 	}
-	accessToken, refreshToken, expiresAt, err := GenerateTokens(userID)
+	roles := u.Roles
+	if len(roles) == 0 {
+		roles = []string{string(core.RoleUser)}
+	}
+	accessToken, refreshToken, expiresAt, err := GenerateTokens(h.opts.keySet(), userID, roles, refresh)
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, err.Error())
 		return
@@ -175,4 +311,4 @@ func RefreshHandler(w http.ResponseWriter, r *http.Request) {
 		RefreshToken: refreshToken,
 		ExpiresIn:    expiresAt,
 	})
-}
\ No newline at end of file
+}
@@ -0,0 +1,130 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/Zisimopoulou/platform-go-challenge/internal/core"
+	"github.com/Zisimopoulou/platform-go-challenge/internal/data"
+)
+
+type CollectionRequest struct {
+	Name string `json:"name"`
+}
+
+type CollectionOrderRequest struct {
+	IDs []string `json:"ids"`
+}
+
+func (h *Handler) handleListCollections(w http.ResponseWriter, r *http.Request, userID string) {
+	list, err := h.svc.ListCollections(userID)
+	if err != nil {
+		writeCollectionError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"collections": list})
+}
+
+func (h *Handler) handleCreateCollection(w http.ResponseWriter, r *http.Request, userID string) {
+	var req CollectionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+	c, err := h.svc.CreateCollection(userID, req.Name)
+	if err != nil {
+		writeCollectionError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, c)
+}
+
+func (h *Handler) handleGetCollection(w http.ResponseWriter, r *http.Request, userID, collID string) {
+	c, err := h.svc.GetCollection(userID, collID)
+	if err != nil {
+		writeCollectionError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, c)
+}
+
+func (h *Handler) handleRenameCollection(w http.ResponseWriter, r *http.Request, userID, collID string) {
+	var req CollectionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+	c, err := h.svc.RenameCollection(userID, collID, req.Name)
+	if err != nil {
+		writeCollectionError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, c)
+}
+
+func (h *Handler) handleReorderCollections(w http.ResponseWriter, r *http.Request, userID string) {
+	var req CollectionOrderRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+	list, err := h.svc.ReorderCollections(userID, req.IDs)
+	if err != nil {
+		writeCollectionError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"collections": list})
+}
+
+func (h *Handler) handleDeleteCollection(w http.ResponseWriter, r *http.Request, userID, collID string) {
+	if err := h.svc.DeleteCollection(userID, collID); err != nil {
+		writeCollectionError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) handleListCollectionFavorites(w http.ResponseWriter, r *http.Request, userID, collID string) {
+	params, err := getPagedListParams(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	favs, err := h.svc.ListCollectionFavorites(userID, collID, params)
+	if err != nil {
+		writeCollectionError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, favs)
+}
+
+func (h *Handler) handleAddToCollection(w http.ResponseWriter, r *http.Request, userID, collID, favID string) {
+	if err := h.svc.AddToCollection(userID, collID, favID); err != nil {
+		writeCollectionError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) handleRemoveFromCollection(w http.ResponseWriter, r *http.Request, userID, collID, favID string) {
+	if err := h.svc.RemoveFromCollection(userID, collID, favID); err != nil {
+		writeCollectionError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func writeCollectionError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, data.ErrNotFound):
+		writeError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, data.ErrCollectionExists):
+		writeError(w, http.StatusConflict, err.Error())
+	case errors.Is(err, core.ErrInvalidCollectionName), errors.Is(err, data.ErrInvalidOrder),
+		errors.Is(err, core.ErrInvalidCursor):
+		writeError(w, http.StatusBadRequest, err.Error())
+	default:
+		writeError(w, http.StatusInternalServerError, err.Error())
+	}
+}
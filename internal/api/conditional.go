@@ -0,0 +1,89 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Zisimopoulou/platform-go-challenge/internal/data"
+	"github.com/Zisimopoulou/platform-go-challenge/internal/models"
+)
+
+// The version is bumped by every mutation, so it doubles as a strong ETag.
+func assetETag(asset models.RawAsset) string {
+	return `"` + strconv.FormatInt(asset.Version, 10) + `"`
+}
+
+// ifMatchVersion turns If-Match into the version a mutation must find. No
+// header or "*" means any version. Several tags are resolved against the
+// current version, since the store can only compare against one.
+func ifMatchVersion(r *http.Request, current func() (int64, error)) (int64, error) {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if header == "" || header == "*" {
+		return data.AnyVersion, nil
+	}
+
+	var versions []int64
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		// If-Match uses strong comparison, so weak tags never match.
+		if !strings.HasPrefix(tag, `"`) || !strings.HasSuffix(tag, `"`) || len(tag) < 2 {
+			continue
+		}
+		if v, err := strconv.ParseInt(tag[1:len(tag)-1], 10, 64); err == nil && v > 0 {
+			versions = append(versions, v)
+		}
+	}
+
+	switch len(versions) {
+	case 0:
+		return 0, data.ErrVersionMismatch
+	case 1:
+		return versions[0], nil
+	}
+	v, err := current()
+	if err != nil {
+		return 0, err
+	}
+	if !slices.Contains(versions, v) {
+		return 0, data.ErrVersionMismatch
+	}
+	return v, nil
+}
+
+func writeStoreError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, data.ErrNotFound):
+		writeError(w, http.StatusNotFound, "favorite not found")
+	case errors.Is(err, data.ErrVersionMismatch):
+		writeError(w, http.StatusPreconditionFailed, "favorite was modified; refetch and retry")
+	default:
+		writeError(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+// notModified evaluates If-None-Match and, only when that header is absent,
+// If-Modified-Since, as RFC 9110 prescribes for GET.
+func notModified(r *http.Request, etag string, lastModified time.Time) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		return etagListContains(inm, etag)
+	}
+	if ims := r.Header.Get("If-Modified-Since"); ims != "" {
+		t, err := http.ParseTime(ims)
+		return err == nil && !lastModified.After(t)
+	}
+	return false
+}
+
+func etagListContains(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -2,32 +2,41 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
 	"net/http"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/Zisimopoulou/platform-go-challenge/internal/core"
+	"github.com/Zisimopoulou/platform-go-challenge/internal/data"
 	"github.com/Zisimopoulou/platform-go-challenge/internal/models"
+	"github.com/Zisimopoulou/platform-go-challenge/internal/patch"
+	"github.com/Zisimopoulou/platform-go-challenge/internal/validation"
 )
 
 type Handler struct {
 	svc *core.Service
 }
 
-func NewHandler(svc *core.Service) *http.ServeMux {
+func NewHandler(svc *core.Service, opts ...Option) *http.ServeMux {
 	h := &Handler{svc: svc}
 	mux := http.NewServeMux()
 
 	baseHandler := http.HandlerFunc(h.handle)
 
-	protectedHandler := AuthMiddleware(baseHandler)
+	protectedHandler := AuthMiddleware(baseHandler, opts...)
 
 	mux.Handle("/", protectedHandler)
 	return mux
 }
 
 func (h *Handler) handle(w http.ResponseWriter, r *http.Request) {
-	userID, ok := FromContextUserID(r.Context())
+	principal, ok := FromContextPrincipal(r.Context())
 	if !ok {
 		writeError(w, http.StatusUnauthorized, "unauthorized")
 		return
@@ -40,9 +49,15 @@ func (h *Handler) handle(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	requestedUser := parts[0]
-	if requestedUser != userID {
-		writeError(w, http.StatusForbidden, "access denied")
+	// From here on userID is the owner of the favorites being addressed,
+	// which is not the caller when an admin or support user acts on it.
+	userID := parts[0]
+	var favID string
+	if len(parts) == 3 && parts[1] == "favorites" && parts[2] != "search" && parts[2] != "batch" {
+		favID = parts[2]
+	}
+	if err := h.svc.AuthorizeFavorite(principal, userID, favID, actionFor(r.Method), r.RequestURI); err != nil {
+		writeAuthzError(w, err)
 		return
 	}
 
@@ -53,6 +68,16 @@ func (h *Handler) handle(w http.ResponseWriter, r *http.Request) {
 	case len(parts) == 2 && parts[1] == "favorites" && r.Method == http.MethodPost:
 		h.handleAddFavorite(w, r, userID)
 
+	case len(parts) == 3 && parts[1] == "favorites" && parts[2] == "search" && r.Method == http.MethodGet:
+		h.handleSearchFavorites(w, r, userID)
+
+	case len(parts) == 3 && parts[1] == "favorites" && parts[2] == "batch" && r.Method == http.MethodPost:
+		h.handleBatchFavorites(w, r, userID)
+
+	case len(parts) == 3 && parts[1] == "favorites" && r.Method == http.MethodGet:
+		favID := parts[2]
+		h.handleGetFavorite(w, r, userID, favID)
+
 	case len(parts) == 3 && parts[1] == "favorites" && r.Method == http.MethodDelete:
 		favID := parts[2]
 		h.handleDeleteFavorite(w, r, userID, favID)
@@ -61,14 +86,110 @@ func (h *Handler) handle(w http.ResponseWriter, r *http.Request) {
 		favID := parts[2]
 		h.handleUpdateFavorite(w, r, userID, favID)
 
+	case len(parts) == 3 && parts[1] == "favorites" && r.Method == http.MethodPatch:
+		favID := parts[2]
+		h.handlePatchFavorite(w, r, userID, favID)
+
+	case len(parts) == 4 && parts[1] == "favorites" && parts[3] == "history" && r.Method == http.MethodGet:
+		h.handleFavoriteHistory(w, r, userID, parts[2])
+
+	case len(parts) == 5 && parts[1] == "favorites" && parts[3] == "history" && r.Method == http.MethodGet:
+		h.handleGetRevision(w, r, userID, parts[2], parts[4])
+
+	case len(parts) == 6 && parts[1] == "favorites" && parts[3] == "history" && parts[5] == "revert" && r.Method == http.MethodPost:
+		h.handleRevertFavorite(w, r, userID, parts[2], parts[4])
+
+	case len(parts) == 4 && parts[1] == "favorites" && parts[3] == "diff" && r.Method == http.MethodGet:
+		h.handleDiffRevisions(w, r, userID, parts[2])
+
+	case len(parts) == 2 && parts[1] == "shared" && r.Method == http.MethodGet:
+		h.handleSharedWith(w, r, userID)
+
+	case len(parts) == 4 && parts[1] == "favorites" && parts[3] == "shares" && r.Method == http.MethodGet:
+		h.handleListShares(w, r, userID, parts[2])
+
+	case len(parts) == 5 && parts[1] == "favorites" && parts[3] == "shares" && r.Method == http.MethodPut:
+		h.handlePutShare(w, r, userID, parts[2], parts[4])
+
+	case len(parts) == 5 && parts[1] == "favorites" && parts[3] == "shares" && r.Method == http.MethodDelete:
+		h.handleDeleteShare(w, r, userID, parts[2], parts[4])
+
+	case len(parts) == 2 && parts[1] == "trash" && r.Method == http.MethodGet:
+		h.handleListTrash(w, r, userID)
+
+	case len(parts) == 4 && parts[1] == "trash" && parts[3] == "restore" && r.Method == http.MethodPost:
+		h.handleRestoreFavorite(w, r, userID, parts[2])
+
+	case len(parts) == 3 && parts[1] == "trash" && r.Method == http.MethodDelete:
+		h.handlePurgeFavorite(w, r, userID, parts[2])
+
+	case len(parts) == 2 && parts[1] == "tags" && r.Method == http.MethodGet:
+		h.handleListTags(w, r, userID)
+
+	case len(parts) == 4 && parts[1] == "favorites" && parts[3] == "tags" && r.Method == http.MethodPost:
+		h.handleAddTags(w, r, userID, parts[2])
+
+	case len(parts) == 5 && parts[1] == "favorites" && parts[3] == "tags" && r.Method == http.MethodDelete:
+		h.handleRemoveTag(w, r, userID, parts[2], parts[4])
+
+	case len(parts) == 2 && parts[1] == "collections" && r.Method == http.MethodGet:
+		h.handleListCollections(w, r, userID)
+
+	case len(parts) == 2 && parts[1] == "collections" && r.Method == http.MethodPost:
+		h.handleCreateCollection(w, r, userID)
+
+	case len(parts) == 3 && parts[1] == "collections" && parts[2] == "order" && r.Method == http.MethodPut:
+		h.handleReorderCollections(w, r, userID)
+
+	case len(parts) == 3 && parts[1] == "collections" && r.Method == http.MethodGet:
+		h.handleGetCollection(w, r, userID, parts[2])
+
+	case len(parts) == 3 && parts[1] == "collections" && (r.Method == http.MethodPut || r.Method == http.MethodPatch):
+		h.handleRenameCollection(w, r, userID, parts[2])
+
+	case len(parts) == 3 && parts[1] == "collections" && r.Method == http.MethodDelete:
+		h.handleDeleteCollection(w, r, userID, parts[2])
+
+	case len(parts) == 4 && parts[1] == "collections" && parts[3] == "favorites" && r.Method == http.MethodGet:
+		h.handleListCollectionFavorites(w, r, userID, parts[2])
+
+	case len(parts) == 5 && parts[1] == "collections" && parts[3] == "favorites" && r.Method == http.MethodPut:
+		h.handleAddToCollection(w, r, userID, parts[2], parts[4])
+
+	case len(parts) == 5 && parts[1] == "collections" && parts[3] == "favorites" && r.Method == http.MethodDelete:
+		h.handleRemoveFromCollection(w, r, userID, parts[2], parts[4])
+
 	default:
 		writeError(w, http.StatusNotFound, "not found")
 	}
 }
 
+func writeAuthzError(w http.ResponseWriter, err error) {
+	if errors.Is(err, core.ErrForbidden) || errors.Is(err, core.ErrInsufficientScope) {
+		writeError(w, http.StatusForbidden, err.Error())
+		return
+	}
+	writeError(w, http.StatusInternalServerError, err.Error())
+}
+
+func actionFor(method string) core.Action {
+	switch method {
+	case http.MethodGet, http.MethodHead:
+		return core.ActionRead
+	case http.MethodDelete:
+		return core.ActionDelete
+	default:
+		return core.ActionWrite
+	}
+}
+
 func (h *Handler) handleAddFavorite(w http.ResponseWriter, r *http.Request, userID string) {
 	var asset models.RawAsset
 	if err := json.NewDecoder(r.Body).Decode(&asset); err != nil {
+		if errors.Is(err, models.ErrInvalidPayload) {
+			validationErrorResponse(w, err)
+			return
+		}
 		writeError(w, http.StatusBadRequest, "invalid JSON body")
 		return
 	}
@@ -78,14 +199,123 @@ func (h *Handler) handleAddFavorite(w http.ResponseWriter, r *http.Request, user
 		return
 	}
 
-	id, err := h.svc.AddFavorite(userID, asset)
+	key := r.Header.Get("Idempotency-Key")
+	if key == "" {
+		id, err := h.svc.AddFavorite(userID, asset)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusCreated, map[string]string{"favoriteId": id})
+		return
+	}
+	if len(key) > maxIdempotencyKeyLen {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("Idempotency-Key cannot exceed %d characters", maxIdempotencyKeyLen))
+		return
+	}
+
+	id, replayed, err := h.svc.AddFavoriteIdempotent(userID, key, asset)
+	if errors.Is(err, core.ErrIdempotencyConflict) {
+		writeError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
 	if err != nil {
 		writeError(w, http.StatusBadRequest, err.Error())
 		return
 	}
+	if replayed {
+		w.Header().Set("Idempotent-Replayed", "true")
+	}
 	writeJSON(w, http.StatusCreated, map[string]string{"favoriteId": id})
 }
 
+const maxIdempotencyKeyLen = 255
+
+// handleBatchFavorites answers 200 when every operation succeeded and 207
+// otherwise; each item carries the status its single-item request would have.
+func (h *Handler) handleBatchFavorites(w http.ResponseWriter, r *http.Request, userID string) {
+	var req models.BatchRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 16<<20)).Decode(&req); err != nil {
+		if errors.Is(err, models.ErrInvalidPayload) {
+			validationErrorResponse(w, err)
+			return
+		}
+		writeError(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+
+	results, err := h.svc.BatchFavorites(userID, req)
+	if errors.Is(err, core.ErrEmptyBatch) || errors.Is(err, core.ErrBatchTooLarge) {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	resp := models.BatchResponse{Atomic: req.Atomic, Results: make([]models.BatchItemResult, len(results))}
+	for i, res := range results {
+		item := models.BatchItemResult{Index: i, Op: req.Operations[i].Op, FavoriteID: res.FavoriteID, Favorite: res.Favorite}
+		if res.Err != nil {
+			item.Status, item.Error = batchErrorStatus(res.Err), res.Err.Error()
+			resp.Failed++
+		} else {
+			item.Status = batchSuccessStatus[data.BatchOpKind(item.Op)]
+			resp.Succeeded++
+		}
+		resp.Results[i] = item
+	}
+
+	code := http.StatusOK
+	if resp.Failed > 0 {
+		code = http.StatusMultiStatus
+	}
+	writeJSON(w, code, resp)
+}
+
+var batchSuccessStatus = map[data.BatchOpKind]int{
+	data.BatchAdd:    http.StatusCreated,
+	data.BatchUpdate: http.StatusOK,
+	data.BatchDelete: http.StatusNoContent,
+}
+
+func batchErrorStatus(err error) int {
+	var invalid *core.InvalidAssetError
+	switch {
+	case errors.As(err, &invalid), errors.Is(err, data.ErrInvalidBatchOp):
+		return http.StatusBadRequest
+	case errors.Is(err, data.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, data.ErrVersionMismatch):
+		return http.StatusPreconditionFailed
+	case errors.Is(err, data.ErrBatchAborted):
+		return http.StatusFailedDependency
+	case errors.Is(err, data.ErrTagLimit):
+		return http.StatusUnprocessableEntity
+	}
+	return http.StatusInternalServerError
+}
+
+func (h *Handler) handleGetFavorite(w http.ResponseWriter, r *http.Request, userID, favID string) {
+	fav, err := h.svc.GetFavorite(userID, favID)
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	etag := assetETag(fav.Asset)
+	lastModified := fav.Asset.UpdatedAt.UTC().Truncate(time.Second)
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Last-Modified", lastModified.Format(http.TimeFormat))
+
+	if notModified(r, etag, lastModified) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	writeJSON(w, http.StatusOK, fav)
+}
+
 func (h *Handler) handleUpdateFavorite(w http.ResponseWriter, r *http.Request, userID, favID string) {
 	var body struct {
 		Description string `json:"description" validate:"required,max=500"`
@@ -101,16 +331,98 @@ func (h *Handler) handleUpdateFavorite(w http.ResponseWriter, r *http.Request, u
 		return
 	}
 
-	if err := h.svc.UpdateDescription(userID, favID, body.Description); err != nil {
-		writeError(w, http.StatusNotFound, err.Error())
+	version, err := ifMatchVersion(r, h.currentVersion(userID, favID))
+	if err != nil {
+		writeStoreError(w, err)
 		return
 	}
+
+	principal, _ := FromContextPrincipal(r.Context())
+	fav, err := h.svc.UpdateDescription(principal.UserID, userID, favID, body.Description, version)
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	w.Header().Set("ETag", assetETag(fav.Asset))
 	w.WriteHeader(http.StatusNoContent)
 }
 
+func (h *Handler) currentVersion(userID, favID string) func() (int64, error) {
+	return func() (int64, error) {
+		fav, err := h.svc.GetFavorite(userID, favID)
+		if err != nil {
+			return 0, err
+		}
+		return fav.Asset.Version, nil
+	}
+}
+
+const acceptPatch = "application/merge-patch+json, application/json-patch+json"
+
+func (h *Handler) handlePatchFavorite(w http.ResponseWriter, r *http.Request, userID, favID string) {
+	var format core.PatchFormat
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case "application/merge-patch+json":
+		format = core.MergePatch
+	case "application/json-patch+json":
+		format = core.JSONPatch
+	default:
+		w.Header().Set("Accept-Patch", acceptPatch)
+		writeError(w, http.StatusUnsupportedMediaType, "unsupported patch format")
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 1<<20))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid body")
+		return
+	}
+
+	version, err := ifMatchVersion(r, h.currentVersion(userID, favID))
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	principal, _ := FromContextPrincipal(r.Context())
+	fav, err := h.svc.PatchFavorite(principal.UserID, userID, favID, format, body, version)
+	var invalid *core.InvalidAssetError
+	switch {
+	case err == nil:
+	case errors.Is(err, data.ErrNotFound), errors.Is(err, data.ErrVersionMismatch):
+		writeStoreError(w, err)
+		return
+	case errors.Is(err, patch.ErrInvalidPatch), errors.Is(err, patch.ErrPathNotFound):
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	case errors.Is(err, patch.ErrTestFailed):
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	case errors.Is(err, core.ErrTypeChange), errors.Is(err, data.ErrTagLimit):
+		writeError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	case errors.As(err, &invalid):
+		validationErrorResponse(w, invalid.Err)
+		return
+	default:
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.Header().Set("ETag", assetETag(fav.Asset))
+	writeJSON(w, http.StatusOK, fav)
+}
+
 func (h *Handler) handleDeleteFavorite(w http.ResponseWriter, r *http.Request, userID, favID string) {
-	if err := h.svc.DeleteFavorite(userID, favID); err != nil {
-		writeError(w, http.StatusNotFound, err.Error())
+	version, err := ifMatchVersion(r, h.currentVersion(userID, favID))
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	if err := h.svc.DeleteFavorite(userID, favID, version); err != nil {
+		writeStoreError(w, err)
 		return
 	}
 	w.WriteHeader(http.StatusNoContent)
@@ -128,9 +440,17 @@ func writeJSON(w http.ResponseWriter, code int, v interface{}) {
 }
 
 func (h *Handler) handleListFavorites(w http.ResponseWriter, r *http.Request, userID string) {
-	limit, offset := getPaginationParams(r)
+	params, err := getPagedListParams(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
 
-	favs, err := h.svc.ListFavorites(userID, limit, offset)
+	favs, err := h.svc.ListFavorites(userID, params)
+	if errors.Is(err, core.ErrInvalidCursor) {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, err.Error())
 		return
@@ -138,6 +458,33 @@ func (h *Handler) handleListFavorites(w http.ResponseWriter, r *http.Request, us
 	writeJSON(w, http.StatusOK, favs)
 }
 
+// getPagedListParams reads filters, sorting and either offset or cursor
+// pagination, as accepted by every favorites listing.
+func getPagedListParams(r *http.Request) (core.ListParams, error) {
+	params, err := getListParams(r)
+	if err != nil {
+		return params, err
+	}
+	params.Limit, params.Offset = getPaginationParams(r)
+	params.Cursor = r.URL.Query().Get("cursor")
+	return params, nil
+}
+
+func (h *Handler) handleSearchFavorites(w http.ResponseWriter, r *http.Request, userID string) {
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+
+	results, err := h.svc.SearchFavorites(userID, r.URL.Query().Get("q"), limit)
+	if errors.Is(err, core.ErrEmptyQuery) {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, results)
+}
+
 func getPaginationParams(r *http.Request) (int, int) {
 	limitStr := r.URL.Query().Get("limit")
 	offsetStr := r.URL.Query().Get("offset")
@@ -162,3 +509,77 @@ func getPaginationParams(r *http.Request) (int, int) {
 
 	return limit, offset
 }
+
+// maxTagsFilter keeps a tags query within what one SQL statement can bind.
+const maxTagsFilter = 100
+
+func getListParams(r *http.Request) (core.ListParams, error) {
+	query := r.URL.Query()
+	var params core.ListParams
+
+	for _, value := range query["type"] {
+		for _, t := range strings.Split(value, ",") {
+			assetType := models.AssetType(strings.TrimSpace(t))
+			if _, ok := models.LookupAssetType(assetType); !ok {
+				return params, fmt.Errorf("invalid type filter %q", t)
+			}
+			params.Filter.Types = append(params.Filter.Types, assetType)
+		}
+	}
+
+	for name, target := range map[string]*time.Time{
+		"createdAfter":  &params.Filter.CreatedAfter,
+		"createdBefore": &params.Filter.CreatedBefore,
+	} {
+		if v := query.Get(name); v != "" {
+			t, err := time.Parse(time.RFC3339Nano, v)
+			if err != nil {
+				return params, fmt.Errorf("invalid %s: expected an RFC 3339 timestamp", name)
+			}
+			*target = t.UTC()
+		}
+	}
+
+	params.Filter.Description = query.Get("description")
+
+	var tags []string
+	for _, value := range query["tags"] {
+		tags = append(tags, strings.Split(value, ",")...)
+	}
+	for _, tag := range tags {
+		normalized, err := validation.NormalizeTag(tag)
+		if err != nil {
+			return params, fmt.Errorf("invalid tags filter: %v", err)
+		}
+		params.Filter.Tags = append(params.Filter.Tags, normalized)
+	}
+	slices.Sort(params.Filter.Tags)
+	params.Filter.Tags = slices.Compact(params.Filter.Tags)
+	if len(params.Filter.Tags) > maxTagsFilter {
+		return params, fmt.Errorf("invalid tags filter: at most %d tags can be filtered on", maxTagsFilter)
+	}
+	switch match := query.Get("tagMatch"); match {
+	case "", "any":
+	case "all":
+		params.Filter.AllTags = true
+	default:
+		return params, fmt.Errorf("invalid tagMatch %q: expected any or all", match)
+	}
+
+	switch sortBy := data.SortField(query.Get("sort")); sortBy {
+	case "", data.SortByCreatedAt, data.SortByDescription:
+		params.Sort = sortBy
+	default:
+		return params, fmt.Errorf("invalid sort %q: expected createdAt or description", sortBy)
+	}
+
+	switch order := query.Get("order"); order {
+	case "", "desc":
+	case "asc":
+		params.Ascending = true
+	default:
+		return params, fmt.Errorf("invalid order %q: expected asc or desc", order)
+	}
+
+	return params, nil
+}
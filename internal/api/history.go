@@ -0,0 +1,101 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Zisimopoulou/platform-go-challenge/internal/core"
+	"github.com/Zisimopoulou/platform-go-challenge/internal/data"
+)
+
+func (h *Handler) handleFavoriteHistory(w http.ResponseWriter, r *http.Request, userID, favID string) {
+	limit, offset := getPaginationParams(r)
+	history, err := h.svc.FavoriteHistory(userID, favID, limit, offset)
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, history)
+}
+
+func (h *Handler) handleGetRevision(w http.ResponseWriter, r *http.Request, userID, favID, version string) {
+	v, err := parseVersion(version)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	rev, err := h.svc.FavoriteRevision(userID, favID, v)
+	if err != nil {
+		writeRevisionError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, rev)
+}
+
+func (h *Handler) handleDiffRevisions(w http.ResponseWriter, r *http.Request, userID, favID string) {
+	from, err := parseVersion(r.URL.Query().Get("from"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "from: "+err.Error())
+		return
+	}
+	var to int64
+	if raw := r.URL.Query().Get("to"); raw != "" {
+		if to, err = parseVersion(raw); err != nil {
+			writeError(w, http.StatusBadRequest, "to: "+err.Error())
+			return
+		}
+	}
+	diff, err := h.svc.DiffRevisions(userID, favID, from, to)
+	if err != nil {
+		writeRevisionError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, diff)
+}
+
+func (h *Handler) handleRevertFavorite(w http.ResponseWriter, r *http.Request, userID, favID, version string) {
+	v, err := parseVersion(version)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	expected, err := ifMatchVersion(r, h.currentVersion(userID, favID))
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	fav, err := h.svc.RevertFavorite(userID, favID, v, expected)
+	var invalid *core.InvalidAssetError
+	switch {
+	case err == nil:
+	case errors.Is(err, data.ErrTagLimit):
+		writeError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	case errors.As(err, &invalid):
+		validationErrorResponse(w, invalid.Err)
+		return
+	default:
+		writeRevisionError(w, err)
+		return
+	}
+	w.Header().Set("ETag", assetETag(fav.Asset))
+	writeJSON(w, http.StatusOK, fav)
+}
+
+func parseVersion(raw string) (int64, error) {
+	v, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil || v < 1 {
+		return 0, errors.New("version must be a positive integer")
+	}
+	return v, nil
+}
+
+func writeRevisionError(w http.ResponseWriter, err error) {
+	if errors.Is(err, data.ErrNotFound) {
+		writeError(w, http.StatusNotFound, "revision not found")
+		return
+	}
+	writeStoreError(w, err)
+}
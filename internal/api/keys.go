@@ -0,0 +1,81 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/Zisimopoulou/platform-go-challenge/internal/auth"
+	"github.com/Zisimopoulou/platform-go-challenge/internal/schema"
+)
+
+type Option func(*options)
+
+type options struct {
+	keys    *auth.KeySet
+	issuer  *auth.Verifier
+	apiKeys *auth.APIKeys
+	schemas *schema.Loader
+}
+
+// WithKeySet signs and verifies tokens with ks. Without it tokens are HS256
+// with the secret from JWT_SECRET or APP_JWT_SECRET.
+func WithKeySet(ks *auth.KeySet) Option {
+	return func(o *options) {
+		o.keys = ks
+	}
+}
+
+// WithExternalIssuer also accepts access tokens issued by an external
+// identity provider. Tokens whose iss matches v are checked by v alone;
+// everything else still goes through the local key set. External users get
+// the plain user role.
+func WithExternalIssuer(v *auth.Verifier) Option {
+	return func(o *options) {
+		o.issuer = v
+	}
+}
+
+// WithAPIKeys accepts API keys from keys in the X-API-Key header.
+func WithAPIKeys(keys *auth.APIKeys) Option {
+	return func(o *options) {
+		o.apiKeys = keys
+	}
+}
+
+// WithSchemaLoader lets admins reload the schema-defined asset types from
+// loader with POST /admin/asset-types/reload.
+func WithSchemaLoader(loader *schema.Loader) Option {
+	return func(o *options) {
+		o.schemas = loader
+	}
+}
+
+func newOptions(opts []Option) options {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+func (o options) keySet() *auth.KeySet {
+	if o.keys != nil {
+		return o.keys
+	}
+	ks, err := auth.NewKeySet(auth.NewHMACKey("", getJWTSecret()))
+	if err != nil {
+		panic(err)
+	}
+	return ks
+}
+
+// JWKSHandler publishes the public verification keys of ks.
+func JWKSHandler(ks *auth.KeySet) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		w.Header().Set("Cache-Control", "public, max-age=300")
+		writeJSON(w, http.StatusOK, ks.JWKS())
+	}
+}
@@ -0,0 +1,79 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/Zisimopoulou/platform-go-challenge/internal/core"
+	"github.com/Zisimopoulou/platform-go-challenge/internal/data"
+	"github.com/Zisimopoulou/platform-go-challenge/internal/models"
+)
+
+type ShareRequest struct {
+	Permission models.Permission `json:"permission"`
+}
+
+func (h *Handler) handleSharedWith(w http.ResponseWriter, r *http.Request, userID string) {
+	shared, err := h.svc.SharedWith(userID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"favorites": shared})
+}
+
+func (h *Handler) handleListShares(w http.ResponseWriter, r *http.Request, userID, favID string) {
+	shares, err := h.svc.ListShares(userID, favID)
+	if err != nil {
+		writeShareError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"shares": shares})
+}
+
+// handlePutShare answers 201 for a new grant and 200 when it changes the
+// permission of an existing one.
+func (h *Handler) handlePutShare(w http.ResponseWriter, r *http.Request, userID, favID, grantee string) {
+	var req ShareRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+	_, err := h.svc.GetShare(userID, favID, grantee)
+	existed := err == nil
+	if err != nil && !errors.Is(err, data.ErrNotFound) {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	share, err := h.svc.ShareFavorite(userID, favID, grantee, req.Permission)
+	if err != nil {
+		writeShareError(w, err)
+		return
+	}
+	code := http.StatusCreated
+	if existed {
+		code = http.StatusOK
+	}
+	writeJSON(w, code, share)
+}
+
+func (h *Handler) handleDeleteShare(w http.ResponseWriter, r *http.Request, userID, favID, grantee string) {
+	if err := h.svc.UnshareFavorite(userID, favID, grantee); err != nil {
+		writeShareError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func writeShareError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, data.ErrNotFound):
+		writeError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, core.ErrInvalidPermission), errors.Is(err, core.ErrShareWithOwner):
+		writeError(w, http.StatusBadRequest, err.Error())
+	default:
+		writeError(w, http.StatusInternalServerError, err.Error())
+	}
+}
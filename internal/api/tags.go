@@ -0,0 +1,60 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/Zisimopoulou/platform-go-challenge/internal/data"
+	"github.com/Zisimopoulou/platform-go-challenge/internal/validation"
+)
+
+type TagsRequest struct {
+	Tags []string `json:"tags"`
+}
+
+func (h *Handler) handleListTags(w http.ResponseWriter, r *http.Request, userID string) {
+	tags, err := h.svc.ListTags(userID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"tags": tags})
+}
+
+func (h *Handler) handleAddTags(w http.ResponseWriter, r *http.Request, userID, favID string) {
+	var req TagsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.Tags) == 0 {
+		writeError(w, http.StatusBadRequest, "invalid JSON body: expected a non-empty tags array")
+		return
+	}
+	h.tagFavorite(w, r, userID, favID, req.Tags, nil)
+}
+
+func (h *Handler) handleRemoveTag(w http.ResponseWriter, r *http.Request, userID, favID, tag string) {
+	h.tagFavorite(w, r, userID, favID, nil, []string{tag})
+}
+
+func (h *Handler) tagFavorite(w http.ResponseWriter, r *http.Request, userID, favID string, add, remove []string) {
+	version, err := ifMatchVersion(r, h.currentVersion(userID, favID))
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	fav, err := h.svc.TagFavorite(userID, favID, add, remove, version)
+	switch {
+	case err == nil:
+	case errors.Is(err, validation.ErrInvalidTag), errors.Is(err, validation.ErrTooManyTags):
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	case errors.Is(err, data.ErrTagLimit):
+		writeError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	default:
+		writeStoreError(w, err)
+		return
+	}
+	w.Header().Set("ETag", assetETag(fav.Asset))
+	writeJSON(w, http.StatusOK, fav)
+}
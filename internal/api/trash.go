@@ -0,0 +1,48 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Zisimopoulou/platform-go-challenge/internal/data"
+)
+
+func (h *Handler) handleListTrash(w http.ResponseWriter, r *http.Request, userID string) {
+	limit, offset := getPaginationParams(r)
+	trashed, err := h.svc.ListTrash(userID, limit, offset)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, trashed)
+}
+
+func (h *Handler) handleRestoreFavorite(w http.ResponseWriter, r *http.Request, userID, favID string) {
+	fav, err := h.svc.RestoreFavorite(userID, favID)
+	switch {
+	case err == nil:
+	case errors.Is(err, data.ErrNotFound):
+		writeError(w, http.StatusNotFound, "favorite not in trash")
+		return
+	case errors.Is(err, data.ErrTagLimit):
+		writeError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	default:
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.Header().Set("ETag", assetETag(fav.Asset))
+	writeJSON(w, http.StatusOK, fav)
+}
+
+func (h *Handler) handlePurgeFavorite(w http.ResponseWriter, r *http.Request, userID, favID string) {
+	err := h.svc.PurgeFavorite(userID, favID)
+	switch {
+	case err == nil:
+		w.WriteHeader(http.StatusNoContent)
+	case errors.Is(err, data.ErrNotFound):
+		writeError(w, http.StatusNotFound, "favorite not in trash")
+	default:
+		writeError(w, http.StatusInternalServerError, err.Error())
+	}
+}
@@ -1,10 +1,12 @@
  package api
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 
 	"github.com/Zisimopoulou/platform-go-challenge/internal/models"
+	"github.com/Zisimopoulou/platform-go-challenge/internal/schema"
 	"github.com/Zisimopoulou/platform-go-challenge/internal/validation"   
 	"github.com/go-playground/validator/v10"
 )
@@ -18,6 +20,7 @@ import (
 }
 
  func validationErrorResponse(w http.ResponseWriter, err error) {
+	var schemaErrors schema.ValidationErrors
 	if validationErrors, ok := err.(validator.ValidationErrors); ok {
 		errors := make(map[string]string)
 		for _, fieldError := range validationErrors {
@@ -31,6 +34,23 @@ import (
 			"error":   "validation failed",
 			"details": errors,
 		})
+	} else if errors.As(err, &schemaErrors) {
+		details := make(map[string]string)
+		for _, fieldError := range schemaErrors {
+			field := fieldError.Field
+			if field == "" {
+				field = "payload"
+			}
+			if prev, ok := details[field]; ok {
+				details[field] = prev + "; " + fieldError.Message
+			} else {
+				details[field] = fieldError.Message
+			}
+		}
+		writeJSON(w, http.StatusBadRequest, map[string]interface{}{
+			"error":   "validation failed",
+			"details": details,
+		})
 	} else {
 		writeError(w, http.StatusBadRequest, err.Error())
 	}
@@ -0,0 +1,202 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	APIKeyPrefix      = "fav_"
+	MaxAPIKeysPerUser = 20
+	maxAPIKeyNameLen  = 100
+)
+
+var (
+	ErrAPIKeyInvalid  = errors.New("API key is invalid or revoked")
+	ErrAPIKeyNotFound = errors.New("API key not found")
+	ErrInvalidKeyName = fmt.Errorf("API key name must be 1-%d characters", maxAPIKeyNameLen)
+	ErrTooManyAPIKeys = fmt.Errorf("a user may have at most %d API keys", MaxAPIKeysPerUser)
+)
+
+// APIKey is the stored record of a machine credential. Only a SHA-256 of the
+// secret is kept; the secret has enough entropy that a slow hash buys
+// nothing, and it keeps per-request verification cheap.
+type APIKey struct {
+	ID     string
+	UserID string
+	Name   string
+	Hash   []byte
+	// Scopes are stored as given; what they allow is up to the caller.
+	Scopes     []string
+	CreatedAt  time.Time
+	LastUsedAt time.Time
+}
+
+// APIKeyStore persists API keys. Delete and Touch fail with
+// ErrAPIKeyNotFound for unknown IDs.
+type APIKeyStore interface {
+	Save(k APIKey) error
+	Get(id string) (APIKey, error)
+	ListByUser(userID string) ([]APIKey, error)
+	Delete(id string) error
+	Touch(id string, at time.Time) error
+}
+
+type InMemoryAPIKeyStore struct {
+	mu   sync.RWMutex
+	keys map[string]APIKey
+}
+
+func NewInMemoryAPIKeyStore() *InMemoryAPIKeyStore {
+	return &InMemoryAPIKeyStore{keys: make(map[string]APIKey)}
+}
+
+func (s *InMemoryAPIKeyStore) Save(k APIKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys[k.ID] = k
+	return nil
+}
+
+func (s *InMemoryAPIKeyStore) Get(id string) (APIKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	k, ok := s.keys[id]
+	if !ok {
+		return APIKey{}, ErrAPIKeyNotFound
+	}
+	return k, nil
+}
+
+func (s *InMemoryAPIKeyStore) ListByUser(userID string) ([]APIKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var keys []APIKey
+	for _, k := range s.keys {
+		if k.UserID == userID {
+			keys = append(keys, k)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].CreatedAt.Before(keys[j].CreatedAt) })
+	return keys, nil
+}
+
+func (s *InMemoryAPIKeyStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.keys[id]; !ok {
+		return ErrAPIKeyNotFound
+	}
+	delete(s.keys, id)
+	return nil
+}
+
+func (s *InMemoryAPIKeyStore) Touch(id string, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	k, ok := s.keys[id]
+	if !ok {
+		return ErrAPIKeyNotFound
+	}
+	k.LastUsedAt = at
+	s.keys[id] = k
+	return nil
+}
+
+type APIKeys struct {
+	store APIKeyStore
+	// mu keeps the per-user limit from being raced past.
+	mu sync.Mutex
+}
+
+func NewAPIKeys(store APIKeyStore) *APIKeys {
+	return &APIKeys{store: store}
+}
+
+// Create issues a key and returns it with the full secret, which is never
+// available again.
+func (a *APIKeys) Create(userID, name string, scopes []string) (APIKey, string, error) {
+	if name == "" || len(name) > maxAPIKeyNameLen {
+		return APIKey{}, "", ErrInvalidKeyName
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	existing, err := a.store.ListByUser(userID)
+	if err != nil {
+		return APIKey{}, "", err
+	}
+	if len(existing) >= MaxAPIKeysPerUser {
+		return APIKey{}, "", ErrTooManyAPIKeys
+	}
+
+	secret := rand.Text()
+	sum := sha256.Sum256([]byte(secret))
+	k := APIKey{
+		ID:        rand.Text(),
+		UserID:    userID,
+		Name:      name,
+		Hash:      sum[:],
+		Scopes:    scopes,
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := a.store.Save(k); err != nil {
+		return APIKey{}, "", err
+	}
+	return k, APIKeyPrefix + k.ID + "_" + secret, nil
+}
+
+func (a *APIKeys) List(userID string) ([]APIKey, error) {
+	return a.store.ListByUser(userID)
+}
+
+// Revoke deletes one of userID's keys. Keys of other users are reported as
+// not found.
+func (a *APIKeys) Revoke(userID, id string) error {
+	k, err := a.store.Get(id)
+	if err != nil {
+		return err
+	}
+	if k.UserID != userID {
+		return ErrAPIKeyNotFound
+	}
+	return a.store.Delete(id)
+}
+
+// Authenticate resolves a presented key and records its use.
+func (a *APIKeys) Authenticate(presented string) (APIKey, error) {
+	rest, ok := strings.CutPrefix(presented, APIKeyPrefix)
+	if !ok {
+		return APIKey{}, ErrAPIKeyInvalid
+	}
+	id, secret, ok := strings.Cut(rest, "_")
+	if !ok {
+		return APIKey{}, ErrAPIKeyInvalid
+	}
+	k, err := a.store.Get(id)
+	if errors.Is(err, ErrAPIKeyNotFound) {
+		return APIKey{}, ErrAPIKeyInvalid
+	}
+	if err != nil {
+		return APIKey{}, err
+	}
+	sum := sha256.Sum256([]byte(secret))
+	if subtle.ConstantTimeCompare(sum[:], k.Hash) != 1 {
+		return APIKey{}, ErrAPIKeyInvalid
+	}
+	k.LastUsedAt = time.Now().UTC()
+	// A key revoked since the lookup above must not get through.
+	if err := a.store.Touch(k.ID, k.LastUsedAt); errors.Is(err, ErrAPIKeyNotFound) {
+		return APIKey{}, ErrAPIKeyInvalid
+	} else if err != nil {
+		return APIKey{}, err
+	}
+	return k, nil
+}
@@ -0,0 +1,155 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+)
+
+// touchWriteInterval bounds how often Touch rewrites the file for keys that
+// were used before. Last-use times are exact in memory, but after a crash
+// they can be this much behind.
+const touchWriteInterval = time.Minute
+
+// FileAPIKeyStore keeps API keys in memory and in a JSON file that is
+// rewritten atomically on every change, for stores without a database.
+type FileAPIKeyStore struct {
+	keys *InMemoryAPIKeyStore
+	path string
+	// mu orders file writes, so the file always holds the latest set.
+	mu      sync.Mutex
+	written time.Time
+}
+
+// NewFileAPIKeyStore loads the keys saved at path, if any.
+func NewFileAPIKeyStore(path string) (*FileAPIKeyStore, error) {
+	s := &FileAPIKeyStore{keys: NewInMemoryAPIKeyStore(), path: path}
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var keys []APIKey
+	if err := json.Unmarshal(raw, &keys); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	for _, k := range keys {
+		s.keys.keys[k.ID] = k
+	}
+	return s, nil
+}
+
+func (s *FileAPIKeyStore) Save(k APIKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	keys := s.snapshot()
+	keys[k.ID] = k
+	if err := s.write(keys); err != nil {
+		return err
+	}
+	return s.keys.Save(k)
+}
+
+func (s *FileAPIKeyStore) Get(id string) (APIKey, error) {
+	return s.keys.Get(id)
+}
+
+func (s *FileAPIKeyStore) ListByUser(userID string) ([]APIKey, error) {
+	return s.keys.ListByUser(userID)
+}
+
+func (s *FileAPIKeyStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	keys := s.snapshot()
+	if _, ok := keys[id]; !ok {
+		return ErrAPIKeyNotFound
+	}
+	delete(keys, id)
+	if err := s.write(keys); err != nil {
+		return err
+	}
+	return s.keys.Delete(id)
+}
+
+func (s *FileAPIKeyStore) Touch(id string, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	prev, err := s.keys.Get(id)
+	if err != nil {
+		return err
+	}
+	if err := s.keys.Touch(id, at); err != nil {
+		return err
+	}
+	if !prev.LastUsedAt.IsZero() && time.Since(s.written) < touchWriteInterval {
+		return nil
+	}
+	return s.write(s.snapshot())
+}
+
+func (s *FileAPIKeyStore) snapshot() map[string]APIKey {
+	s.keys.mu.RLock()
+	defer s.keys.mu.RUnlock()
+	keys := make(map[string]APIKey, len(s.keys.keys))
+	for id, k := range s.keys.keys {
+		keys[id] = k
+	}
+	return keys
+}
+
+// write replaces the file through a synced temporary file, so a crash leaves
+// either the old set or the new one.
+func (s *FileAPIKeyStore) write(keys map[string]APIKey) error {
+	list := make([]APIKey, 0, len(keys))
+	for _, k := range keys {
+		list = append(list, k)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	raw, err := json.Marshal(list)
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(s.path)
+	tmp, err := os.CreateTemp(dir, filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(raw); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return err
+	}
+	// Windows cannot sync a directory handle, and its renames do not need it.
+	if runtime.GOOS != "windows" {
+		d, err := os.Open(dir)
+		if err != nil {
+			return err
+		}
+		defer d.Close()
+		if err := d.Sync(); err != nil {
+			return err
+		}
+	}
+	s.written = time.Now()
+	return nil
+}
@@ -0,0 +1,108 @@
+package auth
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// SQLiteAPIKeyStore keeps API keys in the api_keys table that
+// data.NewSQLiteStore creates, so they survive a restart.
+type SQLiteAPIKeyStore struct {
+	db *sql.DB
+}
+
+func NewSQLiteAPIKeyStore(db *sql.DB) *SQLiteAPIKeyStore {
+	return &SQLiteAPIKeyStore{db: db}
+}
+
+const apiKeyColumns = `id, user_id, name, hash, scopes, created_at, last_used_at`
+
+func (s *SQLiteAPIKeyStore) Save(k APIKey) error {
+	scopes, err := json.Marshal(k.Scopes)
+	if err != nil {
+		return err
+	}
+	var lastUsed int64
+	if !k.LastUsedAt.IsZero() {
+		lastUsed = k.LastUsedAt.UnixNano()
+	}
+	_, err = s.db.Exec(`INSERT INTO api_keys (`+apiKeyColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET user_id = excluded.user_id, name = excluded.name, hash = excluded.hash,
+			scopes = excluded.scopes, created_at = excluded.created_at, last_used_at = excluded.last_used_at`,
+		k.ID, k.UserID, k.Name, k.Hash, string(scopes), k.CreatedAt.UnixNano(), lastUsed)
+	return err
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanAPIKey(row rowScanner) (APIKey, error) {
+	var (
+		k                   APIKey
+		scopes              string
+		createdAt, lastUsed int64
+	)
+	if err := row.Scan(&k.ID, &k.UserID, &k.Name, &k.Hash, &scopes, &createdAt, &lastUsed); err != nil {
+		return APIKey{}, err
+	}
+	if err := json.Unmarshal([]byte(scopes), &k.Scopes); err != nil {
+		return APIKey{}, fmt.Errorf("decode scopes of API key %s: %w", k.ID, err)
+	}
+	k.CreatedAt = time.Unix(0, createdAt).UTC()
+	if lastUsed > 0 {
+		k.LastUsedAt = time.Unix(0, lastUsed).UTC()
+	}
+	return k, nil
+}
+
+func (s *SQLiteAPIKeyStore) Get(id string) (APIKey, error) {
+	k, err := scanAPIKey(s.db.QueryRow(`SELECT `+apiKeyColumns+` FROM api_keys WHERE id = ?`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return APIKey{}, ErrAPIKeyNotFound
+	}
+	return k, err
+}
+
+func (s *SQLiteAPIKeyStore) ListByUser(userID string) ([]APIKey, error) {
+	rows, err := s.db.Query(`SELECT `+apiKeyColumns+` FROM api_keys WHERE user_id = ? ORDER BY created_at, id`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var keys []APIKey
+	for rows.Next() {
+		k, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+	return keys, rows.Err()
+}
+
+func (s *SQLiteAPIKeyStore) Delete(id string) error {
+	return notFoundIfNone(s.db.Exec(`DELETE FROM api_keys WHERE id = ?`, id))
+}
+
+func (s *SQLiteAPIKeyStore) Touch(id string, at time.Time) error {
+	return notFoundIfNone(s.db.Exec(`UPDATE api_keys SET last_used_at = ? WHERE id = ?`, at.UnixNano(), id))
+}
+
+// notFoundIfNone turns a statement that touched no key into ErrAPIKeyNotFound.
+func notFoundIfNone(res sql.Result, err error) error {
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrAPIKeyNotFound
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package auth
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+var (
+	ErrUserNotFound  = errors.New("user not found")
+	ErrUsernameTaken = errors.New("username already taken")
+)
+
+// User is a registered account. The username doubles as the user ID that
+// favorites are stored under.
+type User struct {
+	Username       string
+	PasswordHash   []byte
+	CreatedAt      time.Time
+	FailedAttempts int
+	LockedUntil    time.Time
+	// Roles end up in the access token; what they allow is up to the caller.
+	Roles []string
+}
+
+// CredentialStore persists accounts. Implementations must be safe for
+// concurrent use; Create must fail with ErrUsernameTaken on duplicates and
+// lookups of unknown users with ErrUserNotFound.
+type CredentialStore interface {
+	Create(u User) error
+	GetByUsername(username string) (User, error)
+	Update(u User) error
+}
+
+type InMemoryCredentialStore struct {
+	mu    sync.RWMutex
+	users map[string]User
+}
+
+func NewInMemoryCredentialStore() *InMemoryCredentialStore {
+	return &InMemoryCredentialStore{users: make(map[string]User)}
+}
+
+func (s *InMemoryCredentialStore) Create(u User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.users[u.Username]; ok {
+		return ErrUsernameTaken
+	}
+	s.users[u.Username] = u
+	return nil
+}
+
+func (s *InMemoryCredentialStore) GetByUsername(username string) (User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	u, ok := s.users[username]
+	if !ok {
+		return User{}, ErrUserNotFound
+	}
+	return u, nil
+}
+
+func (s *InMemoryCredentialStore) Update(u User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.users[u.Username]; !ok {
+		return ErrUserNotFound
+	}
+	s.users[u.Username] = u
+	return nil
+}
@@ -0,0 +1,268 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const minRSABits = 2048
+
+var ErrUnknownKey = errors.New("token signed with an unknown key")
+
+// SigningKey is one key of a KeySet. Keys loaded from a public key only
+// verify; HMAC keys use the secret for both.
+type SigningKey struct {
+	ID      string
+	Method  jwt.SigningMethod
+	private interface{}
+	public  interface{}
+}
+
+func (k *SigningKey) CanSign() bool { return k.private != nil }
+
+// NewHMACKey wraps a shared secret. It never shows up in the JWKS.
+func NewHMACKey(kid string, secret []byte) *SigningKey {
+	return &SigningKey{ID: kid, Method: jwt.SigningMethodHS256, private: secret, public: secret}
+}
+
+func LoadKeyFile(path, kid string) (*SigningKey, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	k, err := ParsePEMKey(raw, kid)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return k, nil
+}
+
+// ParsePEMKey accepts RSA keys (PKCS#1 or PKCS#8/PKIX) and Ed25519 keys
+// (PKCS#8/PKIX). An empty kid defaults to the RFC 7638 thumbprint, which
+// stays stable for the same key across restarts.
+func ParsePEMKey(data []byte, kid string) (*SigningKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+	var (
+		key interface{}
+		err error
+	)
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		key, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		key, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return NewKey(key, kid)
+}
+
+// NewKey wraps an RSA or Ed25519 private or public key.
+func NewKey(key interface{}, kid string) (*SigningKey, error) {
+	k := &SigningKey{ID: kid}
+	switch key := key.(type) {
+	case *rsa.PrivateKey:
+		k.Method, k.private, k.public = jwt.SigningMethodRS256, key, &key.PublicKey
+	case *rsa.PublicKey:
+		k.Method, k.public = jwt.SigningMethodRS256, key
+	case ed25519.PrivateKey:
+		k.Method, k.private, k.public = jwt.SigningMethodEdDSA, key, key.Public()
+	case ed25519.PublicKey:
+		k.Method, k.public = jwt.SigningMethodEdDSA, key
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", key)
+	}
+	if pub, ok := k.public.(*rsa.PublicKey); ok && pub.N.BitLen() < minRSABits {
+		return nil, fmt.Errorf("RSA keys must be at least %d bits", minRSABits)
+	}
+	if k.ID == "" {
+		k.ID = thumbprint(k.jwk())
+	}
+	return k, nil
+}
+
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid,omitempty"`
+	Use       string `json:"use,omitempty"`
+	Algorithm string `json:"alg,omitempty"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+	Curve     string `json:"crv,omitempty"`
+	X         string `json:"x,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+func (k *SigningKey) jwk() JWK {
+	b64 := base64.RawURLEncoding.EncodeToString
+	switch pub := k.public.(type) {
+	case *rsa.PublicKey:
+		return JWK{KeyType: "RSA", N: b64(pub.N.Bytes()), E: b64(big.NewInt(int64(pub.E)).Bytes())}
+	case ed25519.PublicKey:
+		return JWK{KeyType: "OKP", Curve: "Ed25519", X: b64(pub)}
+	}
+	return JWK{}
+}
+
+// Key turns a published JWK back into a verification key.
+func (j JWK) Key() (*SigningKey, error) {
+	b64 := base64.RawURLEncoding.DecodeString
+	var key interface{}
+	switch {
+	case j.KeyType == "RSA":
+		n, err := b64(j.N)
+		if err != nil {
+			return nil, fmt.Errorf("jwk %q: bad n: %w", j.KeyID, err)
+		}
+		e, err := b64(j.E)
+		if err != nil || len(e) == 0 || len(e) > 4 {
+			return nil, fmt.Errorf("jwk %q: bad e", j.KeyID)
+		}
+		key = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+	case j.KeyType == "OKP" && j.Curve == "Ed25519":
+		x, err := b64(j.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("jwk %q: bad x", j.KeyID)
+		}
+		key = ed25519.PublicKey(x)
+	default:
+		return nil, fmt.Errorf("jwk %q: unsupported key type %s %s", j.KeyID, j.KeyType, j.Curve)
+	}
+	k, err := NewKey(key, j.KeyID)
+	if err != nil {
+		return nil, err
+	}
+	if j.Algorithm != "" && j.Algorithm != k.Method.Alg() {
+		return nil, fmt.Errorf("jwk %q: algorithm %s does not match key type", j.KeyID, j.Algorithm)
+	}
+	return k, nil
+}
+
+// thumbprint hashes only the required members, which encoding/json already
+// emits in the lexicographic order RFC 7638 asks for.
+func thumbprint(j JWK) string {
+	var members interface{}
+	switch j.KeyType {
+	case "RSA":
+		members = struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{j.E, j.KeyType, j.N}
+	default:
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{j.Curve, j.KeyType, j.X}
+	}
+	raw, _ := json.Marshal(members)
+	sum := sha256.Sum256(raw)
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// KeySet signs with one key and verifies with any of its keys, picked by
+// the token's kid. Rotating is a matter of starting a new set with a new
+// signing key and the old one kept for verification until its tokens expire.
+type KeySet struct {
+	signing *SigningKey
+	keys    map[string]*SigningKey
+	order   []*SigningKey
+	methods []string
+}
+
+func NewKeySet(signing *SigningKey, verification ...*SigningKey) (*KeySet, error) {
+	if signing == nil || !signing.CanSign() {
+		return nil, errors.New("signing key must include a private key")
+	}
+	ks := &KeySet{signing: signing, keys: make(map[string]*SigningKey)}
+	for _, k := range append([]*SigningKey{signing}, verification...) {
+		if _, dup := ks.keys[k.ID]; dup {
+			return nil, fmt.Errorf("duplicate key id %q", k.ID)
+		}
+		ks.keys[k.ID] = k
+		ks.order = append(ks.order, k)
+		ks.addMethod(k.Method.Alg())
+	}
+	return ks, nil
+}
+
+func (ks *KeySet) addMethod(alg string) {
+	for _, m := range ks.methods {
+		if m == alg {
+			return
+		}
+	}
+	ks.methods = append(ks.methods, alg)
+}
+
+func (ks *KeySet) Sign(claims jwt.Claims) (string, error) {
+	t := jwt.NewWithClaims(ks.signing.Method, claims)
+	if ks.signing.ID != "" {
+		t.Header["kid"] = ks.signing.ID
+	}
+	return t.SignedString(ks.signing.private)
+}
+
+// Parse verifies the token against the key named by its kid; tokens
+// without one are checked against the key with an empty ID, which is how
+// tokens from before key IDs existed keep working.
+func (ks *KeySet) Parse(token string) (jwt.MapClaims, error) {
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(token, claims, ks.keyfunc, jwt.WithValidMethods(ks.methods))
+	if err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+func (ks *KeySet) keyfunc(t *jwt.Token) (interface{}, error) {
+	kid, _ := t.Header["kid"].(string)
+	k, ok := ks.keys[kid]
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+	// Never let the token pick the algorithm for a key.
+	if t.Method.Alg() != k.Method.Alg() {
+		return nil, fmt.Errorf("key %q does not use %s", kid, t.Method.Alg())
+	}
+	return k.public, nil
+}
+
+// JWKS lists the public halves of the asymmetric keys, signing key first.
+func (ks *KeySet) JWKS() JWKS {
+	set := JWKS{Keys: []JWK{}}
+	for _, k := range ks.order {
+		if k.Method == jwt.SigningMethodHS256 {
+			continue
+		}
+		j := k.jwk()
+		j.KeyID, j.Use, j.Algorithm = k.ID, "sig", k.Method.Alg()
+		set.Keys = append(set.Keys, j)
+	}
+	return set
+}
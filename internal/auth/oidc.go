@@ -0,0 +1,217 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	DefaultUserClaim          = "sub"
+	defaultMinRefreshInterval = time.Minute
+)
+
+var ErrMissingUserClaim = errors.New("token lacks the configured user claim")
+
+// IssuerConfig describes an external identity provider whose tokens are
+// accepted as they are. Keys come from JWKSFile or, failing that, from the
+// jwks_uri advertised at DiscoveryURL.
+type IssuerConfig struct {
+	Issuer       string
+	Audience     string
+	JWKSFile     string
+	DiscoveryURL string
+	// UserClaim names the string claim used as the user ID; "sub" by default.
+	UserClaim string
+	Leeway    time.Duration
+	// MinRefreshInterval throttles key reloads triggered by unknown kids.
+	MinRefreshInterval time.Duration
+	HTTPClient         *http.Client
+}
+
+// Verifier validates tokens from one external issuer. An unknown kid makes
+// it reload the keys, at most once per MinRefreshInterval, so the IdP can
+// rotate keys without a restart.
+type Verifier struct {
+	cfg     IssuerConfig
+	jwksURL string
+
+	mu        sync.RWMutex
+	keys      map[string]*SigningKey
+	lastFetch time.Time
+}
+
+func NewVerifier(cfg IssuerConfig) (*Verifier, error) {
+	if cfg.JWKSFile == "" && cfg.DiscoveryURL == "" {
+		return nil, errors.New("issuer needs a JWKS file or a discovery URL")
+	}
+	if cfg.UserClaim == "" {
+		cfg.UserClaim = DefaultUserClaim
+	}
+	if cfg.MinRefreshInterval <= 0 {
+		cfg.MinRefreshInterval = defaultMinRefreshInterval
+	}
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = &http.Client{Timeout: 10 * time.Second}
+	}
+	v := &Verifier{cfg: cfg}
+
+	if cfg.JWKSFile == "" {
+		var doc struct {
+			Issuer  string `json:"issuer"`
+			JWKSURI string `json:"jwks_uri"`
+		}
+		if err := v.getJSON(cfg.DiscoveryURL, &doc); err != nil {
+			return nil, fmt.Errorf("discovery: %w", err)
+		}
+		if v.cfg.Issuer == "" {
+			v.cfg.Issuer = doc.Issuer
+		}
+		if doc.Issuer != v.cfg.Issuer {
+			return nil, fmt.Errorf("discovery document is for issuer %q, expected %q", doc.Issuer, v.cfg.Issuer)
+		}
+		if doc.JWKSURI == "" {
+			return nil, errors.New("discovery document has no jwks_uri")
+		}
+		v.jwksURL = doc.JWKSURI
+	}
+	if v.cfg.Issuer == "" {
+		return nil, errors.New("issuer must be configured")
+	}
+	if err := v.reload(); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func (v *Verifier) Issuer() string { return v.cfg.Issuer }
+
+// Issues reports whether the token claims to come from this issuer. It does
+// not verify anything; it only decides which verifier to hand the token to.
+func (v *Verifier) Issues(token string) bool {
+	claims := jwt.MapClaims{}
+	if _, _, err := jwt.NewParser().ParseUnverified(token, claims); err != nil {
+		return false
+	}
+	iss, _ := claims["iss"].(string)
+	return iss == v.cfg.Issuer
+}
+
+// Verify checks signature, iss, aud, exp and nbf and returns the user ID
+// from the configured claim.
+func (v *Verifier) Verify(token string) (string, error) {
+	opts := []jwt.ParserOption{
+		jwt.WithIssuer(v.cfg.Issuer),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(v.cfg.Leeway),
+		jwt.WithValidMethods([]string{"RS256", "EdDSA"}),
+	}
+	if v.cfg.Audience != "" {
+		opts = append(opts, jwt.WithAudience(v.cfg.Audience))
+	}
+	claims := jwt.MapClaims{}
+	if _, err := jwt.ParseWithClaims(token, claims, v.keyfunc, opts...); err != nil {
+		return "", err
+	}
+	userID, _ := claims[v.cfg.UserClaim].(string)
+	if userID == "" {
+		return "", ErrMissingUserClaim
+	}
+	return userID, nil
+}
+
+func (v *Verifier) keyfunc(t *jwt.Token) (interface{}, error) {
+	kid, _ := t.Header["kid"].(string)
+	k, err := v.key(kid)
+	if err != nil {
+		return nil, err
+	}
+	if t.Method.Alg() != k.Method.Alg() {
+		return nil, fmt.Errorf("key %q does not use %s", kid, t.Method.Alg())
+	}
+	return k.public, nil
+}
+
+func (v *Verifier) key(kid string) (*SigningKey, error) {
+	v.mu.RLock()
+	k, ok := v.keys[kid]
+	v.mu.RUnlock()
+	if ok {
+		return k, nil
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if k, ok := v.keys[kid]; ok {
+		return k, nil
+	}
+	if time.Since(v.lastFetch) < v.cfg.MinRefreshInterval {
+		return nil, ErrUnknownKey
+	}
+	if err := v.reloadLocked(); err != nil {
+		return nil, err
+	}
+	if k, ok := v.keys[kid]; ok {
+		return k, nil
+	}
+	return nil, ErrUnknownKey
+}
+
+func (v *Verifier) reload() error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.reloadLocked()
+}
+
+func (v *Verifier) reloadLocked() error {
+	v.lastFetch = time.Now()
+
+	var set JWKS
+	if v.cfg.JWKSFile != "" {
+		raw, err := os.ReadFile(v.cfg.JWKSFile)
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal(raw, &set); err != nil {
+			return fmt.Errorf("%s: %w", v.cfg.JWKSFile, err)
+		}
+	} else if err := v.getJSON(v.jwksURL, &set); err != nil {
+		return fmt.Errorf("fetch jwks: %w", err)
+	}
+
+	keys := make(map[string]*SigningKey, len(set.Keys))
+	for _, j := range set.Keys {
+		if j.Use != "" && j.Use != "sig" {
+			continue
+		}
+		k, err := j.Key()
+		if err != nil {
+			// One key we cannot use should not take the others down.
+			continue
+		}
+		keys[j.KeyID] = k
+	}
+	if len(keys) == 0 {
+		return errors.New("issuer published no usable signing keys")
+	}
+	v.keys = keys
+	return nil
+}
+
+func (v *Verifier) getJSON(url string, out interface{}) error {
+	res, err := v.cfg.HTTPClient.Get(url)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", url, res.Status)
+	}
+	return json.NewDecoder(res.Body).Decode(out)
+}
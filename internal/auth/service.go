@@ -0,0 +1,169 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	DefaultMaxFailedAttempts = 5
+	DefaultLockoutDuration   = 15 * time.Minute
+
+	minPasswordLen = 8
+	// bcrypt ignores everything past 72 bytes.
+	maxPasswordLen = 72
+)
+
+var (
+	ErrInvalidCredentials = errors.New("invalid username or password")
+	ErrInvalidUsername    = errors.New("username must be 3-64 characters of letters, digits, '.', '_' or '-'")
+	ErrInvalidPassword    = fmt.Errorf("password must be %d-%d bytes long", minPasswordLen, maxPasswordLen)
+)
+
+// LockedError is returned while an account is locked out after too many
+// failed logins.
+type LockedError struct {
+	Until time.Time
+}
+
+func (e *LockedError) Error() string {
+	return "account locked until " + e.Until.UTC().Format(time.RFC3339)
+}
+
+var usernamePattern = regexp.MustCompile(`^[A-Za-z0-9._-]{3,64}$`)
+
+type Service struct {
+	store       CredentialStore
+	cost        int
+	maxAttempts int
+	lockout     time.Duration
+
+	// mu serializes the read-modify-write of failure counters.
+	mu        sync.Mutex
+	dummyHash []byte
+}
+
+type Option func(*Service)
+
+// WithLockout locks an account for d after maxAttempts consecutive failed
+// logins. A maxAttempts of zero disables lockout.
+func WithLockout(maxAttempts int, d time.Duration) Option {
+	return func(s *Service) {
+		s.maxAttempts = maxAttempts
+		s.lockout = d
+	}
+}
+
+// WithBcryptCost overrides bcrypt.DefaultCost, mainly to keep tests fast.
+func WithBcryptCost(cost int) Option {
+	return func(s *Service) {
+		s.cost = cost
+	}
+}
+
+func NewService(store CredentialStore, opts ...Option) *Service {
+	s := &Service{
+		store:       store,
+		cost:        bcrypt.DefaultCost,
+		maxAttempts: DefaultMaxFailedAttempts,
+		lockout:     DefaultLockoutDuration,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	// Logins for unknown users still pay for one comparison, so response
+	// times do not reveal which usernames exist.
+	hash, err := bcrypt.GenerateFromPassword([]byte("not a real password"), s.cost)
+	if err != nil {
+		panic(err)
+	}
+	s.dummyHash = hash
+	return s
+}
+
+func (s *Service) Register(username, password string) (User, error) {
+	if !usernamePattern.MatchString(username) {
+		return User{}, ErrInvalidUsername
+	}
+	if len(password) < minPasswordLen || len(password) > maxPasswordLen {
+		return User{}, ErrInvalidPassword
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), s.cost)
+	if err != nil {
+		return User{}, err
+	}
+	u := User{Username: username, PasswordHash: hash, CreatedAt: time.Now().UTC()}
+	if err := s.store.Create(u); err != nil {
+		return User{}, err
+	}
+	return u, nil
+}
+
+// Login checks the password and maintains the lockout counters. A locked
+// account is refused even with the right password.
+func (s *Service) Login(username, password string) (User, error) {
+	u, err := s.store.GetByUsername(username)
+	if errors.Is(err, ErrUserNotFound) {
+		bcrypt.CompareHashAndPassword(s.dummyHash, []byte(password))
+		return User{}, ErrInvalidCredentials
+	}
+	if err != nil {
+		return User{}, err
+	}
+	if time.Now().Before(u.LockedUntil) {
+		return User{}, &LockedError{Until: u.LockedUntil}
+	}
+
+	match := bcrypt.CompareHashAndPassword(u.PasswordHash, []byte(password)) == nil
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u, err = s.store.GetByUsername(username)
+	if err != nil {
+		return User{}, err
+	}
+	if match {
+		if u.FailedAttempts > 0 || !u.LockedUntil.IsZero() {
+			u.FailedAttempts, u.LockedUntil = 0, time.Time{}
+			if err := s.store.Update(u); err != nil {
+				return User{}, err
+			}
+		}
+		return u, nil
+	}
+
+	u.FailedAttempts++
+	if s.maxAttempts > 0 && u.FailedAttempts >= s.maxAttempts {
+		u.FailedAttempts = 0
+		u.LockedUntil = time.Now().Add(s.lockout)
+	}
+	if err := s.store.Update(u); err != nil {
+		return User{}, err
+	}
+	return User{}, ErrInvalidCredentials
+}
+
+func (s *Service) User(username string) (User, error) {
+	return s.store.GetByUsername(username)
+}
+
+// SetRoles replaces the user's roles. They take effect with the next access
+// token, at the latest when the current one is refreshed.
+func (s *Service) SetRoles(username string, roles []string) (User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u, err := s.store.GetByUsername(username)
+	if err != nil {
+		return User{}, err
+	}
+	u.Roles = roles
+	if err := s.store.Update(u); err != nil {
+		return User{}, err
+	}
+	return u, nil
+}
@@ -0,0 +1,188 @@
+package auth
+
+import (
+	"crypto/rand"
+	"errors"
+	"sync"
+	"time"
+)
+
+const DefaultRefreshTTL = 7 * 24 * time.Hour
+
+var (
+	ErrRefreshTokenInvalid = errors.New("refresh token is invalid, expired or revoked")
+	ErrRefreshTokenReused  = errors.New("refresh token was already used; session revoked")
+)
+
+// RefreshToken is the server-side record of an issued refresh token. Every
+// login starts a family; rotation hands out the next token of the same
+// family, and each token may be spent once.
+type RefreshToken struct {
+	ID        string
+	FamilyID  string
+	UserID    string
+	ExpiresAt time.Time
+	Used      bool
+}
+
+// RefreshStore persists refresh tokens. Consume must be atomic: it marks the
+// token used and returns it as it was, so of two concurrent callers exactly
+// one sees Used == false. Unknown IDs fail with ErrRefreshTokenInvalid.
+type RefreshStore interface {
+	Save(t RefreshToken) error
+	Get(id string) (RefreshToken, error)
+	Consume(id string) (RefreshToken, error)
+	RevokeFamily(familyID string) error
+	FamilyRevoked(familyID string) (bool, error)
+}
+
+type InMemoryRefreshStore struct {
+	mu      sync.Mutex
+	tokens  map[string]RefreshToken
+	revoked map[string]time.Time
+	saves   int
+}
+
+func NewInMemoryRefreshStore() *InMemoryRefreshStore {
+	return &InMemoryRefreshStore{
+		tokens:  make(map[string]RefreshToken),
+		revoked: make(map[string]time.Time),
+	}
+}
+
+const sweepEvery = 1000
+
+func (s *InMemoryRefreshStore) Save(t RefreshToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokens[t.ID] = t
+	if s.saves++; s.saves%sweepEvery == 0 {
+		s.sweep(time.Now())
+	}
+	return nil
+}
+
+// sweep drops expired tokens, and revocations that outlived every token of
+// their family.
+func (s *InMemoryRefreshStore) sweep(now time.Time) {
+	for id, t := range s.tokens {
+		if now.After(t.ExpiresAt) {
+			delete(s.tokens, id)
+		}
+	}
+	for family, until := range s.revoked {
+		if now.After(until) {
+			delete(s.revoked, family)
+		}
+	}
+}
+
+func (s *InMemoryRefreshStore) Get(id string) (RefreshToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.tokens[id]
+	if !ok {
+		return RefreshToken{}, ErrRefreshTokenInvalid
+	}
+	return t, nil
+}
+
+func (s *InMemoryRefreshStore) Consume(id string) (RefreshToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.tokens[id]
+	if !ok {
+		return RefreshToken{}, ErrRefreshTokenInvalid
+	}
+	used := t
+	used.Used = true
+	s.tokens[id] = used
+	return t, nil
+}
+
+func (s *InMemoryRefreshStore) RevokeFamily(familyID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	until := s.revoked[familyID]
+	for _, t := range s.tokens {
+		if t.FamilyID == familyID && t.ExpiresAt.After(until) {
+			until = t.ExpiresAt
+		}
+	}
+	s.revoked[familyID] = until
+	return nil
+}
+
+func (s *InMemoryRefreshStore) FamilyRevoked(familyID string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.revoked[familyID]
+	return ok, nil
+}
+
+type Sessions struct {
+	store RefreshStore
+	ttl   time.Duration
+}
+
+func NewSessions(store RefreshStore, ttl time.Duration) *Sessions {
+	if ttl <= 0 {
+		ttl = DefaultRefreshTTL
+	}
+	return &Sessions{store: store, ttl: ttl}
+}
+
+// Start opens a new session for userID and returns its first refresh token.
+func (s *Sessions) Start(userID string) (RefreshToken, error) {
+	return s.issue(userID, rand.Text())
+}
+
+func (s *Sessions) issue(userID, familyID string) (RefreshToken, error) {
+	t := RefreshToken{ID: rand.Text(), FamilyID: familyID, UserID: userID, ExpiresAt: time.Now().Add(s.ttl)}
+	if err := s.store.Save(t); err != nil {
+		return RefreshToken{}, err
+	}
+	return t, nil
+}
+
+// Rotate spends userID's refresh token and returns its successor. A token of
+// another user is rejected before it is spent. Presenting a token that was
+// already spent means a copy is in someone else's hands, so the whole family
+// is revoked and neither party can continue.
+func (s *Sessions) Rotate(id, userID string) (RefreshToken, error) {
+	if t, err := s.store.Get(id); err != nil {
+		return RefreshToken{}, err
+	} else if t.UserID != userID {
+		return RefreshToken{}, ErrRefreshTokenInvalid
+	}
+	old, err := s.store.Consume(id)
+	if err != nil {
+		return RefreshToken{}, err
+	}
+	if old.Used {
+		if err := s.store.RevokeFamily(old.FamilyID); err != nil {
+			return RefreshToken{}, err
+		}
+		return RefreshToken{}, ErrRefreshTokenReused
+	}
+	if time.Now().After(old.ExpiresAt) {
+		return RefreshToken{}, ErrRefreshTokenInvalid
+	}
+	revoked, err := s.store.FamilyRevoked(old.FamilyID)
+	if err != nil {
+		return RefreshToken{}, err
+	}
+	if revoked {
+		return RefreshToken{}, ErrRefreshTokenInvalid
+	}
+	return s.issue(old.UserID, old.FamilyID)
+}
+
+// Revoke ends the session the refresh token belongs to.
+func (s *Sessions) Revoke(id string) error {
+	t, err := s.store.Get(id)
+	if err != nil {
+		return err
+	}
+	return s.store.RevokeFamily(t.FamilyID)
+}
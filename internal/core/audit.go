@@ -0,0 +1,42 @@
+package core
+
+import (
+	"log"
+	"time"
+
+	"github.com/Zisimopoulou/platform-go-challenge/internal/models"
+)
+
+// AuditEvent records one access decision about another user's data.
+type AuditEvent struct {
+	Time     time.Time
+	Actor    string
+	Roles    []Role
+	Owner    string
+	Action   Action
+	Resource string
+	// Share is the permission of the share grant that let the actor in, if
+	// it was one.
+	Share   models.Permission
+	Allowed bool
+}
+
+type AuditLog interface {
+	Record(e AuditEvent)
+}
+
+type AuditLogFunc func(e AuditEvent)
+
+func (f AuditLogFunc) Record(e AuditEvent) { f(e) }
+
+// WithAuditLog sends audit events to a instead of the standard logger.
+func WithAuditLog(a AuditLog) Option {
+	return func(s *Service) {
+		s.audit = a
+	}
+}
+
+var stdAuditLog = AuditLogFunc(func(e AuditEvent) {
+	log.Printf("audit: actor=%s roles=%v owner=%s action=%s resource=%q share=%s allowed=%t",
+		e.Actor, e.Roles, e.Owner, e.Action, e.Resource, e.Share, e.Allowed)
+})
@@ -0,0 +1,106 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/Zisimopoulou/platform-go-challenge/internal/data"
+	"github.com/Zisimopoulou/platform-go-challenge/internal/models"
+	"github.com/Zisimopoulou/platform-go-challenge/internal/validation"
+)
+
+const MaxBatchSize = 1000
+
+var (
+	ErrEmptyBatch    = errors.New("batch must contain at least one operation")
+	ErrBatchTooLarge = fmt.Errorf("batch cannot exceed %d operations", MaxBatchSize)
+)
+
+// BatchItemResult is the outcome of one operation, in request order.
+// Favorite is nil for deletes and failures.
+type BatchItemResult struct {
+	FavoriteID string
+	Favorite   *models.Favorite
+	Err        error
+}
+
+// BatchFavorites validates every operation up front. Invalid ones fail on
+// their own in a non-atomic batch; in an atomic batch they abort it before the
+// store is touched.
+func (s *Service) BatchFavorites(userID string, req models.BatchRequest) ([]BatchItemResult, error) {
+	if userID == "" {
+		return nil, errors.New("user ID cannot be empty")
+	}
+	if len(req.Operations) == 0 {
+		return nil, ErrEmptyBatch
+	}
+	if len(req.Operations) > MaxBatchSize {
+		return nil, ErrBatchTooLarge
+	}
+
+	results := make([]BatchItemResult, len(req.Operations))
+	ops := make([]data.BatchOp, 0, len(req.Operations))
+	positions := make([]int, 0, len(req.Operations))
+	invalid := false
+	for i, o := range req.Operations {
+		results[i].FavoriteID = o.ID
+		op, err := batchOp(o)
+		if err != nil {
+			results[i].Err = err
+			invalid = true
+			continue
+		}
+		ops = append(ops, op)
+		positions = append(positions, i)
+	}
+	if invalid && req.Atomic {
+		for i := range results {
+			if results[i].Err == nil {
+				results[i].Err = data.ErrBatchAborted
+			}
+		}
+		return results, nil
+	}
+
+	stored, err := s.store.Batch(userID, ops, req.Atomic)
+	if err != nil {
+		return nil, err
+	}
+	for j, r := range stored {
+		i := positions[j]
+		if r.Err != nil {
+			results[i].Err = r.Err
+			continue
+		}
+		results[i].FavoriteID = r.Asset.ID
+		if ops[j].Kind != data.BatchDelete {
+			results[i].Favorite = &models.Favorite{FavoriteID: r.Asset.ID, Asset: r.Asset}
+		}
+	}
+	return results, nil
+}
+
+func batchOp(o models.BatchOperation) (data.BatchOp, error) {
+	kind := data.BatchOpKind(o.Op)
+	switch kind {
+	case data.BatchAdd, data.BatchUpdate:
+		if o.Asset == nil {
+			return data.BatchOp{}, fmt.Errorf("%w: %s requires an asset", data.ErrInvalidBatchOp, o.Op)
+		}
+		if kind == data.BatchUpdate && o.ID == "" {
+			return data.BatchOp{}, fmt.Errorf("%w: update requires an id", data.ErrInvalidBatchOp)
+		}
+		asset := *o.Asset
+		if err := validation.ValidateAsset(&asset); err != nil {
+			return data.BatchOp{}, &InvalidAssetError{Err: err}
+		}
+		asset.ID = o.ID
+		return data.BatchOp{Kind: kind, Asset: asset, ExpectedVersion: o.Version}, nil
+	case data.BatchDelete:
+		if o.ID == "" {
+			return data.BatchOp{}, fmt.Errorf("%w: delete requires an id", data.ErrInvalidBatchOp)
+		}
+		return data.BatchOp{Kind: kind, Asset: models.RawAsset{ID: o.ID}, ExpectedVersion: o.Version}, nil
+	}
+	return data.BatchOp{}, fmt.Errorf("%w: unknown op %q", data.ErrInvalidBatchOp, o.Op)
+}
@@ -0,0 +1,73 @@
+package core
+
+import (
+	"errors"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/Zisimopoulou/platform-go-challenge/internal/models"
+)
+
+const maxCollectionNameLen = 100
+
+var ErrInvalidCollectionName = errors.New("collection name must be 1 to 100 characters")
+
+func collectionName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || utf8.RuneCountInString(name) > maxCollectionNameLen {
+		return "", ErrInvalidCollectionName
+	}
+	return name, nil
+}
+
+func (s *Service) CreateCollection(userID, name string) (models.Collection, error) {
+	name, err := collectionName(name)
+	if err != nil {
+		return models.Collection{}, err
+	}
+	return s.store.CreateCollection(userID, name)
+}
+
+func (s *Service) GetCollection(userID, collID string) (models.Collection, error) {
+	return s.store.GetCollection(userID, collID)
+}
+
+func (s *Service) ListCollections(userID string) ([]models.Collection, error) {
+	return s.store.ListCollections(userID)
+}
+
+func (s *Service) RenameCollection(userID, collID, name string) (models.Collection, error) {
+	name, err := collectionName(name)
+	if err != nil {
+		return models.Collection{}, err
+	}
+	return s.store.RenameCollection(userID, collID, name)
+}
+
+// ReorderCollections takes every collection ID of the user, in the new order.
+func (s *Service) ReorderCollections(userID string, collIDs []string) ([]models.Collection, error) {
+	return s.store.ReorderCollections(userID, collIDs)
+}
+
+// DeleteCollection removes the collection only; its favorites stay.
+func (s *Service) DeleteCollection(userID, collID string) error {
+	return s.store.DeleteCollection(userID, collID)
+}
+
+func (s *Service) AddToCollection(userID, collID, favID string) error {
+	return s.store.AddToCollection(userID, collID, favID)
+}
+
+func (s *Service) RemoveFromCollection(userID, collID, favID string) error {
+	return s.store.RemoveFromCollection(userID, collID, favID)
+}
+
+// ListCollectionFavorites pages through a collection exactly like
+// ListFavorites, with every filter and cursor option.
+func (s *Service) ListCollectionFavorites(userID, collID string, p ListParams) (*models.PaginatedFavorites, error) {
+	if _, err := s.store.GetCollection(userID, collID); err != nil {
+		return nil, err
+	}
+	p.Filter.Collection = collID
+	return s.ListFavorites(userID, p)
+}
@@ -0,0 +1,99 @@
+package core
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/Zisimopoulou/platform-go-challenge/internal/data"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+type cursorDirection string
+
+const (
+	cursorNext cursorDirection = "next"
+	cursorPrev cursorDirection = "prev"
+)
+
+// A cursor is only meaningful for the ordering and filters it was issued
+// under, so it records the sort and a fingerprint of the filter.
+type cursor struct {
+	Direction   cursorDirection `json:"d"`
+	CreatedAt   int64           `json:"t"`
+	Description string          `json:"ds,omitempty"`
+	ID          string          `json:"id"`
+	Sort        data.SortField  `json:"s,omitempty"`
+	Ascending   bool            `json:"a,omitempty"`
+	Filter      string          `json:"f,omitempty"`
+}
+
+func (c cursor) keyset() data.Keyset {
+	return data.Keyset{CreatedAt: time.Unix(0, c.CreatedAt).UTC(), Description: c.Description, ID: c.ID}
+}
+
+func (c cursor) matches(p ListParams) bool {
+	return c.Sort == p.Sort && c.Ascending == p.Ascending && c.Filter == filterFingerprint(p.Filter)
+}
+
+func filterFingerprint(f data.ListFilter) string {
+	if f.IsZero() {
+		return ""
+	}
+	raw, _ := json.Marshal(f)
+	sum := sha256.Sum256(raw)
+	return base64.RawURLEncoding.EncodeToString(sum[:9])
+}
+
+// Cursors are opaque to clients: base64url(JSON) followed by an HMAC-SHA256
+// of that payload, so a tampered or hand-built cursor is rejected.
+func (s *Service) encodeCursor(dir cursorDirection, key data.Keyset, p ListParams) string {
+	c := cursor{
+		Direction: dir,
+		CreatedAt: key.CreatedAt.UnixNano(),
+		ID:        key.ID,
+		Sort:      p.Sort,
+		Ascending: p.Ascending,
+		Filter:    filterFingerprint(p.Filter),
+	}
+	if p.Sort == data.SortByDescription {
+		c.Description = key.Description
+	}
+	raw, _ := json.Marshal(c)
+	payload := base64.RawURLEncoding.EncodeToString(raw)
+	return payload + "." + base64.RawURLEncoding.EncodeToString(s.signCursor(payload))
+}
+
+func (s *Service) decodeCursor(token string) (cursor, error) {
+	payload, sig, ok := strings.Cut(token, ".")
+	if !ok {
+		return cursor{}, ErrInvalidCursor
+	}
+	gotSig, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil || !hmac.Equal(gotSig, s.signCursor(payload)) {
+		return cursor{}, ErrInvalidCursor
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return cursor{}, ErrInvalidCursor
+	}
+	var c cursor
+	if err := json.Unmarshal(raw, &c); err != nil || c.ID == "" {
+		return cursor{}, ErrInvalidCursor
+	}
+	if c.Direction != cursorNext && c.Direction != cursorPrev {
+		return cursor{}, ErrInvalidCursor
+	}
+	return c, nil
+}
+
+func (s *Service) signCursor(payload string) []byte {
+	mac := hmac.New(sha256.New, s.cursorSecret)
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,93 @@
+package core
+
+import (
+	"encoding/json"
+
+	"github.com/Zisimopoulou/platform-go-challenge/internal/models"
+	"github.com/Zisimopoulou/platform-go-challenge/internal/patch"
+	"github.com/Zisimopoulou/platform-go-challenge/internal/validation"
+)
+
+// RevisionDiff is the JSON Patch that turns revision From of a favorite into
+// revision To.
+type RevisionDiff struct {
+	From    int64             `json:"from"`
+	To      int64             `json:"to"`
+	Changes []patch.Operation `json:"changes"`
+}
+
+// FavoriteHistory pages through a favorite's revisions, newest first.
+func (s *Service) FavoriteHistory(userID, favID string, limit, offset int) (*models.PaginatedRevisions, error) {
+	if limit <= 0 {
+		limit = 50
+	}
+	limit = min(limit, 100)
+	offset = max(offset, 0)
+	revs, total, err := s.store.History(userID, favID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	return &models.PaginatedRevisions{
+		Revisions:  revs,
+		TotalCount: total,
+		Limit:      limit,
+		Offset:     offset,
+		HasMore:    offset+len(revs) < total,
+	}, nil
+}
+
+func (s *Service) FavoriteRevision(userID, favID string, version int64) (models.Revision, error) {
+	return s.store.Revision(userID, favID, version)
+}
+
+// DiffRevisions compares the fields a client can change. A zero to compares
+// against the latest revision.
+func (s *Service) DiffRevisions(userID, favID string, from, to int64) (*RevisionDiff, error) {
+	if to == 0 {
+		latest, _, err := s.store.History(userID, favID, 1, 0)
+		if err != nil {
+			return nil, err
+		}
+		if len(latest) > 0 {
+			to = latest[0].Version
+		}
+	}
+	a, err := s.store.Revision(userID, favID, from)
+	if err != nil {
+		return nil, err
+	}
+	b, err := s.store.Revision(userID, favID, to)
+	if err != nil {
+		return nil, err
+	}
+	docA, err := json.Marshal(patchableOf(a.Asset))
+	if err != nil {
+		return nil, err
+	}
+	docB, err := json.Marshal(patchableOf(b.Asset))
+	if err != nil {
+		return nil, err
+	}
+	ops, err := patch.Diff(docA, docB)
+	if err != nil {
+		return nil, err
+	}
+	return &RevisionDiff{From: from, To: to, Changes: ops}, nil
+}
+
+// RevertFavorite writes an earlier revision back as a new one. The revision
+// is validated again, since the rules may have changed since it was stored.
+func (s *Service) RevertFavorite(userID, favID string, version, expectedVersion int64) (*models.Favorite, error) {
+	rev, err := s.store.Revision(userID, favID, version)
+	if err != nil {
+		return nil, err
+	}
+	if err := validation.ValidateAsset(&rev.Asset); err != nil {
+		return nil, &InvalidAssetError{Err: err}
+	}
+	asset, err := s.store.Revert(userID, favID, version, expectedVersion)
+	if err != nil {
+		return nil, err
+	}
+	return &models.Favorite{FavoriteID: asset.ID, Asset: asset}, nil
+}
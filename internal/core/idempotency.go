@@ -0,0 +1,124 @@
+package core
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/Zisimopoulou/platform-go-challenge/internal/models"
+	"github.com/Zisimopoulou/platform-go-challenge/internal/validation"
+)
+
+const DefaultIdempotencyWindow = 24 * time.Hour
+
+var ErrIdempotencyConflict = errors.New("idempotency key was already used with a different request")
+
+// WithIdempotencyWindow sets how long an Idempotency-Key is remembered.
+func WithIdempotencyWindow(d time.Duration) Option {
+	return func(s *Service) {
+		s.idempotency.window = d
+	}
+}
+
+type idempotencyKey struct {
+	userID string
+	key    string
+}
+
+// An entry is created when the first request with a key starts; done is
+// closed once it has finished. Failed requests drop their entry so the key
+// can be retried.
+type idempotencyEntry struct {
+	key         idempotencyKey
+	fingerprint [sha256.Size]byte
+	expires     time.Time
+	done        chan struct{}
+	favID       string
+	err         error
+}
+
+type idempotencyCache struct {
+	mu      sync.Mutex
+	window  time.Duration
+	entries map[idempotencyKey]*idempotencyEntry
+	// queue holds entries in creation order, which is also expiry order
+	// since the window is fixed.
+	queue []*idempotencyEntry
+	now   func() time.Time
+}
+
+func newIdempotencyCache() *idempotencyCache {
+	return &idempotencyCache{
+		window:  DefaultIdempotencyWindow,
+		entries: make(map[idempotencyKey]*idempotencyEntry),
+		now:     time.Now,
+	}
+}
+
+// reserve returns the live entry for key, or creates one and reports that
+// the caller is the one to run the request.
+func (c *idempotencyCache) reserve(key idempotencyKey, fingerprint [sha256.Size]byte) (*idempotencyEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	for len(c.queue) > 0 && !c.queue[0].expires.After(now) {
+		e := c.queue[0]
+		c.queue = c.queue[1:]
+		if c.entries[e.key] == e {
+			delete(c.entries, e.key)
+		}
+	}
+
+	if e, ok := c.entries[key]; ok {
+		return e, false
+	}
+	e := &idempotencyEntry{key: key, fingerprint: fingerprint, expires: now.Add(c.window), done: make(chan struct{})}
+	c.entries[key] = e
+	c.queue = append(c.queue, e)
+	return e, true
+}
+
+func (c *idempotencyCache) finish(e *idempotencyEntry, favID string, err error) {
+	c.mu.Lock()
+	e.favID, e.err = favID, err
+	if err != nil && c.entries[e.key] == e {
+		delete(c.entries, e.key)
+	}
+	c.mu.Unlock()
+	close(e.done)
+}
+
+// AddFavoriteIdempotent behaves like AddFavorite, except that a repeat of a
+// request with the same key within the window returns the original favorite
+// ID with replayed set instead of adding again. A key reused with a different
+// asset fails with ErrIdempotencyConflict.
+func (s *Service) AddFavoriteIdempotent(userID, key string, asset models.RawAsset) (string, bool, error) {
+	if err := validation.ValidateAsset(&asset); err != nil {
+		return "", false, err
+	}
+	body, err := json.Marshal(asset)
+	if err != nil {
+		return "", false, err
+	}
+	fingerprint := sha256.Sum256(body)
+
+	for {
+		entry, leader := s.idempotency.reserve(idempotencyKey{userID, key}, fingerprint)
+		if leader {
+			favID, err := s.store.Add(userID, asset)
+			s.idempotency.finish(entry, favID, err)
+			return favID, false, err
+		}
+		if entry.fingerprint != fingerprint {
+			return "", false, ErrIdempotencyConflict
+		}
+		<-entry.done
+		if entry.err == nil {
+			return entry.favID, true, nil
+		}
+		// The original attempt failed and released the key; run it again.
+	}
+}
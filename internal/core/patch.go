@@ -0,0 +1,141 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/Zisimopoulou/platform-go-challenge/internal/data"
+	"github.com/Zisimopoulou/platform-go-challenge/internal/models"
+	"github.com/Zisimopoulou/platform-go-challenge/internal/patch"
+	"github.com/Zisimopoulou/platform-go-challenge/internal/validation"
+)
+
+var ErrTypeChange = errors.New("type change rejected")
+
+type PatchFormat string
+
+const (
+	MergePatch PatchFormat = "merge"
+	JSONPatch  PatchFormat = "json"
+)
+
+// InvalidAssetError wraps a validation failure of the asset produced by a
+// patch, so callers can tell it apart from store errors.
+type InvalidAssetError struct {
+	Err error
+}
+
+func (e *InvalidAssetError) Error() string { return e.Err.Error() }
+func (e *InvalidAssetError) Unwrap() error { return e.Err }
+
+// patchableAsset is the document a patch is applied to: only the fields a
+// client may change. Anything else in the patched result is rejected.
+type patchableAsset struct {
+	Type        models.AssetType `json:"type"`
+	Description string           `json:"description"`
+	Tags        []string         `json:"tags,omitempty"`
+	Payload     interface{}      `json:"payload"`
+}
+
+func patchableOf(asset models.RawAsset) patchableAsset {
+	return patchableAsset{Type: asset.Type, Description: asset.Description, Tags: asset.Tags, Payload: asset.Payload}
+}
+
+const patchAttempts = 3
+
+// retryConflicts runs a read-modify-write again when another write got in
+// between, up to patchAttempts times. With an expected version the caller's
+// precondition is final, so the conflict is returned as is.
+func retryConflicts(expectedVersion int64, write func() (*models.Favorite, error)) (*models.Favorite, error) {
+	for attempt := 1; ; attempt++ {
+		fav, err := write()
+		if errors.Is(err, data.ErrVersionMismatch) && expectedVersion == data.AnyVersion && attempt < patchAttempts {
+			continue
+		}
+		return fav, err
+	}
+}
+
+// PatchFavorite applies the patch to the stored favorite and writes the result
+// back with a compare-and-swap on the version it was computed from. With an
+// expected version the caller's precondition is final; without one, a
+// concurrent write just means the patch is re-applied to the newer state.
+// The change is attributed to actor, who may be a user it is shared with.
+func (s *Service) PatchFavorite(actor, userID, favID string, format PatchFormat, patchDoc []byte, expectedVersion int64) (*models.Favorite, error) {
+	if userID == "" {
+		return nil, errors.New("user ID cannot be empty")
+	}
+	if favID == "" {
+		return nil, errors.New("favorite ID cannot be empty")
+	}
+	return retryConflicts(expectedVersion, func() (*models.Favorite, error) {
+		return s.patchOnce(actor, userID, favID, format, patchDoc, expectedVersion)
+	})
+}
+
+func (s *Service) patchOnce(actor, userID, favID string, format PatchFormat, patchDoc []byte, expectedVersion int64) (*models.Favorite, error) {
+	current, err := s.store.Get(userID, favID)
+	if err != nil {
+		return nil, err
+	}
+	if expectedVersion != data.AnyVersion && current.Version != expectedVersion {
+		return nil, data.ErrVersionMismatch
+	}
+
+	doc, err := json.Marshal(patchableOf(current))
+	if err != nil {
+		return nil, err
+	}
+
+	var patched []byte
+	switch format {
+	case MergePatch:
+		patched, err = patch.MergePatch(doc, patchDoc)
+	case JSONPatch:
+		patched, err = patch.ApplyJSONPatch(doc, patchDoc)
+	default:
+		return nil, fmt.Errorf("%w: unsupported format %q", patch.ErrInvalidPatch, format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// The payload is decoded once the patched type is known.
+	var result struct {
+		patchableAsset
+		Payload json.RawMessage `json:"payload"`
+	}
+	dec := json.NewDecoder(bytes.NewReader(patched))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&result); err != nil {
+		return nil, &InvalidAssetError{Err: fmt.Errorf("patched document is invalid: %v", err)}
+	}
+
+	updated := current
+	updated.Type = result.Type
+	updated.Description = result.Description
+	updated.Tags = result.Tags
+	updated.Payload = nil
+	updated.UpdatedBy = actor
+
+	if len(result.Payload) > 0 && string(result.Payload) != "null" {
+		updated.Payload, err = models.DecodePayload(result.Type, result.Payload, models.StrictPayloads())
+	}
+	if err == nil {
+		err = validation.ValidateAsset(&updated)
+	}
+	if err != nil {
+		if updated.Type != current.Type {
+			return nil, fmt.Errorf("%w: payload is not valid for type %q: %v", ErrTypeChange, updated.Type, err)
+		}
+		return nil, &InvalidAssetError{Err: err}
+	}
+
+	stored, err := s.store.Update(userID, updated, current.Version)
+	if err != nil {
+		return nil, err
+	}
+	return &models.Favorite{FavoriteID: stored.ID, Asset: stored}, nil
+}
@@ -0,0 +1,142 @@
+package core
+
+import (
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/Zisimopoulou/platform-go-challenge/internal/data"
+	"github.com/Zisimopoulou/platform-go-challenge/internal/models"
+)
+
+var (
+	ErrForbidden         = errors.New("access denied")
+	ErrInsufficientScope = errors.New("credential lacks the required scope")
+)
+
+type Role string
+
+const (
+	RoleUser            Role = "user"
+	RoleAdmin           Role = "admin"
+	RoleSupportReadOnly Role = "support-readonly"
+)
+
+func (r Role) Valid() bool {
+	return r == RoleUser || r == RoleAdmin || r == RoleSupportReadOnly
+}
+
+type Action string
+
+const (
+	ActionRead   Action = "read"
+	ActionWrite  Action = "write"
+	ActionDelete Action = "delete"
+	// ActionManage covers the admin endpoints, which belong to no user.
+	ActionManage Action = "manage"
+)
+
+// Scope limits what an API key may do, on top of its user's roles.
+type Scope string
+
+const (
+	ScopeFavoritesRead  Scope = "favorites:read"
+	ScopeFavoritesWrite Scope = "favorites:write"
+)
+
+func (s Scope) Valid() bool {
+	return s == ScopeFavoritesRead || s == ScopeFavoritesWrite
+}
+
+// actionScopes names the scope each action needs. ActionManage needs one
+// that no API key can hold.
+var actionScopes = map[Action]Scope{
+	ActionRead:   ScopeFavoritesRead,
+	ActionWrite:  ScopeFavoritesWrite,
+	ActionDelete: ScopeFavoritesWrite,
+}
+
+// Principal is whoever a request acts as: the token subject and its roles.
+// Scopes is nil for user tokens, which are not restricted by scope.
+type Principal struct {
+	UserID string
+	Roles  []Role
+	Scopes []Scope
+}
+
+// crossUserPolicy lists what each role may do to favorites it does not own.
+// Owners may do anything to their own favorites that their scopes allow.
+var crossUserPolicy = map[Role][]Action{
+	RoleAdmin:           {ActionRead, ActionDelete, ActionManage},
+	RoleSupportReadOnly: {ActionRead},
+}
+
+func (p Principal) scoped(action Action) bool {
+	return p.Scopes == nil || slices.Contains(p.Scopes, actionScopes[action])
+}
+
+func (p Principal) may(action Action) bool {
+	for _, r := range p.Roles {
+		if slices.Contains(crossUserPolicy[r], action) {
+			return true
+		}
+	}
+	return false
+}
+
+// Authorize decides whether p may perform action on owner's favorites.
+// Every decision about somebody else's data, allowed or not, is audited.
+func (s *Service) Authorize(p Principal, owner string, action Action, resource string) error {
+	return s.authorize(p, owner, "", action, resource)
+}
+
+// AuthorizeFavorite is Authorize for a single favorite, where share grants
+// can let users in that their roles would not.
+func (s *Service) AuthorizeFavorite(p Principal, owner, favID string, action Action, resource string) error {
+	return s.authorize(p, owner, favID, action, resource)
+}
+
+func (s *Service) authorize(p Principal, owner, favID string, action Action, resource string) error {
+	crossUser := owner != p.UserID || action == ActionManage
+	if !p.scoped(action) && !crossUser {
+		return ErrInsufficientScope
+	}
+	if !crossUser {
+		return nil
+	}
+
+	allowed := p.scoped(action) && p.may(action)
+	var via models.Permission
+	if !allowed && p.scoped(action) && favID != "" {
+		share, err := s.store.GetShare(owner, favID, p.UserID)
+		if err != nil && !errors.Is(err, data.ErrNotFound) {
+			return err
+		}
+		if err == nil && slices.Contains(sharePolicy[share.Permission], action) {
+			allowed, via = true, share.Permission
+		}
+	}
+	s.audit.Record(AuditEvent{
+		Time:     time.Now().UTC(),
+		Actor:    p.UserID,
+		Roles:    p.Roles,
+		Owner:    owner,
+		Action:   action,
+		Resource: resource,
+		Share:    via,
+		Allowed:  allowed,
+	})
+	if !allowed {
+		if !p.scoped(action) {
+			return ErrInsufficientScope
+		}
+		return ErrForbidden
+	}
+	return nil
+}
+
+// ListUsers lists the users that own favorites. Callers authorize with
+// ActionManage first.
+func (s *Service) ListUsers() ([]models.UserSummary, error) {
+	return s.store.Users()
+}
@@ -1,19 +1,56 @@
 package core
 
 import (
+	"crypto/rand"
 	"errors"
+	"fmt"
+	"strings"
 
 	"github.com/Zisimopoulou/platform-go-challenge/internal/data"
 	"github.com/Zisimopoulou/platform-go-challenge/internal/models"
 	"github.com/Zisimopoulou/platform-go-challenge/internal/validation"
 )
 
+var ErrEmptyQuery = errors.New("search query cannot be empty")
+
 type Service struct {
-	store data.Store
+	store        data.Store
+	cursorSecret []byte
+	idempotency  *idempotencyCache
+	audit        AuditLog
 }
 
-func NewService(s data.Store) *Service {
-	return &Service{store: s}
+type Option func(*Service)
+
+// WithCursorSecret sets the key used to sign pagination cursors. Without it a
+// random key is generated, so cursors do not survive a restart.
+func WithCursorSecret(secret []byte) Option {
+	return func(s *Service) {
+		s.cursorSecret = secret
+	}
+}
+
+func NewService(s data.Store, opts ...Option) *Service {
+	svc := &Service{store: s, idempotency: newIdempotencyCache(), audit: stdAuditLog}
+	for _, opt := range opts {
+		opt(svc)
+	}
+	if len(svc.cursorSecret) == 0 {
+		svc.cursorSecret = make([]byte, 32)
+		if _, err := rand.Read(svc.cursorSecret); err != nil {
+			panic(err)
+		}
+	}
+	return svc
+}
+
+type ListParams struct {
+	Limit     int
+	Offset    int
+	Cursor    string
+	Filter    data.ListFilter
+	Sort      data.SortField
+	Ascending bool
 }
 
 func (s *Service) AddFavorite(userID string, asset models.RawAsset) (string, error) {
@@ -23,11 +60,12 @@ func (s *Service) AddFavorite(userID string, asset models.RawAsset) (string, err
 	return s.store.Add(userID, asset)
 }
 
-func (s *Service) ListFavorites(userID string, limit, offset int) (*models.PaginatedFavorites, error) {
+func (s *Service) ListFavorites(userID string, p ListParams) (*models.PaginatedFavorites, error) {
 	if userID == "" {
 		return nil, errors.New("user ID cannot be empty")
 	}
 
+	limit, offset := p.Limit, p.Offset
 	if limit <= 0 {
 		limit = 50
 	}
@@ -38,42 +76,142 @@ func (s *Service) ListFavorites(userID string, limit, offset int) (*models.Pagin
 		offset = 0
 	}
 
-	favorites, totalCount, err := s.store.List(userID, limit, offset)
+	// One extra item tells us whether another page exists in the direction
+	// we are reading without a second query.
+	if p.Sort == "" {
+		p.Sort = data.SortByCreatedAt
+	}
+	q := data.ListQuery{
+		Limit:     limit + 1,
+		Offset:    offset,
+		Filter:    p.Filter,
+		Sort:      p.Sort,
+		Ascending: p.Ascending,
+	}
+	var dir cursorDirection
+	if p.Cursor != "" {
+		c, err := s.decodeCursor(p.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		if !c.matches(p) {
+			return nil, fmt.Errorf("%w: cursor was issued for a different sort or filter", ErrInvalidCursor)
+		}
+		key := c.keyset()
+		dir = c.Direction
+		offset = 0
+		if dir == cursorNext {
+			q.After = &key
+		} else {
+			q.Before = &key
+		}
+	}
+
+	favorites, totalCount, err := s.store.List(userID, q)
 	if err != nil {
 		return nil, err
 	}
 
-	return &models.PaginatedFavorites{
+	more := len(favorites) > limit
+	if more {
+		if dir == cursorPrev {
+			favorites = favorites[1:]
+		} else {
+			favorites = favorites[:limit]
+		}
+	}
+
+	var hasNext, hasPrev bool
+	switch dir {
+	case cursorNext:
+		hasNext, hasPrev = more, true
+	case cursorPrev:
+		hasNext, hasPrev = true, more
+	default:
+		hasNext, hasPrev = more, offset > 0
+	}
+
+	result := &models.PaginatedFavorites{
 		Favorites:  favorites,
 		TotalCount: totalCount,
 		Limit:      limit,
 		Offset:     offset,
-		HasMore:    (offset + limit) < totalCount,
-	}, nil
+		HasMore:    hasNext,
+	}
+	if len(favorites) > 0 {
+		if hasNext {
+			result.NextCursor = s.encodeCursor(cursorNext, data.KeysetOf(favorites[len(favorites)-1].Asset), p)
+		}
+		if hasPrev {
+			result.PrevCursor = s.encodeCursor(cursorPrev, data.KeysetOf(favorites[0].Asset), p)
+		}
+	}
+	return result, nil
 }
 
-func (s *Service) DeleteFavorite(userID, favID string) error {
+func (s *Service) SearchFavorites(userID, query string, limit int) (*models.SearchResults, error) {
 	if userID == "" {
-		return errors.New("user ID cannot be empty")
+		return nil, errors.New("user ID cannot be empty")
+	}
+	if strings.TrimSpace(query) == "" {
+		return nil, ErrEmptyQuery
+	}
+	if limit <= 0 {
+		limit = 20
+	}
+	if limit > 100 {
+		limit = 100
+	}
+
+	results, totalCount, err := s.store.Search(userID, query, limit)
+	if err != nil {
+		return nil, err
+	}
+	return &models.SearchResults{Query: query, Results: results, TotalCount: totalCount}, nil
+}
+
+func (s *Service) GetFavorite(userID, favID string) (*models.Favorite, error) {
+	if userID == "" {
+		return nil, errors.New("user ID cannot be empty")
 	}
 	if favID == "" {
-		return errors.New("favorite ID cannot be empty")
+		return nil, errors.New("favorite ID cannot be empty")
 	}
-	return s.store.Delete(userID, favID)
+	asset, err := s.store.Get(userID, favID)
+	if err != nil {
+		return nil, err
+	}
+	return &models.Favorite{FavoriteID: asset.ID, Asset: asset}, nil
 }
 
-func (s *Service) UpdateDescription(userID, favID, desc string) error {
+func (s *Service) DeleteFavorite(userID, favID string, expectedVersion int64) error {
 	if userID == "" {
 		return errors.New("user ID cannot be empty")
 	}
 	if favID == "" {
 		return errors.New("favorite ID cannot be empty")
 	}
+	return s.store.Delete(userID, favID, expectedVersion)
+}
+
+// UpdateDescription attributes the change to actor, who may be a user the
+// favorite is shared with.
+func (s *Service) UpdateDescription(actor, userID, favID, desc string, expectedVersion int64) (*models.Favorite, error) {
+	if userID == "" {
+		return nil, errors.New("user ID cannot be empty")
+	}
+	if favID == "" {
+		return nil, errors.New("favorite ID cannot be empty")
+	}
 	if desc == "" {
-		return errors.New("description cannot be empty")
+		return nil, errors.New("description cannot be empty")
 	}
 	if len(desc) > 500 {
-		return errors.New("description cannot exceed 500 characters")
+		return nil, errors.New("description cannot exceed 500 characters")
+	}
+	asset, err := s.store.UpdateDescription(actor, userID, favID, desc, expectedVersion)
+	if err != nil {
+		return nil, err
 	}
-	return s.store.UpdateDescription(userID, favID, desc)
+	return &models.Favorite{FavoriteID: asset.ID, Asset: asset}, nil
 }
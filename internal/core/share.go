@@ -0,0 +1,45 @@
+package core
+
+import (
+	"errors"
+
+	"github.com/Zisimopoulou/platform-go-challenge/internal/models"
+)
+
+var (
+	ErrInvalidPermission = errors.New("permission must be read or edit")
+	ErrShareWithOwner    = errors.New("cannot share a favorite with its owner")
+)
+
+// sharePolicy lists what each share permission lets the grantee do to the
+// shared favorite. Deleting it and managing its shares stay with the owner.
+var sharePolicy = map[models.Permission][]Action{
+	models.PermissionRead: {ActionRead},
+	models.PermissionEdit: {ActionRead, ActionWrite},
+}
+
+func (s *Service) ShareFavorite(ownerID, favID, userID string, perm models.Permission) (models.Share, error) {
+	if _, ok := sharePolicy[perm]; !ok {
+		return models.Share{}, ErrInvalidPermission
+	}
+	if userID == ownerID {
+		return models.Share{}, ErrShareWithOwner
+	}
+	return s.store.PutShare(models.Share{OwnerID: ownerID, FavoriteID: favID, UserID: userID, Permission: perm})
+}
+
+func (s *Service) UnshareFavorite(ownerID, favID, userID string) error {
+	return s.store.DeleteShare(ownerID, favID, userID)
+}
+
+func (s *Service) ListShares(ownerID, favID string) ([]models.Share, error) {
+	return s.store.ListShares(ownerID, favID)
+}
+
+func (s *Service) SharedWith(userID string) ([]models.SharedFavorite, error) {
+	return s.store.SharedWith(userID)
+}
+
+func (s *Service) GetShare(ownerID, favID, userID string) (models.Share, error) {
+	return s.store.GetShare(ownerID, favID, userID)
+}
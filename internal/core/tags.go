@@ -0,0 +1,63 @@
+package core
+
+import (
+	"slices"
+
+	"github.com/Zisimopoulou/platform-go-challenge/internal/data"
+	"github.com/Zisimopoulou/platform-go-challenge/internal/models"
+	"github.com/Zisimopoulou/platform-go-challenge/internal/validation"
+)
+
+func (s *Service) ListTags(userID string) ([]models.TagCount, error) {
+	return s.store.Tags(userID)
+}
+
+// TagFavorite adds and removes tags on a favorite. Like PatchFavorite it
+// writes with a compare-and-swap and, without an expected version, retries
+// on concurrent writes. Nothing is written when the tags do not change.
+func (s *Service) TagFavorite(userID, favID string, add, remove []string, expectedVersion int64) (*models.Favorite, error) {
+	add, err := validation.NormalizeTags(add)
+	if err != nil {
+		return nil, err
+	}
+	drop := make([]string, len(remove))
+	for i, tag := range remove {
+		if drop[i], err = validation.NormalizeTag(tag); err != nil {
+			return nil, err
+		}
+	}
+
+	return retryConflicts(expectedVersion, func() (*models.Favorite, error) {
+		return s.tagOnce(userID, favID, add, drop, expectedVersion)
+	})
+}
+
+func (s *Service) tagOnce(userID, favID string, add, remove []string, expectedVersion int64) (*models.Favorite, error) {
+	current, err := s.store.Get(userID, favID)
+	if err != nil {
+		return nil, err
+	}
+	if expectedVersion != data.AnyVersion && current.Version != expectedVersion {
+		return nil, data.ErrVersionMismatch
+	}
+
+	tags := slices.DeleteFunc(append(slices.Clone(current.Tags), add...), func(tag string) bool {
+		return slices.Contains(remove, tag)
+	})
+	tags, err = validation.NormalizeTags(tags)
+	if err != nil {
+		return nil, err
+	}
+	if slices.Equal(tags, current.Tags) {
+		return &models.Favorite{FavoriteID: current.ID, Asset: current}, nil
+	}
+
+	updated := current
+	updated.Tags = tags
+	updated.UpdatedBy = userID
+	stored, err := s.store.Update(userID, updated, current.Version)
+	if err != nil {
+		return nil, err
+	}
+	return &models.Favorite{FavoriteID: stored.ID, Asset: stored}, nil
+}
@@ -0,0 +1,77 @@
+package core
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/Zisimopoulou/platform-go-challenge/internal/models"
+)
+
+const (
+	DefaultTrashRetention     = 30 * 24 * time.Hour
+	DefaultTrashPurgeInterval = time.Hour
+)
+
+// ListTrash pages through the user's trashed favorites, most recently
+// deleted first.
+func (s *Service) ListTrash(userID string, limit, offset int) (*models.PaginatedFavorites, error) {
+	if limit <= 0 {
+		limit = 50
+	}
+	limit = min(limit, 100)
+	offset = max(offset, 0)
+	favorites, total, err := s.store.ListTrash(userID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	return &models.PaginatedFavorites{
+		Favorites:  favorites,
+		TotalCount: total,
+		Limit:      limit,
+		Offset:     offset,
+		HasMore:    offset+len(favorites) < total,
+	}, nil
+}
+
+func (s *Service) RestoreFavorite(userID, favID string) (*models.Favorite, error) {
+	asset, err := s.store.Restore(userID, favID)
+	if err != nil {
+		return nil, err
+	}
+	return &models.Favorite{FavoriteID: asset.ID, Asset: asset}, nil
+}
+
+// PurgeFavorite permanently deletes a favorite that is in the trash.
+func (s *Service) PurgeFavorite(userID, favID string) error {
+	return s.store.Purge(userID, favID)
+}
+
+// PurgeTrash permanently deletes favorites that have been in the trash for
+// longer than retention.
+func (s *Service) PurgeTrash(retention time.Duration) (int, error) {
+	return s.store.PurgeTrash(time.Now().Add(-retention))
+}
+
+// RunTrashPurger calls PurgeTrash every interval until ctx is done. A zero
+// interval disables purging.
+func (s *Service) RunTrashPurger(ctx context.Context, retention, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			n, err := s.PurgeTrash(retention)
+			if err != nil {
+				log.Printf("trash: purge failed: %v", err)
+			} else if n > 0 {
+				log.Printf("trash: purged %d favorites deleted more than %s ago", n, retention)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,313 @@
+package data
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"github.com/Zisimopoulou/platform-go-challenge/internal/models"
+)
+
+const (
+	journalFileName  = "journal.log"
+	snapshotFileName = "snapshot.json"
+	frameHeaderSize  = 8
+	maxRecordSize    = 16 << 20
+)
+
+var ErrJournalCorrupt = errors.New("journal corrupt")
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+type journalOp string
+
+const (
+	opAdd    journalOp = "add"
+	opUpdate journalOp = "update"
+	opDelete journalOp = "delete"
+	opBatch  journalOp = "batch"
+
+	opShare   journalOp = "share"
+	opUnshare journalOp = "unshare"
+
+	opTrash   journalOp = "trash"
+	opRestore journalOp = "restore"
+	opRevert  journalOp = "revert"
+
+	opCollection     journalOp = "collection"
+	opDropCollection journalOp = "dropCollection"
+	opCollect        journalOp = "collect"
+	opUncollect      journalOp = "uncollect"
+)
+
+// Records carry the resulting state rather than the request, so replaying a
+// journal on top of a snapshot that already contains some of its effects is
+// harmless.
+type journalRecord struct {
+	Op      journalOp        `json:"op"`
+	UserID  string           `json:"userId"`
+	FavID   string           `json:"favoriteId,omitempty"`
+	Counter int64            `json:"counter,omitempty"`
+	Asset   *models.RawAsset `json:"asset,omitempty"`
+	Records []journalRecord  `json:"records,omitempty"`
+	Share   *models.Share    `json:"share,omitempty"`
+	// Revision is the version an opRevert went back to.
+	Revision int64 `json:"revision,omitempty"`
+
+	CollectionID string             `json:"collectionId,omitempty"`
+	Collection   *models.Collection `json:"collection,omitempty"`
+}
+
+type snapshot struct {
+	Data     map[string]map[string]models.RawAsset `json:"data"`
+	Counters map[string]int64                      `json:"counters"`
+	Shares   []models.Share                        `json:"shares,omitempty"`
+	Trash    map[string]map[string]models.RawAsset `json:"trash,omitempty"`
+
+	Collections []snapshotCollection `json:"collections,omitempty"`
+	History     []snapshotHistory    `json:"history,omitempty"`
+}
+
+type snapshotHistory struct {
+	UserID    string            `json:"userId"`
+	FavID     string            `json:"favoriteId"`
+	Revisions []models.Revision `json:"revisions"`
+}
+
+type snapshotCollection struct {
+	UserID string `json:"userId"`
+	models.Collection
+	Members []string `json:"members,omitempty"`
+}
+
+type journal struct {
+	dir    string
+	file   *os.File
+	size   int64
+	noSync bool
+}
+
+func openJournal(dir string, noSync bool) (*journal, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(filepath.Join(dir, journalFileName), os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &journal{dir: dir, file: f, noSync: noSync}, nil
+}
+
+// replay feeds every intact record to apply. A torn write at the tail (short
+// header, short payload or checksum mismatch on the final frame) is treated as
+// an interrupted append and cut off; damage anywhere else, including a length
+// that is too large for the frames after it, is reported as ErrJournalCorrupt.
+func (j *journal) replay(apply func(journalRecord)) error {
+	if _, err := j.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	info, err := j.file.Stat()
+	if err != nil {
+		return err
+	}
+	fileSize := info.Size()
+
+	r := bufio.NewReader(j.file)
+	var offset int64
+	header := make([]byte, frameHeaderSize)
+	for {
+		if _, err := io.ReadFull(r, header); err != nil {
+			if err == io.EOF {
+				break
+			}
+			if err == io.ErrUnexpectedEOF {
+				return j.truncateTail(offset, fileSize)
+			}
+			return err
+		}
+		length := binary.LittleEndian.Uint32(header[0:4])
+		sum := binary.LittleEndian.Uint32(header[4:8])
+		end := offset + frameHeaderSize + int64(length)
+		if length > maxRecordSize || end > fileSize {
+			// A bad length hides where the next frame starts, so it is only
+			// a torn append if no intact frame follows it.
+			intact, err := j.intactFrameAfter(offset+frameHeaderSize, fileSize)
+			if err != nil {
+				return err
+			}
+			if !intact {
+				return j.truncateTail(offset, fileSize)
+			}
+			if length > maxRecordSize {
+				return fmt.Errorf("%w: oversized record at offset %d", ErrJournalCorrupt, offset)
+			}
+			return fmt.Errorf("%w: record at offset %d runs past the end of the file", ErrJournalCorrupt, offset)
+		}
+
+		payload := make([]byte, length)
+		if _, err := io.ReadFull(r, payload); err != nil {
+			return j.truncateTail(offset, fileSize)
+		}
+		if crc32.Checksum(payload, crcTable) != sum {
+			if end == fileSize {
+				return j.truncateTail(offset, fileSize)
+			}
+			return fmt.Errorf("%w: checksum mismatch at offset %d", ErrJournalCorrupt, offset)
+		}
+
+		var rec journalRecord
+		if err := json.Unmarshal(payload, &rec); err != nil {
+			return fmt.Errorf("%w: undecodable record at offset %d: %v", ErrJournalCorrupt, offset, err)
+		}
+		apply(rec)
+		offset = end
+	}
+
+	j.size = offset
+	_, err = j.file.Seek(offset, io.SeekStart)
+	return err
+}
+
+// intactFrameAfter reports whether a frame with a valid checksum starts
+// anywhere in the file from start on.
+func (j *journal) intactFrameAfter(start, fileSize int64) (bool, error) {
+	if start >= fileSize {
+		return false, nil
+	}
+	rest := make([]byte, fileSize-start)
+	if _, err := j.file.ReadAt(rest, start); err != nil && err != io.EOF {
+		return false, err
+	}
+	for p := 0; p+frameHeaderSize < len(rest); p++ {
+		length := int(binary.LittleEndian.Uint32(rest[p : p+4]))
+		end := p + frameHeaderSize + length
+		if length == 0 || length > maxRecordSize || end > len(rest) || rest[p+frameHeaderSize] != '{' {
+			continue
+		}
+		if crc32.Checksum(rest[p+frameHeaderSize:end], crcTable) == binary.LittleEndian.Uint32(rest[p+4:p+8]) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (j *journal) truncateTail(offset, fileSize int64) error {
+	log.Printf("journal: discarding %d bytes of truncated trailing record at offset %d", fileSize-offset, offset)
+	if err := j.file.Truncate(offset); err != nil {
+		return err
+	}
+	j.size = offset
+	_, err := j.file.Seek(offset, io.SeekStart)
+	return err
+}
+
+func (j *journal) append(rec journalRecord) error {
+	payload, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	frame := make([]byte, frameHeaderSize+len(payload))
+	binary.LittleEndian.PutUint32(frame[0:4], uint32(len(payload)))
+	binary.LittleEndian.PutUint32(frame[4:8], crc32.Checksum(payload, crcTable))
+	copy(frame[frameHeaderSize:], payload)
+
+	if _, err := j.file.Write(frame); err != nil {
+		j.rollback()
+		return fmt.Errorf("journal append: %w", err)
+	}
+	if !j.noSync {
+		if err := j.file.Sync(); err != nil {
+			// The caller treats the write as failed, so a restart must not
+			// replay it either.
+			j.rollback()
+			return fmt.Errorf("journal sync: %w", err)
+		}
+	}
+	j.size += int64(len(frame))
+	return nil
+}
+
+// rollback drops whatever part of a failed append made it to the file, so
+// the next append does not land behind a record j.size does not cover.
+func (j *journal) rollback() {
+	j.file.Truncate(j.size)
+	j.file.Seek(j.size, io.SeekStart)
+}
+
+func (j *journal) reset() error {
+	if err := j.file.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := j.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	j.size = 0
+	return j.file.Sync()
+}
+
+func (j *journal) close() error {
+	return j.file.Close()
+}
+
+func writeSnapshot(dir string, snap snapshot) error {
+	tmp, err := os.CreateTemp(dir, snapshotFileName+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := json.NewEncoder(tmp).Encode(snap); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(dir, snapshotFileName)); err != nil {
+		return err
+	}
+	// The journal is reset next, so the rename has to be on disk first.
+	return syncDir(dir)
+}
+
+func syncDir(dir string) error {
+	// Windows cannot sync a directory handle, and its renames do not need it.
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+func readSnapshot(dir string) (*snapshot, error) {
+	f, err := os.Open(filepath.Join(dir, snapshotFileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var snap snapshot
+	if err := json.NewDecoder(f).Decode(&snap); err != nil {
+		return nil, fmt.Errorf("read snapshot: %w", err)
+	}
+	return &snap, nil
+}
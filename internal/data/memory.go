@@ -1,9 +1,13 @@
 package data
 
 import (
-	"errors"
+	"cmp"
+	"crypto/rand"
 	"fmt"
+	"log"
+	"slices"
 	"sort"
+	"strings"
 	"sync"
 	"time"
 
@@ -13,97 +17,1143 @@ import (
 type InMemoryStore struct {
 	mu       sync.RWMutex
 	data     map[string]map[string]models.RawAsset
+	order    map[string][]Keyset
 	counters map[string]int64
+	index    *searchIndex
+	tags     map[string]map[string]int
+	trash    map[string]map[string]models.RawAsset
+	history  map[favKey][]models.Revision
+
+	shares     map[favKey]map[string]models.Share
+	sharedWith map[string]map[favKey]struct{}
+
+	collections map[string]map[string]models.Collection
+	members     map[collKey]map[string]struct{}
+	memberOf    map[favKey]map[string]struct{}
+
+	journal       *journal
+	snapshotEvery int
+	sinceSnapshot int
+	stop          chan struct{}
+	done          chan struct{}
+}
+
+var newestFirst = ListQuery{Sort: SortByCreatedAt}
+
+type favKey struct {
+	userID, favID string
+}
+
+type collKey struct {
+	userID, collID string
+}
+
+type JournalConfig struct {
+	Dir              string
+	SnapshotEvery    int
+	SnapshotInterval time.Duration
+	NoSync           bool
 }
 
 func NewInMemoryStore() *InMemoryStore {
 	return &InMemoryStore{
 		data:     make(map[string]map[string]models.RawAsset),
+		order:    make(map[string][]Keyset),
 		counters: make(map[string]int64),
+		index:    newSearchIndex(),
+		tags:     make(map[string]map[string]int),
+		trash:    make(map[string]map[string]models.RawAsset),
+		history:  make(map[favKey][]models.Revision),
+
+		shares:     make(map[favKey]map[string]models.Share),
+		sharedWith: make(map[string]map[favKey]struct{}),
+
+		collections: make(map[string]map[string]models.Collection),
+		members:     make(map[collKey]map[string]struct{}),
+		memberOf:    make(map[favKey]map[string]struct{}),
 	}
 }
 
-func (s *InMemoryStore) nextID(userID string) string {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+func NewJournaledInMemoryStore(cfg JournalConfig) (*InMemoryStore, error) {
+	s := NewInMemoryStore()
+
+	j, err := openJournal(cfg.Dir, cfg.NoSync)
+	if err != nil {
+		return nil, err
+	}
+	snap, err := readSnapshot(cfg.Dir)
+	if err != nil {
+		j.close()
+		return nil, err
+	}
+	if snap != nil {
+		s.restore(snap)
+	}
+	replayed := 0
+	if err := j.replay(func(rec journalRecord) { s.apply(rec); replayed++ }); err != nil {
+		j.close()
+		return nil, err
+	}
+	log.Printf("journal: restored state from %s (%d journal records)", cfg.Dir, replayed)
+
+	s.journal = j
+	s.snapshotEvery = cfg.SnapshotEvery
+	s.sinceSnapshot = replayed
+	if cfg.SnapshotInterval > 0 {
+		s.stop = make(chan struct{})
+		s.done = make(chan struct{})
+		go s.snapshotLoop(cfg.SnapshotInterval)
+	}
+	return s, nil
+}
+
+func (s *InMemoryStore) nextID(userID string) (string, int64) {
 	c := s.counters[userID] + 1
-	s.counters[userID] = c
-	return fmt.Sprintf("%s-%s-%d", time.Now().UTC().Format("20060102T150405"), userID, c)
+	return fmt.Sprintf("%s-%s-%d", time.Now().UTC().Format("20060102T150405"), userID, c), c
 }
 
 func (s *InMemoryStore) Add(userID string, asset models.RawAsset) (string, error) {
-	favID := s.nextID(userID)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.checkTagLimit(userID, asset.Tags); err != nil {
+		return "", err
+	}
+	rec := s.addRecord(userID, asset)
+	if err := s.commit(rec); err != nil {
+		return "", err
+	}
+	return rec.FavID, nil
+}
+
+func (s *InMemoryStore) addRecord(userID string, asset models.RawAsset) journalRecord {
+	favID, counter := s.nextID(userID)
 	asset.ID = favID
 	asset.CreatedAt = time.Now().UTC()
+	asset.UpdatedAt = asset.CreatedAt
+	asset.Version = 1
+	asset.UpdatedBy = userID
+	return journalRecord{Op: opAdd, UserID: userID, FavID: favID, Counter: counter, Asset: &asset}
+}
+
+func (s *InMemoryStore) List(userID string, q ListQuery) ([]models.Favorite, int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	keys := s.order[userID]
+	if !q.Filter.IsZero() || q.Sort == SortByDescription || q.Ascending {
+		keys = s.arrange(userID, q)
+	}
+	totalCount := len(keys)
+
+	var from, to int
+	switch {
+	case q.After != nil:
+		from = sort.Search(totalCount, func(i int) bool { return q.Precedes(*q.After, keys[i]) })
+		to = min(from+q.Limit, totalCount)
+	case q.Before != nil:
+		to = sort.Search(totalCount, func(i int) bool { return !q.Precedes(keys[i], *q.Before) })
+		from = max(to-q.Limit, 0)
+	default:
+		if q.Offset >= totalCount {
+			return []models.Favorite{}, totalCount, nil
+		}
+		from = q.Offset
+		to = min(from+q.Limit, totalCount)
+	}
 
+	favorites := make([]models.Favorite, 0, to-from)
+	for _, key := range keys[from:to] {
+		favorites = append(favorites, models.Favorite{FavoriteID: key.ID, Asset: s.data[userID][key.ID]})
+	}
+	return favorites, totalCount, nil
+}
+
+// arrange returns the user's keys that pass the filter, in the query's order.
+// The default newest-first listing skips this and reads s.order directly.
+func (s *InMemoryStore) arrange(userID string, q ListQuery) []Keyset {
+	m := s.data[userID]
+	keys := make([]Keyset, 0, len(s.order[userID]))
+	members := s.members[collKey{userID, q.Filter.Collection}]
+	for _, key := range s.order[userID] {
+		if _, ok := members[key.ID]; q.Filter.Collection != "" && !ok {
+			continue
+		}
+		if asset := m[key.ID]; q.Filter.Matches(asset) {
+			keys = append(keys, KeysetOf(asset))
+		}
+	}
+	switch {
+	case q.Sort == SortByDescription:
+		sort.Slice(keys, func(i, j int) bool { return q.Precedes(keys[i], keys[j]) })
+	case q.Ascending:
+		slices.Reverse(keys)
+	}
+	return keys
+}
+
+func (s *InMemoryStore) Get(userID, favID string) (models.RawAsset, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	asset, ok := s.data[userID][favID]
+	if !ok {
+		return models.RawAsset{}, ErrNotFound
+	}
+	return asset, nil
+}
+
+func (s *InMemoryStore) Users() ([]models.UserSummary, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	users := make([]models.UserSummary, 0, len(s.data))
+	for userID, favs := range s.data {
+		if len(favs) > 0 {
+			users = append(users, models.UserSummary{UserID: userID, Favorites: len(favs)})
+		}
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].UserID < users[j].UserID })
+	return users, nil
+}
+
+func (s *InMemoryStore) Tags(userID string) ([]models.TagCount, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	tags := make([]models.TagCount, 0, len(s.tags[userID]))
+	for tag, n := range s.tags[userID] {
+		tags = append(tags, models.TagCount{Tag: tag, Count: n})
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		if tags[i].Count != tags[j].Count {
+			return tags[i].Count > tags[j].Count
+		}
+		return tags[i].Tag < tags[j].Tag
+	})
+	return tags, nil
+}
+
+func (s *InMemoryStore) PutShare(share models.Share) (models.Share, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	if _, ok := s.data[userID]; !ok {
-		s.data[userID] = make(map[string]models.RawAsset)
+	if _, ok := s.data[share.OwnerID][share.FavoriteID]; !ok {
+		return models.Share{}, ErrNotFound
+	}
+	share.CreatedAt = time.Now().UTC()
+	if err := s.commit(journalRecord{Op: opShare, UserID: share.OwnerID, FavID: share.FavoriteID, Share: &share}); err != nil {
+		return models.Share{}, err
 	}
-	s.data[userID][favID] = asset
-	return favID, nil
+	return share, nil
 }
 
-func (s *InMemoryStore) List(userID string, limit, offset int) ([]models.Favorite, int, error) {
+func (s *InMemoryStore) GetShare(ownerID, favID, userID string) (models.Share, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
+	share, ok := s.shares[favKey{ownerID, favID}][userID]
+	if _, live := s.data[ownerID][favID]; !ok || !live {
+		return models.Share{}, ErrNotFound
+	}
+	return share, nil
+}
 
-	m, ok := s.data[userID]
+func (s *InMemoryStore) DeleteShare(ownerID, favID, userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	share, ok := s.shares[favKey{ownerID, favID}][userID]
 	if !ok {
-		return []models.Favorite{}, 0, nil
+		return ErrNotFound
 	}
+	return s.commit(journalRecord{Op: opUnshare, UserID: ownerID, FavID: favID, Share: &share})
+}
 
-	totalCount := len(m)
+func (s *InMemoryStore) ListShares(ownerID, favID string) ([]models.Share, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if _, ok := s.data[ownerID][favID]; !ok {
+		return nil, ErrNotFound
+	}
+	shares := make([]models.Share, 0, len(s.shares[favKey{ownerID, favID}]))
+	for _, share := range s.shares[favKey{ownerID, favID}] {
+		shares = append(shares, share)
+	}
+	sort.Slice(shares, func(i, j int) bool { return shares[i].UserID < shares[j].UserID })
+	return shares, nil
+}
 
- 	favorites := make([]models.Favorite, 0, totalCount)
-	for id, asset := range m {
-		favorites = append(favorites, models.Favorite{FavoriteID: id, Asset: asset})
+func (s *InMemoryStore) SharedWith(userID string) ([]models.SharedFavorite, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	shared := make([]models.SharedFavorite, 0, len(s.sharedWith[userID]))
+	for key := range s.sharedWith[userID] {
+		asset, live := s.data[key.userID][key.favID]
+		if !live {
+			continue
+		}
+		shared = append(shared, models.SharedFavorite{Share: s.shares[key][userID], Asset: asset})
 	}
+	sortShared(shared)
+	return shared, nil
+}
 
- 	sort.Slice(favorites, func(i, j int) bool {
-		return favorites[i].Asset.CreatedAt.After(favorites[j].Asset.CreatedAt)
+func sortShared(shared []models.SharedFavorite) {
+	sort.Slice(shared, func(i, j int) bool {
+		if c := shared[i].CreatedAt.Compare(shared[j].CreatedAt); c != 0 {
+			return c > 0
+		}
+		return shared[i].FavoriteID > shared[j].FavoriteID
 	})
+}
 
- 	if offset >= totalCount {
-		return []models.Favorite{}, totalCount, nil
+func (s *InMemoryStore) CreateCollection(userID, name string) (models.Collection, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.nameTaken(userID, name, "") {
+		return models.Collection{}, ErrCollectionExists
 	}
+	position := 0
+	for _, c := range s.collections[userID] {
+		position = max(position, c.Position+1)
+	}
+	now := time.Now().UTC()
+	c := models.Collection{ID: newCollectionID(), Name: name, Position: position, CreatedAt: now, UpdatedAt: now}
+	if err := s.commit(journalRecord{Op: opCollection, UserID: userID, CollectionID: c.ID, Collection: &c}); err != nil {
+		return models.Collection{}, err
+	}
+	return c, nil
+}
+
+func (s *InMemoryStore) nameTaken(userID, name, exceptID string) bool {
+	for _, c := range s.collections[userID] {
+		if c.Name == name && c.ID != exceptID {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *InMemoryStore) GetCollection(userID, collID string) (models.Collection, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.collection(userID, collID)
+}
+
+func (s *InMemoryStore) collection(userID, collID string) (models.Collection, error) {
+	c, ok := s.collections[userID][collID]
+	if !ok {
+		return models.Collection{}, ErrNotFound
+	}
+	for favID := range s.members[collKey{userID, collID}] {
+		if _, live := s.data[userID][favID]; live {
+			c.Favorites++
+		}
+	}
+	return c, nil
+}
+
+func (s *InMemoryStore) ListCollections(userID string) ([]models.Collection, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.listCollections(userID), nil
+}
+
+func (s *InMemoryStore) listCollections(userID string) []models.Collection {
+	list := make([]models.Collection, 0, len(s.collections[userID]))
+	for id := range s.collections[userID] {
+		c, _ := s.collection(userID, id)
+		list = append(list, c)
+	}
+	sortCollections(list)
+	return list
+}
+
+func sortCollections(list []models.Collection) {
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Position != list[j].Position {
+			return list[i].Position < list[j].Position
+		}
+		return list[i].CreatedAt.Before(list[j].CreatedAt)
+	})
+}
+
+func (s *InMemoryStore) RenameCollection(userID, collID, name string) (models.Collection, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, err := s.collection(userID, collID)
+	if err != nil {
+		return models.Collection{}, err
+	}
+	if s.nameTaken(userID, name, collID) {
+		return models.Collection{}, ErrCollectionExists
+	}
+	c.Name, c.UpdatedAt = name, time.Now().UTC()
+	if err := s.commit(journalRecord{Op: opCollection, UserID: userID, CollectionID: c.ID, Collection: &c}); err != nil {
+		return models.Collection{}, err
+	}
+	return c, nil
+}
+
+func (s *InMemoryStore) ReorderCollections(userID string, collIDs []string) ([]models.Collection, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !isPermutation(collIDs, s.collections[userID]) {
+		return nil, ErrInvalidOrder
+	}
+	now := time.Now().UTC()
+	rec := journalRecord{Op: opBatch, UserID: userID}
+	for i, id := range collIDs {
+		c := s.collections[userID][id]
+		if c.Position == i {
+			continue
+		}
+		c.Position, c.UpdatedAt = i, now
+		rec.Records = append(rec.Records, journalRecord{Op: opCollection, UserID: userID, CollectionID: id, Collection: &c})
+	}
+	if len(rec.Records) > 0 {
+		if err := s.commit(rec); err != nil {
+			return nil, err
+		}
+	}
+	return s.listCollections(userID), nil
+}
 
-	end := offset + limit
-	if end > totalCount {
-		end = totalCount
+func isPermutation(ids []string, collections map[string]models.Collection) bool {
+	if len(ids) != len(collections) {
+		return false
 	}
+	seen := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		if _, ok := collections[id]; !ok || seen[id] {
+			return false
+		}
+		seen[id] = true
+	}
+	return true
+}
+
+func (s *InMemoryStore) DeleteCollection(userID, collID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.collections[userID][collID]; !ok {
+		return ErrNotFound
+	}
+	return s.commit(journalRecord{Op: opDropCollection, UserID: userID, CollectionID: collID})
+}
+
+func (s *InMemoryStore) AddToCollection(userID, collID, favID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.collections[userID][collID]; !ok {
+		return ErrNotFound
+	}
+	if _, ok := s.data[userID][favID]; !ok {
+		return ErrNotFound
+	}
+	if _, ok := s.members[collKey{userID, collID}][favID]; ok {
+		return nil
+	}
+	return s.commit(journalRecord{Op: opCollect, UserID: userID, FavID: favID, CollectionID: collID})
+}
+
+func (s *InMemoryStore) RemoveFromCollection(userID, collID, favID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.members[collKey{userID, collID}][favID]; !ok {
+		return ErrNotFound
+	}
+	return s.commit(journalRecord{Op: opUncollect, UserID: userID, FavID: favID, CollectionID: collID})
+}
 
-	paginatedFavorites := favorites[offset:end]
-	return paginatedFavorites, totalCount, nil
+func newCollectionID() string {
+	return strings.ToLower(rand.Text())
 }
 
-func (s *InMemoryStore) Delete(userID, favID string) error {
+// Delete moves the favorite to the trash. Its shares and collection
+// memberships stay, hidden, until it is restored or purged.
+func (s *InMemoryStore) Delete(userID, favID string, expectedVersion int64) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	m, ok := s.data[userID]
+	asset, err := s.current(userID, favID, expectedVersion)
+	if err != nil {
+		return err
+	}
+	return s.commit(trashRecord(userID, asset))
+}
+
+func trashRecord(userID string, asset models.RawAsset) journalRecord {
+	now := time.Now().UTC()
+	asset.DeletedAt = &now
+	asset.UpdatedAt = now
+	asset.UpdatedBy = userID
+	asset.Version++
+	return journalRecord{Op: opTrash, UserID: userID, FavID: asset.ID, Asset: &asset}
+}
+
+func (s *InMemoryStore) ListTrash(userID string, limit, offset int) ([]models.Favorite, int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	trashed := make([]models.Favorite, 0, len(s.trash[userID]))
+	for _, asset := range s.trash[userID] {
+		trashed = append(trashed, models.Favorite{FavoriteID: asset.ID, Asset: asset})
+	}
+	sort.Slice(trashed, func(i, j int) bool {
+		a, b := trashed[i].Asset, trashed[j].Asset
+		if !a.DeletedAt.Equal(*b.DeletedAt) {
+			return a.DeletedAt.After(*b.DeletedAt)
+		}
+		return a.ID > b.ID
+	})
+	total := len(trashed)
+	if offset >= total {
+		return []models.Favorite{}, total, nil
+	}
+	return trashed[offset:min(offset+limit, total)], total, nil
+}
+
+func (s *InMemoryStore) Restore(userID, favID string) (models.RawAsset, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	asset, ok := s.trash[userID][favID]
 	if !ok {
-		return errors.New("not found")
+		return models.RawAsset{}, ErrNotFound
 	}
-	if _, ok := m[favID]; !ok {
-		return errors.New("not found")
+	if err := s.checkTagLimit(userID, asset.Tags); err != nil {
+		return models.RawAsset{}, err
 	}
-	delete(m, favID)
-	return nil
+	asset.DeletedAt = nil
+	asset.UpdatedAt = time.Now().UTC()
+	asset.UpdatedBy = userID
+	asset.Version++
+	if err := s.commit(journalRecord{Op: opRestore, UserID: userID, FavID: favID, Asset: &asset}); err != nil {
+		return models.RawAsset{}, err
+	}
+	return asset, nil
+}
+
+func (s *InMemoryStore) Purge(userID, favID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.trash[userID][favID]; !ok {
+		return ErrNotFound
+	}
+	return s.commit(journalRecord{Op: opDelete, UserID: userID, FavID: favID})
+}
+
+func (s *InMemoryStore) PurgeTrash(deletedBefore time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	purge := journalRecord{Op: opBatch}
+	for userID, trashed := range s.trash {
+		for favID, asset := range trashed {
+			if asset.DeletedAt.Before(deletedBefore) {
+				purge.Records = append(purge.Records, journalRecord{Op: opDelete, UserID: userID, FavID: favID})
+			}
+		}
+	}
+	if len(purge.Records) == 0 {
+		return 0, nil
+	}
+	if err := s.commit(purge); err != nil {
+		return 0, err
+	}
+	return len(purge.Records), nil
+}
+
+func (s *InMemoryStore) UpdateDescription(actor, userID, favID, desc string, expectedVersion int64) (models.RawAsset, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	asset, err := s.current(userID, favID, expectedVersion)
+	if err != nil {
+		return models.RawAsset{}, err
+	}
+	asset.Description = desc
+	asset.UpdatedBy = cmp.Or(actor, userID)
+	return s.commitUpdate(userID, asset)
+}
+
+func (s *InMemoryStore) History(userID, favID string, limit, offset int) ([]models.Revision, int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if !s.known(userID, favID) {
+		return nil, 0, ErrNotFound
+	}
+	revs := s.history[favKey{userID, favID}]
+	return pageRevisions(revs, limit, offset), len(revs), nil
+}
+
+func (s *InMemoryStore) Revision(userID, favID string, version int64) (models.Revision, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.revision(userID, favID, version)
+}
+
+func (s *InMemoryStore) revision(userID, favID string, version int64) (models.Revision, error) {
+	for _, rev := range s.history[favKey{userID, favID}] {
+		if rev.Version == version {
+			return rev, nil
+		}
+	}
+	return models.Revision{}, ErrNotFound
 }
 
-func (s *InMemoryStore) UpdateDescription(userID, favID, desc string) error {
+// known reports whether the favorite is live or in the trash.
+func (s *InMemoryStore) known(userID, favID string) bool {
+	if _, ok := s.data[userID][favID]; ok {
+		return true
+	}
+	_, ok := s.trash[userID][favID]
+	return ok
+}
+
+func (s *InMemoryStore) Revert(userID, favID string, version, expectedVersion int64) (models.RawAsset, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	m, ok := s.data[userID]
+	current, err := s.current(userID, favID, expectedVersion)
+	if err != nil {
+		return models.RawAsset{}, err
+	}
+	rev, err := s.revision(userID, favID, version)
+	if err != nil {
+		return models.RawAsset{}, err
+	}
+	if err := s.checkTagLimit(userID, rev.Asset.Tags); err != nil {
+		return models.RawAsset{}, err
+	}
+	current.Type = rev.Asset.Type
+	current.Description = rev.Asset.Description
+	current.Tags = rev.Asset.Tags
+	current.Payload = rev.Asset.Payload
+	current.UpdatedBy = userID
+	rec := updateRecord(userID, current)
+	rec.Op, rec.Revision = opRevert, version
+	if err := s.commit(rec); err != nil {
+		return models.RawAsset{}, err
+	}
+	return *rec.Asset, nil
+}
+
+func (s *InMemoryStore) Search(userID, query string, limit int) ([]models.SearchResult, int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return searchResults(s.index, userID, query, limit, func(ids []string) (map[string]models.RawAsset, error) {
+		return s.data[userID], nil
+	})
+}
+
+// Update replaces the type, description and payload of an existing favorite.
+// ID and CreatedAt are kept from the stored copy.
+func (s *InMemoryStore) Update(userID string, asset models.RawAsset, expectedVersion int64) (models.RawAsset, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	current, err := s.current(userID, asset.ID, expectedVersion)
+	if err != nil {
+		return models.RawAsset{}, err
+	}
+	if err := s.checkTagLimit(userID, asset.Tags); err != nil {
+		return models.RawAsset{}, err
+	}
+	current.Type = asset.Type
+	current.Description = asset.Description
+	current.Tags = asset.Tags
+	current.Payload = asset.Payload
+	current.UpdatedBy = cmp.Or(asset.UpdatedBy, userID)
+	return s.commitUpdate(userID, current)
+}
+
+// current returns the stored favorite after checking it against the
+// caller's expected version; must be called with s.mu held.
+func (s *InMemoryStore) current(userID, favID string, expectedVersion int64) (models.RawAsset, error) {
+	asset, ok := s.data[userID][favID]
 	if !ok {
-		return errors.New("not found")
+		return models.RawAsset{}, ErrNotFound
+	}
+	if expectedVersion != AnyVersion && asset.Version != expectedVersion {
+		return models.RawAsset{}, ErrVersionMismatch
+	}
+	return asset, nil
+}
+
+func (s *InMemoryStore) commitUpdate(userID string, asset models.RawAsset) (models.RawAsset, error) {
+	rec := updateRecord(userID, asset)
+	if err := s.commit(rec); err != nil {
+		return models.RawAsset{}, err
+	}
+	return *rec.Asset, nil
+}
+
+func updateRecord(userID string, asset models.RawAsset) journalRecord {
+	asset.Version++
+	asset.UpdatedAt = time.Now().UTC()
+	return journalRecord{Op: opUpdate, UserID: userID, FavID: asset.ID, Asset: &asset}
+}
+
+func (s *InMemoryStore) Batch(userID string, ops []BatchOp, atomic bool) ([]BatchResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	results := make([]BatchResult, len(ops))
+	if !atomic {
+		for i, op := range ops {
+			rec, err := s.batchRecord(userID, op)
+			if err != nil {
+				results[i].Err = err
+				continue
+			}
+			if err := s.commit(rec); err != nil {
+				return nil, err
+			}
+			results[i] = batchResult(rec)
+		}
+		return results, nil
+	}
+
+	// Ops are applied as they go so later ones see earlier effects, and undone
+	// if one fails. The journal only sees the finished batch, as one frame, so
+	// a crash can never replay half of it.
+	counter := s.counters[userID]
+	var applied, undo []journalRecord
+	for i, op := range ops {
+		rec, err := s.batchRecord(userID, op)
+		if err != nil {
+			s.rollback(userID, undo, counter)
+			for j := range results {
+				results[j] = BatchResult{Err: ErrBatchAborted}
+			}
+			results[i].Err = err
+			return results, nil
+		}
+		undo = append(undo, s.inverse(rec))
+		s.apply(rec)
+		applied = append(applied, rec)
+		results[i] = batchResult(rec)
 	}
-	asset, ok := m[favID]
+	if s.journal != nil && len(applied) > 0 {
+		if err := s.journal.append(journalRecord{Op: opBatch, UserID: userID, Records: applied}); err != nil {
+			s.rollback(userID, undo, counter)
+			return nil, err
+		}
+		s.journaled()
+	}
+	return results, nil
+}
+
+func (s *InMemoryStore) batchRecord(userID string, op BatchOp) (journalRecord, error) {
+	switch op.Kind {
+	case BatchAdd:
+		if err := s.checkTagLimit(userID, op.Asset.Tags); err != nil {
+			return journalRecord{}, err
+		}
+		return s.addRecord(userID, op.Asset), nil
+	case BatchUpdate, BatchDelete:
+		current, err := s.current(userID, op.Asset.ID, op.ExpectedVersion)
+		if err != nil {
+			return journalRecord{}, err
+		}
+		if op.Kind == BatchDelete {
+			return trashRecord(userID, current), nil
+		}
+		if err := s.checkTagLimit(userID, op.Asset.Tags); err != nil {
+			return journalRecord{}, err
+		}
+		current.Type = op.Asset.Type
+		current.Description = op.Asset.Description
+		current.Tags = op.Asset.Tags
+		current.Payload = op.Asset.Payload
+		current.UpdatedBy = userID
+		return updateRecord(userID, current), nil
+	}
+	return journalRecord{}, ErrInvalidBatchOp
+}
+
+func batchResult(rec journalRecord) BatchResult {
+	if rec.Asset != nil && rec.Op != opTrash {
+		return BatchResult{Asset: *rec.Asset}
+	}
+	return BatchResult{Asset: models.RawAsset{ID: rec.FavID}}
+}
+
+// inverse returns the record that restores the current state of the favorite
+// rec is about to touch, including the shares and collection memberships a
+// delete would drop; call it before applying rec.
+func (s *InMemoryStore) inverse(rec journalRecord) journalRecord {
+	prev, ok := s.data[rec.UserID][rec.FavID]
 	if !ok {
-		return errors.New("not found")
+		return journalRecord{Op: opDelete, UserID: rec.UserID, FavID: rec.FavID}
+	}
+	restore := journalRecord{Op: opUpdate, UserID: rec.UserID, FavID: rec.FavID, Asset: &prev}
+	if rec.Op == opTrash {
+		restore.Op = opRestore
+	}
+	if rec.Op != opDelete {
+		return restore
+	}
+	undo := journalRecord{Op: opBatch, UserID: rec.UserID, Records: []journalRecord{restore}}
+	for _, share := range s.shares[favKey{rec.UserID, rec.FavID}] {
+		undo.Records = append(undo.Records, journalRecord{Op: opShare, UserID: rec.UserID, FavID: rec.FavID, Share: &share})
+	}
+	for collID := range s.memberOf[favKey{rec.UserID, rec.FavID}] {
+		undo.Records = append(undo.Records, journalRecord{Op: opCollect, UserID: rec.UserID, FavID: rec.FavID, CollectionID: collID})
+	}
+	return undo
+}
+
+func (s *InMemoryStore) rollback(userID string, undo []journalRecord, counter int64) {
+	for i := len(undo) - 1; i >= 0; i-- {
+		s.apply(undo[i])
+	}
+	s.counters[userID] = counter
+}
+
+// Snapshot writes the full state to disk and truncates the journal. It is a
+// no-op for stores opened without a journal.
+func (s *InMemoryStore) Snapshot() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.snapshotLocked()
+}
+
+func (s *InMemoryStore) Close() error {
+	if s.stop != nil {
+		close(s.stop)
+		<-s.done
+		s.stop = nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.journal == nil {
+		return nil
+	}
+	err := s.snapshotLocked()
+	if cerr := s.journal.close(); err == nil {
+		err = cerr
+	}
+	s.journal = nil
+	return err
+}
+
+// commit must be called with s.mu held for writing. The record reaches the
+// journal before it is applied, so a failed append leaves memory untouched.
+func (s *InMemoryStore) commit(rec journalRecord) error {
+	if s.journal != nil {
+		if err := s.journal.append(rec); err != nil {
+			return err
+		}
+	}
+	s.apply(rec)
+	s.journaled()
+	return nil
+}
+
+// journaled counts an appended record towards the next snapshot.
+func (s *InMemoryStore) journaled() {
+	if s.journal == nil {
+		return
+	}
+	s.sinceSnapshot++
+	if s.snapshotEvery > 0 && s.sinceSnapshot >= s.snapshotEvery {
+		if err := s.snapshotLocked(); err != nil {
+			log.Printf("journal: snapshot failed: %v", err)
+		}
+	}
+}
+
+func (s *InMemoryStore) apply(rec journalRecord) {
+	switch rec.Op {
+	case opAdd, opUpdate, opRestore, opRevert:
+		s.recordRevision(rec)
+		delete(s.trash[rec.UserID], rec.FavID)
+		if rec.Counter > s.counters[rec.UserID] {
+			s.counters[rec.UserID] = rec.Counter
+		}
+		if _, ok := s.data[rec.UserID]; !ok {
+			s.data[rec.UserID] = make(map[string]models.RawAsset)
+		}
+		if prev, exists := s.data[rec.UserID][rec.FavID]; exists {
+			s.countTags(rec.UserID, prev.Tags, -1)
+		} else {
+			s.insertKey(rec.UserID, Keyset{CreatedAt: rec.Asset.CreatedAt, ID: rec.FavID})
+		}
+		s.data[rec.UserID][rec.FavID] = *rec.Asset
+		s.countTags(rec.UserID, rec.Asset.Tags, 1)
+		s.index.put(rec.UserID, *rec.Asset)
+	case opTrash, opDelete:
+		if rec.Op == opTrash {
+			s.recordRevision(rec)
+		} else {
+			delete(s.history, favKey{rec.UserID, rec.FavID})
+		}
+		if asset, ok := s.data[rec.UserID][rec.FavID]; ok {
+			s.removeKey(rec.UserID, Keyset{CreatedAt: asset.CreatedAt, ID: asset.ID})
+			delete(s.data[rec.UserID], rec.FavID)
+			s.countTags(rec.UserID, asset.Tags, -1)
+			s.index.remove(rec.UserID, rec.FavID)
+		}
+		if rec.Op == opTrash {
+			if s.trash[rec.UserID] == nil {
+				s.trash[rec.UserID] = make(map[string]models.RawAsset)
+			}
+			s.trash[rec.UserID][rec.FavID] = *rec.Asset
+			break
+		}
+		delete(s.trash[rec.UserID], rec.FavID)
+		for userID := range s.shares[favKey{rec.UserID, rec.FavID}] {
+			s.removeShare(favKey{rec.UserID, rec.FavID}, userID)
+		}
+		for collID := range s.memberOf[favKey{rec.UserID, rec.FavID}] {
+			s.uncollect(rec.UserID, collID, rec.FavID)
+		}
+	case opShare:
+		// A replayed share may refer to a favorite a later record deletes.
+		if _, ok := s.data[rec.UserID][rec.FavID]; ok {
+			s.putShare(*rec.Share)
+		}
+	case opUnshare:
+		s.removeShare(favKey{rec.UserID, rec.FavID}, rec.Share.UserID)
+	case opCollection:
+		if s.collections[rec.UserID] == nil {
+			s.collections[rec.UserID] = make(map[string]models.Collection)
+		}
+		c := *rec.Collection
+		c.Favorites = 0
+		s.collections[rec.UserID][rec.CollectionID] = c
+	case opDropCollection:
+		for favID := range s.members[collKey{rec.UserID, rec.CollectionID}] {
+			s.uncollect(rec.UserID, rec.CollectionID, favID)
+		}
+		delete(s.collections[rec.UserID], rec.CollectionID)
+	case opCollect:
+		_, collOK := s.collections[rec.UserID][rec.CollectionID]
+		if _, favOK := s.data[rec.UserID][rec.FavID]; collOK && favOK {
+			s.collect(rec.UserID, rec.CollectionID, rec.FavID)
+		}
+	case opUncollect:
+		s.uncollect(rec.UserID, rec.CollectionID, rec.FavID)
+	case opBatch:
+		for _, r := range rec.Records {
+			s.apply(r)
+		}
+	}
+}
+
+var revisionActions = map[journalOp]models.RevisionAction{
+	opAdd:     models.RevisionCreated,
+	opUpdate:  models.RevisionUpdated,
+	opTrash:   models.RevisionDeleted,
+	opRestore: models.RevisionRestored,
+	opRevert:  models.RevisionReverted,
+}
+
+// recordRevision appends the state rec leads to to the favorite's history.
+// A record for a version the history already has comes from undoing an
+// atomic batch or from replaying a journal over a snapshot; it drops any
+// later revisions instead, since they no longer happened.
+func (s *InMemoryStore) recordRevision(rec journalRecord) {
+	key := favKey{rec.UserID, rec.FavID}
+	revs := s.history[key]
+	for len(revs) > 0 && revs[len(revs)-1].Version > rec.Asset.Version {
+		revs = revs[:len(revs)-1]
+	}
+	if len(revs) > 0 && revs[len(revs)-1].Version == rec.Asset.Version {
+		s.history[key] = revs
+		return
+	}
+	var prev *models.Revision
+	if len(revs) > 0 {
+		prev = &revs[len(revs)-1]
+	}
+	rev := newRevision(prev, *rec.Asset, revisionActions[rec.Op])
+	rev.RevertedTo = rec.Revision
+	revs = append(revs, rev)
+	if len(revs) > MaxRevisions {
+		revs = slices.Clone(revs[len(revs)-MaxRevisions:])
+	}
+	s.history[key] = revs
+}
+
+func (s *InMemoryStore) countTags(userID string, tags []string, delta int) {
+	if len(tags) == 0 {
+		return
+	}
+	counts := s.tags[userID]
+	if counts == nil {
+		counts = make(map[string]int)
+		s.tags[userID] = counts
+	}
+	for _, tag := range tags {
+		if counts[tag] += delta; counts[tag] <= 0 {
+			delete(counts, tag)
+		}
+	}
+	if len(counts) == 0 {
+		delete(s.tags, userID)
+	}
+}
+
+// checkTagLimit fails when tags would take the user past MaxTagsPerUser.
+// Tags the write would drop still count, which keeps the check simple and
+// identical across stores.
+func (s *InMemoryStore) checkTagLimit(userID string, tags []string) error {
+	counts := s.tags[userID]
+	distinct := len(counts)
+	for _, tag := range slices.Compact(slices.Sorted(slices.Values(tags))) {
+		if _, ok := counts[tag]; !ok {
+			distinct++
+		}
+	}
+	if distinct > MaxTagsPerUser {
+		return ErrTagLimit
 	}
-	asset.Description = desc
-	m[favID] = asset
 	return nil
 }
+
+func (s *InMemoryStore) putShare(share models.Share) {
+	key := favKey{share.OwnerID, share.FavoriteID}
+	if s.shares[key] == nil {
+		s.shares[key] = make(map[string]models.Share)
+	}
+	s.shares[key][share.UserID] = share
+	if s.sharedWith[share.UserID] == nil {
+		s.sharedWith[share.UserID] = make(map[favKey]struct{})
+	}
+	s.sharedWith[share.UserID][key] = struct{}{}
+}
+
+func (s *InMemoryStore) removeShare(key favKey, userID string) {
+	delete(s.shares[key], userID)
+	if len(s.shares[key]) == 0 {
+		delete(s.shares, key)
+	}
+	delete(s.sharedWith[userID], key)
+	if len(s.sharedWith[userID]) == 0 {
+		delete(s.sharedWith, userID)
+	}
+}
+
+func (s *InMemoryStore) collect(userID, collID, favID string) {
+	ck, fk := collKey{userID, collID}, favKey{userID, favID}
+	if s.members[ck] == nil {
+		s.members[ck] = make(map[string]struct{})
+	}
+	s.members[ck][favID] = struct{}{}
+	if s.memberOf[fk] == nil {
+		s.memberOf[fk] = make(map[string]struct{})
+	}
+	s.memberOf[fk][collID] = struct{}{}
+}
+
+func (s *InMemoryStore) uncollect(userID, collID, favID string) {
+	ck, fk := collKey{userID, collID}, favKey{userID, favID}
+	delete(s.members[ck], favID)
+	if len(s.members[ck]) == 0 {
+		delete(s.members, ck)
+	}
+	delete(s.memberOf[fk], collID)
+	if len(s.memberOf[fk]) == 0 {
+		delete(s.memberOf, fk)
+	}
+}
+
+// order keeps each user's keys sorted newest first so the default listing can
+// seek instead of sorting the whole map on every call. Only CreatedAt and ID
+// are kept since those never change.
+func (s *InMemoryStore) insertKey(userID string, key Keyset) {
+	keys := s.order[userID]
+	i := sort.Search(len(keys), func(i int) bool { return newestFirst.Precedes(key, keys[i]) })
+	keys = append(keys, Keyset{})
+	copy(keys[i+1:], keys[i:])
+	keys[i] = key
+	s.order[userID] = keys
+}
+
+func (s *InMemoryStore) removeKey(userID string, key Keyset) {
+	keys := s.order[userID]
+	i := sort.Search(len(keys), func(i int) bool { return !newestFirst.Precedes(keys[i], key) })
+	if i < len(keys) && keys[i].ID == key.ID {
+		s.order[userID] = append(keys[:i], keys[i+1:]...)
+	}
+}
+
+func (s *InMemoryStore) restore(snap *snapshot) {
+	for userID, m := range snap.Data {
+		s.data[userID] = m
+		keys := make([]Keyset, 0, len(m))
+		for _, asset := range m {
+			keys = append(keys, Keyset{CreatedAt: asset.CreatedAt, ID: asset.ID})
+			s.countTags(userID, asset.Tags, 1)
+			s.index.put(userID, asset)
+		}
+		sort.Slice(keys, func(i, j int) bool { return newestFirst.Precedes(keys[i], keys[j]) })
+		s.order[userID] = keys
+	}
+	for userID, c := range snap.Counters {
+		s.counters[userID] = c
+	}
+	for userID, m := range snap.Trash {
+		s.trash[userID] = m
+	}
+	for _, h := range snap.History {
+		s.history[favKey{h.UserID, h.FavID}] = h.Revisions
+	}
+	for _, share := range snap.Shares {
+		s.putShare(share)
+	}
+	for _, c := range snap.Collections {
+		if s.collections[c.UserID] == nil {
+			s.collections[c.UserID] = make(map[string]models.Collection)
+		}
+		s.collections[c.UserID][c.ID] = c.Collection
+		for _, favID := range c.Members {
+			s.collect(c.UserID, c.ID, favID)
+		}
+	}
+}
+
+func (s *InMemoryStore) snapshotLocked() error {
+	if s.journal == nil {
+		return nil
+	}
+	var shares []models.Share
+	for _, byUser := range s.shares {
+		for _, share := range byUser {
+			shares = append(shares, share)
+		}
+	}
+	var collections []snapshotCollection
+	for userID, byID := range s.collections {
+		for _, c := range byID {
+			entry := snapshotCollection{UserID: userID, Collection: c}
+			for favID := range s.members[collKey{userID, c.ID}] {
+				entry.Members = append(entry.Members, favID)
+			}
+			collections = append(collections, entry)
+		}
+	}
+	history := make([]snapshotHistory, 0, len(s.history))
+	for key, revs := range s.history {
+		history = append(history, snapshotHistory{UserID: key.userID, FavID: key.favID, Revisions: revs})
+	}
+	snap := snapshot{Data: s.data, Counters: s.counters, Shares: shares, Trash: s.trash, Collections: collections, History: history}
+	if err := writeSnapshot(s.journal.dir, snap); err != nil {
+		return err
+	}
+	s.sinceSnapshot = 0
+	return s.journal.reset()
+}
+
+func (s *InMemoryStore) snapshotLoop(interval time.Duration) {
+	defer close(s.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.mu.Lock()
+			if s.sinceSnapshot > 0 {
+				if err := s.snapshotLocked(); err != nil {
+					log.Printf("journal: periodic snapshot failed: %v", err)
+				}
+			}
+			s.mu.Unlock()
+		case <-s.stop:
+			return
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package data
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+type migration struct {
+	version    int
+	statements []string
+}
+
+var sqliteMigrations = []migration{
+	{
+		version: 1,
+		statements: []string{
+			`CREATE TABLE favorites (
+				user_id     TEXT    NOT NULL,
+				id          TEXT    NOT NULL,
+				type        TEXT    NOT NULL,
+				description TEXT    NOT NULL DEFAULT '',
+				payload     TEXT    NOT NULL,
+				created_at  INTEGER NOT NULL,
+				PRIMARY KEY (user_id, id)
+			)`,
+			`CREATE INDEX favorites_user_created ON favorites (user_id, created_at DESC, id DESC)`,
+			`CREATE TABLE user_counters (
+				user_id TEXT    PRIMARY KEY,
+				value   INTEGER NOT NULL
+			)`,
+		},
+	},
+	{
+		version: 2,
+		statements: []string{
+			`CREATE INDEX favorites_user_description ON favorites (user_id, description, id)`,
+			`CREATE INDEX favorites_user_type_created ON favorites (user_id, type, created_at DESC, id DESC)`,
+		},
+	},
+	{
+		version: 3,
+		statements: []string{
+			`ALTER TABLE favorites ADD COLUMN updated_at INTEGER NOT NULL DEFAULT 0`,
+			`UPDATE favorites SET updated_at = created_at`,
+		},
+	},
+	{
+		version: 4,
+		statements: []string{
+			`ALTER TABLE favorites ADD COLUMN version INTEGER NOT NULL DEFAULT 1`,
+		},
+	},
+	{
+		version: 5,
+		statements: []string{
+			`CREATE TABLE shares (
+				owner_id    TEXT    NOT NULL,
+				favorite_id TEXT    NOT NULL,
+				user_id     TEXT    NOT NULL,
+				permission  TEXT    NOT NULL,
+				created_at  INTEGER NOT NULL,
+				PRIMARY KEY (owner_id, favorite_id, user_id),
+				FOREIGN KEY (owner_id, favorite_id) REFERENCES favorites (user_id, id) ON DELETE CASCADE
+			)`,
+			`CREATE INDEX shares_user_created ON shares (user_id, created_at DESC, favorite_id DESC)`,
+		},
+	},
+	{
+		version: 6,
+		statements: []string{
+			`CREATE TABLE collections (
+				user_id    TEXT    NOT NULL,
+				id         TEXT    NOT NULL,
+				name       TEXT    NOT NULL,
+				position   INTEGER NOT NULL,
+				created_at INTEGER NOT NULL,
+				updated_at INTEGER NOT NULL,
+				PRIMARY KEY (user_id, id),
+				UNIQUE (user_id, name)
+			)`,
+			`CREATE TABLE collection_items (
+				user_id       TEXT    NOT NULL,
+				collection_id TEXT    NOT NULL,
+				favorite_id   TEXT    NOT NULL,
+				added_at      INTEGER NOT NULL,
+				PRIMARY KEY (user_id, collection_id, favorite_id),
+				FOREIGN KEY (user_id, collection_id) REFERENCES collections (user_id, id) ON DELETE CASCADE,
+				FOREIGN KEY (user_id, favorite_id) REFERENCES favorites (user_id, id) ON DELETE CASCADE
+			)`,
+			`CREATE INDEX collection_items_favorite ON collection_items (user_id, favorite_id)`,
+		},
+	},
+	{
+		version: 7,
+		statements: []string{
+			`ALTER TABLE favorites ADD COLUMN tags TEXT NOT NULL DEFAULT '[]'`,
+			`CREATE TABLE favorite_tags (
+				user_id     TEXT NOT NULL,
+				tag         TEXT NOT NULL,
+				favorite_id TEXT NOT NULL,
+				PRIMARY KEY (user_id, tag, favorite_id),
+				FOREIGN KEY (user_id, favorite_id) REFERENCES favorites (user_id, id) ON DELETE CASCADE
+			)`,
+			`CREATE INDEX favorite_tags_favorite ON favorite_tags (user_id, favorite_id)`,
+		},
+	},
+	{
+		version: 8,
+		statements: []string{
+			`ALTER TABLE favorites ADD COLUMN deleted_at INTEGER NOT NULL DEFAULT 0`,
+			`CREATE INDEX favorites_trash ON favorites (user_id, deleted_at DESC, id DESC) WHERE deleted_at > 0`,
+			`CREATE INDEX favorites_deleted ON favorites (deleted_at) WHERE deleted_at > 0`,
+		},
+	},
+	{
+		version: 9,
+		statements: []string{
+			`ALTER TABLE favorites ADD COLUMN updated_by TEXT NOT NULL DEFAULT ''`,
+			`CREATE TABLE revisions (
+				user_id     TEXT    NOT NULL,
+				favorite_id TEXT    NOT NULL,
+				version     INTEGER NOT NULL,
+				action      TEXT    NOT NULL,
+				author      TEXT    NOT NULL,
+				created_at  INTEGER NOT NULL,
+				changes     TEXT    NOT NULL,
+				reverted_to INTEGER NOT NULL DEFAULT 0,
+				asset       TEXT    NOT NULL,
+				PRIMARY KEY (user_id, favorite_id, version),
+				FOREIGN KEY (user_id, favorite_id) REFERENCES favorites (user_id, id) ON DELETE CASCADE
+			)`,
+		},
+	},
+	{
+		version: 10,
+		statements: []string{
+			`CREATE TABLE api_keys (
+				id           TEXT    PRIMARY KEY,
+				user_id      TEXT    NOT NULL,
+				name         TEXT    NOT NULL,
+				hash         BLOB    NOT NULL,
+				scopes       TEXT    NOT NULL,
+				created_at   INTEGER NOT NULL,
+				last_used_at INTEGER NOT NULL DEFAULT 0
+			)`,
+			`CREATE INDEX api_keys_user_created ON api_keys (user_id, created_at)`,
+		},
+	},
+}
+
+func migrate(db *sql.DB, migrations []migration) error {
+	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    INTEGER PRIMARY KEY,
+		applied_at INTEGER NOT NULL
+	)`); err != nil {
+		return fmt.Errorf("create schema_migrations: %w", err)
+	}
+
+	var current int
+	if err := db.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&current); err != nil {
+		return fmt.Errorf("read schema version: %w", err)
+	}
+
+	for _, m := range migrations {
+		if m.version <= current {
+			continue
+		}
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+		for _, stmt := range m.statements {
+			if _, err := tx.Exec(stmt); err != nil {
+				tx.Rollback()
+				return fmt.Errorf("migration %d: %w", m.version, err)
+			}
+		}
+		if _, err := tx.Exec(`INSERT INTO schema_migrations (version, applied_at) VALUES (?, strftime('%s','now'))`, m.version); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migration %d: %w", m.version, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("migration %d: %w", m.version, err)
+		}
+	}
+	return nil
+}
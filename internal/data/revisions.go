@@ -0,0 +1,65 @@
+package data
+
+import (
+	"encoding/json"
+	"reflect"
+	"slices"
+
+	"github.com/Zisimopoulou/platform-go-challenge/internal/models"
+)
+
+// newRevision describes the change from prev, the favorite's latest recorded
+// revision if it has one, to asset.
+func newRevision(prev *models.Revision, asset models.RawAsset, action models.RevisionAction) models.Revision {
+	rev := models.Revision{
+		Version: asset.Version,
+		Action:  action,
+		Author:  asset.UpdatedBy,
+		Time:    asset.UpdatedAt,
+		Asset:   asset,
+	}
+	if prev != nil {
+		rev.Changes = changedFields(prev.Asset, asset)
+	}
+	return rev
+}
+
+func changedFields(a, b models.RawAsset) []string {
+	var changed []string
+	if a.Type != b.Type {
+		changed = append(changed, "type")
+	}
+	if a.Description != b.Description {
+		changed = append(changed, "description")
+	}
+	if !slices.Equal(a.Tags, b.Tags) {
+		changed = append(changed, "tags")
+	}
+	if !sameJSON(a.Payload, b.Payload) {
+		changed = append(changed, "payload")
+	}
+	return changed
+}
+
+// sameJSON compares payloads by their JSON form, since one side may be a
+// typed struct and the other the map it decodes to.
+func sameJSON(a, b interface{}) bool {
+	var av, bv interface{}
+	if raw, err := json.Marshal(a); err != nil || json.Unmarshal(raw, &av) != nil {
+		return false
+	}
+	if raw, err := json.Marshal(b); err != nil || json.Unmarshal(raw, &bv) != nil {
+		return false
+	}
+	return reflect.DeepEqual(av, bv)
+}
+
+// pageRevisions returns the newest-first page of revs, which are kept
+// oldest first.
+func pageRevisions(revs []models.Revision, limit, offset int) []models.Revision {
+	page := []models.Revision{}
+	for i := len(revs) - 1 - offset; i >= 0 && len(page) < limit; i-- {
+		page = append(page, revs[i])
+	}
+	return page
+}
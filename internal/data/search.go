@@ -0,0 +1,284 @@
+package data
+
+import (
+	"encoding/json"
+	"html"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/Zisimopoulou/platform-go-challenge/internal/models"
+)
+
+const snippetRadius = 60
+
+var fieldWeights = map[string]float64{
+	"description": 2,
+	"title":       2,
+	"text":        1.5,
+}
+
+type indexedDoc struct {
+	fields map[string]string
+	length int
+}
+
+type userIndex struct {
+	postings map[string]map[string]float64
+	docs     map[string]indexedDoc
+	totalLen int
+}
+
+// searchIndex is an in-process inverted index over each user's favorites.
+// Stores keep it in step with their data on every mutation.
+type searchIndex struct {
+	mu    sync.RWMutex
+	users map[string]*userIndex
+}
+
+func newSearchIndex() *searchIndex {
+	return &searchIndex{users: make(map[string]*userIndex)}
+}
+
+func (ix *searchIndex) put(userID string, asset models.RawAsset) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	u, ok := ix.users[userID]
+	if !ok {
+		u = &userIndex{postings: make(map[string]map[string]float64), docs: make(map[string]indexedDoc)}
+		ix.users[userID] = u
+	}
+	u.remove(asset.ID)
+
+	doc := indexedDoc{fields: searchableText(asset)}
+	for field, text := range doc.fields {
+		weight := fieldWeights[field]
+		if weight == 0 {
+			weight = 1
+		}
+		for _, term := range tokenize(text) {
+			if u.postings[term] == nil {
+				u.postings[term] = make(map[string]float64)
+			}
+			u.postings[term][asset.ID] += weight
+			doc.length++
+		}
+	}
+	u.docs[asset.ID] = doc
+	u.totalLen += doc.length
+}
+
+func (ix *searchIndex) remove(userID, favID string) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	if u, ok := ix.users[userID]; ok {
+		u.remove(favID)
+	}
+}
+
+func (u *userIndex) remove(favID string) {
+	doc, ok := u.docs[favID]
+	if !ok {
+		return
+	}
+	for _, text := range doc.fields {
+		for _, term := range tokenize(text) {
+			delete(u.postings[term], favID)
+			if len(u.postings[term]) == 0 {
+				delete(u.postings, term)
+			}
+		}
+	}
+	u.totalLen -= doc.length
+	delete(u.docs, favID)
+}
+
+type scoredDoc struct {
+	id    string
+	score float64
+}
+
+// search ranks the user's favorites with BM25 over the weighted term
+// frequencies. Any query term may match; documents matching more (and rarer)
+// terms rank higher.
+func (ix *searchIndex) search(userID, query string) ([]scoredDoc, []string) {
+	terms := uniqueTerms(query)
+
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+
+	u, ok := ix.users[userID]
+	if !ok || len(terms) == 0 || len(u.docs) == 0 {
+		return nil, terms
+	}
+
+	const k1, b = 1.2, 0.75
+	n := float64(len(u.docs))
+	avgLen := float64(u.totalLen) / n
+	scores := make(map[string]float64)
+	for _, term := range terms {
+		postings := u.postings[term]
+		if len(postings) == 0 {
+			continue
+		}
+		idf := math.Log(1 + (n-float64(len(postings))+0.5)/(float64(len(postings))+0.5))
+		for id, tf := range postings {
+			norm := 1 - b + b*float64(u.docs[id].length)/avgLen
+			scores[id] += idf * tf * (k1 + 1) / (tf + k1*norm)
+		}
+	}
+
+	ranked := make([]scoredDoc, 0, len(scores))
+	for id, score := range scores {
+		ranked = append(ranked, scoredDoc{id: id, score: score})
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].score != ranked[j].score {
+			return ranked[i].score > ranked[j].score
+		}
+		return ranked[i].id > ranked[j].id
+	})
+	return ranked, terms
+}
+
+func (ix *searchIndex) highlights(userID, favID string, terms []string) map[string]string {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+
+	u, ok := ix.users[userID]
+	if !ok {
+		return nil
+	}
+	out := make(map[string]string)
+	for field, text := range u.docs[favID].fields {
+		if snippet, ok := highlight(text, terms); ok {
+			out[field] = snippet
+		}
+	}
+	return out
+}
+
+func searchResults(ix *searchIndex, userID, query string, limit int, lookup func([]string) (map[string]models.RawAsset, error)) ([]models.SearchResult, int, error) {
+	ranked, terms := ix.search(userID, query)
+	total := len(ranked)
+	if len(ranked) > limit {
+		ranked = ranked[:limit]
+	}
+
+	ids := make([]string, len(ranked))
+	for i, doc := range ranked {
+		ids[i] = doc.id
+	}
+	assets, err := lookup(ids)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	results := make([]models.SearchResult, 0, len(ranked))
+	for _, doc := range ranked {
+		asset, ok := assets[doc.id]
+		if !ok {
+			continue
+		}
+		results = append(results, models.SearchResult{
+			Favorite:   models.Favorite{FavoriteID: doc.id, Asset: asset},
+			Score:      math.Round(doc.score*1000) / 1000,
+			Highlights: ix.highlights(userID, doc.id, terms),
+		})
+	}
+	return results, total, nil
+}
+
+func searchableText(asset models.RawAsset) map[string]string {
+	fields := make(map[string]string)
+	if asset.Description != "" {
+		fields["description"] = asset.Description
+	}
+
+	var payload map[string]interface{}
+	if raw, err := json.Marshal(asset.Payload); err == nil {
+		json.Unmarshal(raw, &payload)
+	}
+	info, _ := models.LookupAssetType(asset.Type)
+	for _, name := range info.SearchFields {
+		if s, ok := payload[name].(string); ok && s != "" {
+			fields[name] = s
+		}
+	}
+	return fields
+}
+
+func tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+func uniqueTerms(query string) []string {
+	seen := make(map[string]bool)
+	var terms []string
+	for _, term := range tokenize(query) {
+		if !seen[term] {
+			seen[term] = true
+			terms = append(terms, term)
+		}
+	}
+	return terms
+}
+
+// highlight returns an HTML-escaped window of text around the first matching
+// term, with every matching token wrapped in <mark>.
+func highlight(text string, terms []string) (string, bool) {
+	runes := []rune(text)
+	type span struct{ start, end int }
+	var matches []span
+
+	for i := 0; i < len(runes); {
+		if !unicode.IsLetter(runes[i]) && !unicode.IsDigit(runes[i]) {
+			i++
+			continue
+		}
+		j := i
+		for j < len(runes) && (unicode.IsLetter(runes[j]) || unicode.IsDigit(runes[j])) {
+			j++
+		}
+		token := strings.ToLower(string(runes[i:j]))
+		for _, term := range terms {
+			if token == term {
+				matches = append(matches, span{i, j})
+				break
+			}
+		}
+		i = j
+	}
+	if len(matches) == 0 {
+		return "", false
+	}
+
+	from := max(matches[0].start-snippetRadius, 0)
+	to := min(matches[0].end+snippetRadius, len(runes))
+
+	var sb strings.Builder
+	if from > 0 {
+		sb.WriteString("…")
+	}
+	pos := from
+	for _, m := range matches {
+		if m.start < from || m.end > to {
+			continue
+		}
+		sb.WriteString(html.EscapeString(string(runes[pos:m.start])))
+		sb.WriteString("<mark>")
+		sb.WriteString(html.EscapeString(string(runes[m.start:m.end])))
+		sb.WriteString("</mark>")
+		pos = m.end
+	}
+	sb.WriteString(html.EscapeString(string(runes[pos:to])))
+	if to < len(runes) {
+		sb.WriteString("…")
+	}
+	return sb.String(), true
+}
@@ -0,0 +1,1168 @@
+package data
+
+import (
+	"cmp"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Zisimopoulou/platform-go-challenge/internal/models"
+	"modernc.org/sqlite"
+)
+
+func init() {
+	// SQLite's lower() only folds ASCII; go_lower folds like strings.ToLower,
+	// so filters match the in-memory store for every script.
+	sqlite.MustRegisterDeterministicScalarFunction("go_lower", 1, func(_ *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+		s, _ := args[0].(string)
+		return strings.ToLower(s), nil
+	})
+}
+
+type SQLiteStore struct {
+	db    *sql.DB
+	index *searchIndex
+	// writeMu orders the writes that touch the search index, so an index
+	// update never lands after one for a newer version of the favorite.
+	writeMu sync.Mutex
+}
+
+func NewSQLiteStore(path string) (*SQLiteStore, error) {
+	dsn := fmt.Sprintf("file:%s?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_pragma=foreign_keys(1)", path)
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+	// SQLite allows a single writer; serializing through one connection
+	// avoids SQLITE_BUSY under concurrent requests.
+	db.SetMaxOpenConns(1)
+
+	if err := migrate(db, sqliteMigrations); err != nil {
+		db.Close()
+		return nil, err
+	}
+	s := &SQLiteStore{db: db, index: newSearchIndex()}
+	if err := s.buildIndex(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *SQLiteStore) buildIndex() error {
+	rows, err := s.db.Query(`SELECT user_id, `+assetColumns+` FROM favorites WHERE deleted_at = 0`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var userID string
+		asset, err := scanAsset(prefixedScanner{rows, []interface{}{&userID}})
+		if err != nil {
+			return err
+		}
+		s.index.put(userID, asset)
+	}
+	return rows.Err()
+}
+
+func (s *SQLiteStore) Close() error {
+	return s.db.Close()
+}
+
+// DB is the migrated database, for stores of other packages that keep their
+// tables next to the favorites, such as auth.SQLiteAPIKeyStore.
+func (s *SQLiteStore) DB() *sql.DB {
+	return s.db
+}
+
+func (s *SQLiteStore) Add(userID string, asset models.RawAsset) (string, error) {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	tx, err := s.db.Begin()
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	asset, err = insertAsset(tx, userID, asset)
+	if err != nil {
+		return "", err
+	}
+	if err := tx.Commit(); err != nil {
+		return "", err
+	}
+	s.index.put(userID, asset)
+	return asset.ID, nil
+}
+
+// querier is the part of *sql.DB and *sql.Tx the write helpers need, so the
+// same statements serve single requests and batches.
+type querier interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// insertAsset must run inside a transaction so the counter bump and the
+// insert commit together.
+func insertAsset(q querier, userID string, asset models.RawAsset) (models.RawAsset, error) {
+	payload, err := json.Marshal(asset.Payload)
+	if err != nil {
+		return models.RawAsset{}, fmt.Errorf("encode payload: %w", err)
+	}
+	if err := checkTagLimit(q, userID, asset.Tags); err != nil {
+		return models.RawAsset{}, err
+	}
+
+	var counter int64
+	err = q.QueryRow(`INSERT INTO user_counters (user_id, value) VALUES (?, 1)
+		ON CONFLICT (user_id) DO UPDATE SET value = value + 1
+		RETURNING value`, userID).Scan(&counter)
+	if err != nil {
+		return models.RawAsset{}, err
+	}
+
+	now := time.Now().UTC()
+	favID := fmt.Sprintf("%s-%s-%d", now.Format("20060102T150405"), userID, counter)
+
+	_, err = q.Exec(`INSERT INTO favorites (user_id, id, type, description, payload, created_at, updated_at, version, tags, updated_by)
+		VALUES (?, ?, ?, ?, ?, ?, ?, 1, ?, ?)`,
+		userID, favID, string(asset.Type), asset.Description, string(payload), now.UnixNano(), now.UnixNano(),
+		encodeTags(asset.Tags), userID)
+	if err != nil {
+		return models.RawAsset{}, err
+	}
+	if err := setTags(q, userID, favID, asset.Tags); err != nil {
+		return models.RawAsset{}, err
+	}
+
+	asset.ID = favID
+	asset.CreatedAt = time.Unix(0, now.UnixNano()).UTC()
+	asset.UpdatedAt = asset.CreatedAt
+	asset.Version = 1
+	asset.UpdatedBy = userID
+	return asset, recordRevision(q, userID, asset, models.RevisionCreated, 0)
+}
+
+func (s *SQLiteStore) List(userID string, q ListQuery) ([]models.Favorite, int, error) {
+	where, args := sqliteFilter(userID, q.Filter)
+
+	var totalCount int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM favorites WHERE `+where, args...).Scan(&totalCount); err != nil {
+		return nil, 0, err
+	}
+
+	column, forward, backward := "created_at", "DESC", "ASC"
+	if q.Sort == SortByDescription {
+		column = "description"
+	}
+	if q.Ascending {
+		forward, backward = backward, forward
+	}
+
+	var (
+		order   = forward
+		key     *Keyset
+		reverse bool
+	)
+	switch {
+	case q.After != nil:
+		key = q.After
+	case q.Before != nil:
+		key, order, reverse = q.Before, backward, true
+	default:
+		if q.Offset >= totalCount {
+			return []models.Favorite{}, totalCount, nil
+		}
+	}
+	if key != nil {
+		cmp := "<"
+		if order == "ASC" {
+			cmp = ">"
+		}
+		var value interface{} = key.CreatedAt.UnixNano()
+		if q.Sort == SortByDescription {
+			value = key.Description
+		}
+		where += fmt.Sprintf(" AND (%[1]s %[2]s ? OR (%[1]s = ? AND id %[2]s ?))", column, cmp)
+		args = append(args, value, value, key.ID)
+	}
+
+	query := fmt.Sprintf(`SELECT `+assetColumns+` FROM favorites
+		WHERE %s
+		ORDER BY %s %s, id %s
+		LIMIT ?`, where, column, order, order)
+	args = append(args, q.Limit)
+	if key == nil {
+		query += ` OFFSET ?`
+		args = append(args, q.Offset)
+	}
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	favorites := make([]models.Favorite, 0, q.Limit)
+	for rows.Next() {
+		asset, err := scanAsset(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		favorites = append(favorites, models.Favorite{FavoriteID: asset.ID, Asset: asset})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+	if reverse {
+		slices.Reverse(favorites)
+	}
+	return favorites, totalCount, nil
+}
+
+func sqliteFilter(userID string, f ListFilter) (string, []interface{}) {
+	where := []string{"user_id = ?", "deleted_at = 0"}
+	args := []interface{}{userID}
+	if len(f.Types) > 0 {
+		where = append(where, "type IN (?"+strings.Repeat(", ?", len(f.Types)-1)+")")
+		for _, t := range f.Types {
+			args = append(args, string(t))
+		}
+	}
+	if !f.CreatedAfter.IsZero() {
+		where = append(where, "created_at >= ?")
+		args = append(args, f.CreatedAfter.UnixNano())
+	}
+	if !f.CreatedBefore.IsZero() {
+		where = append(where, "created_at < ?")
+		args = append(args, f.CreatedBefore.UnixNano())
+	}
+	if f.Description != "" {
+		where = append(where, "instr(go_lower(description), go_lower(?)) > 0")
+		args = append(args, f.Description)
+	}
+	if f.Collection != "" {
+		where = append(where, "id IN (SELECT favorite_id FROM collection_items WHERE user_id = ? AND collection_id = ?)")
+		args = append(args, userID, f.Collection)
+	}
+	if len(f.Tags) > 0 {
+		clause := "id IN (SELECT favorite_id FROM favorite_tags WHERE user_id = ? AND tag IN (?" + strings.Repeat(", ?", len(f.Tags)-1) + ")"
+		args = append(args, userID)
+		for _, tag := range f.Tags {
+			args = append(args, tag)
+		}
+		if f.AllTags {
+			clause += " GROUP BY favorite_id HAVING COUNT(*) = ?"
+			args = append(args, len(f.Tags))
+		}
+		where = append(where, clause+")")
+	}
+	return strings.Join(where, " AND "), args
+}
+
+func (s *SQLiteStore) Tags(userID string) ([]models.TagCount, error) {
+	rows, err := s.db.Query(`SELECT tag, COUNT(*) AS n FROM favorite_tags t `+liveTags+` WHERE t.user_id = ?
+		GROUP BY tag ORDER BY n DESC, tag`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	tags := []models.TagCount{}
+	for rows.Next() {
+		var t models.TagCount
+		if err := rows.Scan(&t.Tag, &t.Count); err != nil {
+			return nil, err
+		}
+		tags = append(tags, t)
+	}
+	return tags, rows.Err()
+}
+
+// favorite_tags mirrors the tags column so tag filters and counts can use an
+// index; the column is what assets are read from.
+func setTags(q querier, userID, favID string, tags []string) error {
+	if _, err := q.Exec(`DELETE FROM favorite_tags WHERE user_id = ? AND favorite_id = ?`, userID, favID); err != nil {
+		return err
+	}
+	for _, tag := range tags {
+		if _, err := q.Exec(`INSERT OR IGNORE INTO favorite_tags (user_id, tag, favorite_id) VALUES (?, ?, ?)`,
+			userID, tag, favID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// liveTags leaves out the tags of trashed favorites, which keep their rows so
+// restoring them needs no bookkeeping.
+const liveTags = `JOIN favorites f ON f.user_id = t.user_id AND f.id = t.favorite_id AND f.deleted_at = 0`
+
+// checkTagLimit counts tags the write would drop, like the in-memory store.
+func checkTagLimit(q querier, userID string, tags []string) error {
+	if len(tags) == 0 {
+		return nil
+	}
+	args := []interface{}{}
+	for _, tag := range tags {
+		args = append(args, tag)
+	}
+	args = append(args, userID)
+	var distinct, known int
+	err := q.QueryRow(`SELECT COUNT(DISTINCT tag), COUNT(DISTINCT CASE WHEN tag IN (?`+strings.Repeat(", ?", len(tags)-1)+`) THEN tag END)
+		FROM favorite_tags t `+liveTags+` WHERE t.user_id = ?`, args...).Scan(&distinct, &known)
+	if err != nil {
+		return err
+	}
+	if distinct+len(slices.Compact(slices.Sorted(slices.Values(tags))))-known > MaxTagsPerUser {
+		return ErrTagLimit
+	}
+	return nil
+}
+
+func encodeTags(tags []string) string {
+	if len(tags) == 0 {
+		return "[]"
+	}
+	raw, _ := json.Marshal(tags)
+	return string(raw)
+}
+
+func (s *SQLiteStore) Get(userID, favID string) (models.RawAsset, error) {
+	asset, err := scanAsset(s.db.QueryRow(`SELECT `+assetColumns+` FROM favorites WHERE user_id = ? AND id = ? AND deleted_at = 0`, userID, favID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.RawAsset{}, ErrNotFound
+	}
+	return asset, err
+}
+
+func (s *SQLiteStore) Users() ([]models.UserSummary, error) {
+	rows, err := s.db.Query(`SELECT user_id, COUNT(*) FROM favorites WHERE deleted_at = 0 GROUP BY user_id ORDER BY user_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	users := []models.UserSummary{}
+	for rows.Next() {
+		var u models.UserSummary
+		if err := rows.Scan(&u.UserID, &u.Favorites); err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+	return users, rows.Err()
+}
+
+func (s *SQLiteStore) PutShare(share models.Share) (models.Share, error) {
+	share.CreatedAt = time.Unix(0, time.Now().UTC().UnixNano()).UTC()
+	res, err := s.db.Exec(`INSERT INTO shares (owner_id, favorite_id, user_id, permission, created_at)
+		SELECT ?, ?, ?, ?, ? WHERE EXISTS (SELECT 1 FROM favorites WHERE user_id = ? AND id = ? AND deleted_at = 0)
+		ON CONFLICT (owner_id, favorite_id, user_id) DO UPDATE SET permission = excluded.permission, created_at = excluded.created_at`,
+		share.OwnerID, share.FavoriteID, share.UserID, string(share.Permission), share.CreatedAt.UnixNano(),
+		share.OwnerID, share.FavoriteID)
+	if err != nil {
+		return models.Share{}, err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return models.Share{}, err
+	} else if n == 0 {
+		return models.Share{}, ErrNotFound
+	}
+	return share, nil
+}
+
+const shareColumns = `owner_id, favorite_id, user_id, permission, created_at`
+
+func scanShare(row rowScanner) (models.Share, error) {
+	var (
+		share      models.Share
+		permission string
+		createdAt  int64
+	)
+	if err := row.Scan(&share.OwnerID, &share.FavoriteID, &share.UserID, &permission, &createdAt); err != nil {
+		return models.Share{}, err
+	}
+	share.Permission = models.Permission(permission)
+	share.CreatedAt = time.Unix(0, createdAt).UTC()
+	return share, nil
+}
+
+func (s *SQLiteStore) GetShare(ownerID, favID, userID string) (models.Share, error) {
+	share, err := scanShare(s.db.QueryRow(`SELECT `+shareColumns+` FROM shares
+		WHERE owner_id = ? AND favorite_id = ? AND user_id = ?
+		AND EXISTS (SELECT 1 FROM favorites WHERE user_id = owner_id AND id = favorite_id AND deleted_at = 0)`,
+		ownerID, favID, userID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Share{}, ErrNotFound
+	}
+	return share, err
+}
+
+func (s *SQLiteStore) DeleteShare(ownerID, favID, userID string) error {
+	res, err := s.db.Exec(`DELETE FROM shares WHERE owner_id = ? AND favorite_id = ? AND user_id = ?`, ownerID, favID, userID)
+	return notFoundIfNone(res, err)
+}
+
+func (s *SQLiteStore) ListShares(ownerID, favID string) ([]models.Share, error) {
+	if found, err := exists(s.db, ownerID, favID); err != nil {
+		return nil, err
+	} else if !found {
+		return nil, ErrNotFound
+	}
+	rows, err := s.db.Query(`SELECT `+shareColumns+` FROM shares
+		WHERE owner_id = ? AND favorite_id = ? ORDER BY user_id`, ownerID, favID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	shares := []models.Share{}
+	for rows.Next() {
+		share, err := scanShare(rows)
+		if err != nil {
+			return nil, err
+		}
+		shares = append(shares, share)
+	}
+	return shares, rows.Err()
+}
+
+func (s *SQLiteStore) SharedWith(userID string) ([]models.SharedFavorite, error) {
+	rows, err := s.db.Query(`SELECT s.owner_id, s.favorite_id, s.user_id, s.permission, s.created_at,
+			f.`+strings.ReplaceAll(assetColumns, ", ", ", f.")+`
+		FROM shares s JOIN favorites f ON f.user_id = s.owner_id AND f.id = s.favorite_id AND f.deleted_at = 0
+		WHERE s.user_id = ? ORDER BY s.created_at DESC, s.favorite_id DESC`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	shared := []models.SharedFavorite{}
+	for rows.Next() {
+		var (
+			sf         models.SharedFavorite
+			permission string
+			createdAt  int64
+		)
+		sf.Asset, err = scanAsset(prefixedScanner{rows, []interface{}{&sf.OwnerID, &sf.FavoriteID, &sf.UserID, &permission, &createdAt}})
+		if err != nil {
+			return nil, err
+		}
+		sf.Permission = models.Permission(permission)
+		sf.CreatedAt = time.Unix(0, createdAt).UTC()
+		shared = append(shared, sf)
+	}
+	return shared, rows.Err()
+}
+
+const collectionColumns = `id, name, position, created_at, updated_at,
+	(SELECT COUNT(*) FROM collection_items i
+		JOIN favorites f ON f.user_id = i.user_id AND f.id = i.favorite_id AND f.deleted_at = 0
+		WHERE i.user_id = c.user_id AND i.collection_id = c.id)`
+
+func scanCollection(row rowScanner) (models.Collection, error) {
+	var (
+		c                    models.Collection
+		createdAt, updatedAt int64
+	)
+	if err := row.Scan(&c.ID, &c.Name, &c.Position, &createdAt, &updatedAt, &c.Favorites); err != nil {
+		return models.Collection{}, err
+	}
+	c.CreatedAt = time.Unix(0, createdAt).UTC()
+	c.UpdatedAt = time.Unix(0, updatedAt).UTC()
+	return c, nil
+}
+
+func getCollection(q querier, userID, collID string) (models.Collection, error) {
+	c, err := scanCollection(q.QueryRow(`SELECT `+collectionColumns+` FROM collections c WHERE user_id = ? AND id = ?`, userID, collID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Collection{}, ErrNotFound
+	}
+	return c, err
+}
+
+func nameTaken(q querier, userID, name, exceptID string) (bool, error) {
+	var taken bool
+	err := q.QueryRow(`SELECT EXISTS (SELECT 1 FROM collections WHERE user_id = ? AND name = ? AND id != ?)`,
+		userID, name, exceptID).Scan(&taken)
+	return taken, err
+}
+
+func (s *SQLiteStore) CreateCollection(userID, name string) (models.Collection, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return models.Collection{}, err
+	}
+	defer tx.Rollback()
+
+	if taken, err := nameTaken(tx, userID, name, ""); err != nil {
+		return models.Collection{}, err
+	} else if taken {
+		return models.Collection{}, ErrCollectionExists
+	}
+	now := time.Now().UTC().UnixNano()
+	id := newCollectionID()
+	_, err = tx.Exec(`INSERT INTO collections (user_id, id, name, position, created_at, updated_at)
+		SELECT ?, ?, ?, COALESCE(MAX(position) + 1, 0), ?, ? FROM collections WHERE user_id = ?`,
+		userID, id, name, now, now, userID)
+	if err != nil {
+		return models.Collection{}, err
+	}
+	c, err := getCollection(tx, userID, id)
+	if err != nil {
+		return models.Collection{}, err
+	}
+	return c, tx.Commit()
+}
+
+func (s *SQLiteStore) GetCollection(userID, collID string) (models.Collection, error) {
+	return getCollection(s.db, userID, collID)
+}
+
+func (s *SQLiteStore) ListCollections(userID string) ([]models.Collection, error) {
+	rows, err := s.db.Query(`SELECT `+collectionColumns+` FROM collections c
+		WHERE user_id = ? ORDER BY position, created_at`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	list := []models.Collection{}
+	for rows.Next() {
+		c, err := scanCollection(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, c)
+	}
+	return list, rows.Err()
+}
+
+func (s *SQLiteStore) RenameCollection(userID, collID, name string) (models.Collection, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return models.Collection{}, err
+	}
+	defer tx.Rollback()
+
+	if _, err := getCollection(tx, userID, collID); err != nil {
+		return models.Collection{}, err
+	}
+	if taken, err := nameTaken(tx, userID, name, collID); err != nil {
+		return models.Collection{}, err
+	} else if taken {
+		return models.Collection{}, ErrCollectionExists
+	}
+	if _, err := tx.Exec(`UPDATE collections SET name = ?, updated_at = ? WHERE user_id = ? AND id = ?`,
+		name, time.Now().UTC().UnixNano(), userID, collID); err != nil {
+		return models.Collection{}, err
+	}
+	c, err := getCollection(tx, userID, collID)
+	if err != nil {
+		return models.Collection{}, err
+	}
+	return c, tx.Commit()
+}
+
+func (s *SQLiteStore) ReorderCollections(userID string, collIDs []string) ([]models.Collection, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`SELECT id, position FROM collections WHERE user_id = ?`, userID)
+	if err != nil {
+		return nil, err
+	}
+	current := make(map[string]models.Collection)
+	for rows.Next() {
+		var c models.Collection
+		if err := rows.Scan(&c.ID, &c.Position); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		current[c.ID] = c
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if !isPermutation(collIDs, current) {
+		return nil, ErrInvalidOrder
+	}
+
+	now := time.Now().UTC().UnixNano()
+	for i, id := range collIDs {
+		if current[id].Position == i {
+			continue
+		}
+		if _, err := tx.Exec(`UPDATE collections SET position = ?, updated_at = ? WHERE user_id = ? AND id = ?`,
+			i, now, userID, id); err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return s.ListCollections(userID)
+}
+
+func (s *SQLiteStore) DeleteCollection(userID, collID string) error {
+	res, err := s.db.Exec(`DELETE FROM collections WHERE user_id = ? AND id = ?`, userID, collID)
+	return notFoundIfNone(res, err)
+}
+
+func (s *SQLiteStore) AddToCollection(userID, collID, favID string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := getCollection(tx, userID, collID); err != nil {
+		return err
+	}
+	if found, err := exists(tx, userID, favID); err != nil {
+		return err
+	} else if !found {
+		return ErrNotFound
+	}
+	if _, err := tx.Exec(`INSERT INTO collection_items (user_id, collection_id, favorite_id, added_at)
+		VALUES (?, ?, ?, ?) ON CONFLICT DO NOTHING`, userID, collID, favID, time.Now().UTC().UnixNano()); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *SQLiteStore) RemoveFromCollection(userID, collID, favID string) error {
+	res, err := s.db.Exec(`DELETE FROM collection_items WHERE user_id = ? AND collection_id = ? AND favorite_id = ?`,
+		userID, collID, favID)
+	return notFoundIfNone(res, err)
+}
+
+// notFoundIfNone turns a statement that touched no rows into ErrNotFound.
+func notFoundIfNone(res sql.Result, err error) error {
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *SQLiteStore) Delete(userID, favID string, expectedVersion int64) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := trashAsset(tx, userID, favID, expectedVersion); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	s.index.remove(userID, favID)
+	return nil
+}
+
+// trashAsset only stamps deleted_at, so shares, collection items and tags
+// stay in place for a restore.
+func trashAsset(q querier, userID, favID string, expectedVersion int64) error {
+	now := time.Now().UTC().UnixNano()
+	row := q.QueryRow(`UPDATE favorites SET deleted_at = ?, updated_at = ?, updated_by = ?, version = version + 1
+		WHERE user_id = ? AND id = ? AND deleted_at = 0 AND (? = 0 OR version = ?)
+		RETURNING `+assetColumns,
+		now, now, userID, userID, favID, expectedVersion, expectedVersion)
+	asset, err := updated(q, userID, favID, row)
+	if err != nil {
+		return err
+	}
+	return recordRevision(q, userID, asset, models.RevisionDeleted, 0)
+}
+
+func (s *SQLiteStore) ListTrash(userID string, limit, offset int) ([]models.Favorite, int, error) {
+	var total int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM favorites WHERE user_id = ? AND deleted_at > 0`, userID).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+	rows, err := s.db.Query(`SELECT `+assetColumns+` FROM favorites WHERE user_id = ? AND deleted_at > 0
+		ORDER BY deleted_at DESC, id DESC LIMIT ? OFFSET ?`, userID, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+	trashed := []models.Favorite{}
+	for rows.Next() {
+		asset, err := scanAsset(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		trashed = append(trashed, models.Favorite{FavoriteID: asset.ID, Asset: asset})
+	}
+	return trashed, total, rows.Err()
+}
+
+func (s *SQLiteStore) Restore(userID, favID string) (models.RawAsset, error) {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	tx, err := s.db.Begin()
+	if err != nil {
+		return models.RawAsset{}, err
+	}
+	defer tx.Rollback()
+
+	var tags string
+	err = tx.QueryRow(`SELECT tags FROM favorites WHERE user_id = ? AND id = ? AND deleted_at > 0`, userID, favID).Scan(&tags)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.RawAsset{}, ErrNotFound
+	}
+	if err != nil {
+		return models.RawAsset{}, err
+	}
+	var list []string
+	if err := json.Unmarshal([]byte(tags), &list); err != nil {
+		return models.RawAsset{}, err
+	}
+	if err := checkTagLimit(tx, userID, list); err != nil {
+		return models.RawAsset{}, err
+	}
+	asset, err := scanAsset(tx.QueryRow(`UPDATE favorites SET deleted_at = 0, updated_at = ?, updated_by = ?, version = version + 1
+		WHERE user_id = ? AND id = ? RETURNING `+assetColumns, time.Now().UTC().UnixNano(), userID, userID, favID))
+	if err != nil {
+		return models.RawAsset{}, err
+	}
+	if err := recordRevision(tx, userID, asset, models.RevisionRestored, 0); err != nil {
+		return models.RawAsset{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return models.RawAsset{}, err
+	}
+	s.index.put(userID, asset)
+	return asset, nil
+}
+
+// Purge relies on ON DELETE CASCADE to drop the favorite's shares,
+// collection items and tags.
+func (s *SQLiteStore) Purge(userID, favID string) error {
+	res, err := s.db.Exec(`DELETE FROM favorites WHERE user_id = ? AND id = ? AND deleted_at > 0`, userID, favID)
+	return notFoundIfNone(res, err)
+}
+
+func (s *SQLiteStore) PurgeTrash(deletedBefore time.Time) (int, error) {
+	res, err := s.db.Exec(`DELETE FROM favorites WHERE deleted_at > 0 AND deleted_at < ?`, deletedBefore.UnixNano())
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+func (s *SQLiteStore) UpdateDescription(actor, userID, favID, desc string, expectedVersion int64) (models.RawAsset, error) {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	tx, err := s.db.Begin()
+	if err != nil {
+		return models.RawAsset{}, err
+	}
+	defer tx.Rollback()
+
+	row := tx.QueryRow(`UPDATE favorites SET description = ?, updated_at = ?, updated_by = ?, version = version + 1
+		WHERE user_id = ? AND id = ? AND deleted_at = 0 AND (? = 0 OR version = ?)
+		RETURNING `+assetColumns,
+		desc, time.Now().UTC().UnixNano(), cmp.Or(actor, userID), userID, favID, expectedVersion, expectedVersion)
+	asset, err := updated(tx, userID, favID, row)
+	if err != nil {
+		return models.RawAsset{}, err
+	}
+	if err := recordRevision(tx, userID, asset, models.RevisionUpdated, 0); err != nil {
+		return models.RawAsset{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return models.RawAsset{}, err
+	}
+	s.index.put(userID, asset)
+	return asset, nil
+}
+
+func (s *SQLiteStore) Update(userID string, asset models.RawAsset, expectedVersion int64) (models.RawAsset, error) {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	tx, err := s.db.Begin()
+	if err != nil {
+		return models.RawAsset{}, err
+	}
+	defer tx.Rollback()
+
+	asset, err = updateAsset(tx, userID, asset, expectedVersion)
+	if err != nil {
+		return models.RawAsset{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return models.RawAsset{}, err
+	}
+	s.index.put(userID, asset)
+	return asset, nil
+}
+
+// updateAsset must run inside a transaction so the tags table follows the
+// row.
+func updateAsset(q querier, userID string, asset models.RawAsset, expectedVersion int64) (models.RawAsset, error) {
+	payload, err := json.Marshal(asset.Payload)
+	if err != nil {
+		return models.RawAsset{}, fmt.Errorf("encode payload: %w", err)
+	}
+	if err := checkTagLimit(q, userID, asset.Tags); err != nil {
+		return models.RawAsset{}, err
+	}
+	row := q.QueryRow(`UPDATE favorites SET type = ?, description = ?, payload = ?, tags = ?, updated_at = ?, updated_by = ?, version = version + 1
+		WHERE user_id = ? AND id = ? AND deleted_at = 0 AND (? = 0 OR version = ?)
+		RETURNING `+assetColumns,
+		string(asset.Type), asset.Description, string(payload), encodeTags(asset.Tags), time.Now().UTC().UnixNano(),
+		cmp.Or(asset.UpdatedBy, userID), userID, asset.ID, expectedVersion, expectedVersion)
+	updatedAsset, err := updated(q, userID, asset.ID, row)
+	if err != nil {
+		return models.RawAsset{}, err
+	}
+	if err := setTags(q, userID, asset.ID, asset.Tags); err != nil {
+		return models.RawAsset{}, err
+	}
+	return updatedAsset, recordRevision(q, userID, updatedAsset, models.RevisionUpdated, 0)
+}
+
+// updated finishes a conditional UPDATE ... RETURNING: no row means the
+// favorite is either gone or at a different version.
+func updated(q querier, userID, favID string, row *sql.Row) (models.RawAsset, error) {
+	asset, err := scanAsset(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.RawAsset{}, missing(q, userID, favID)
+	}
+	return asset, err
+}
+
+func missing(q querier, userID, favID string) error {
+	found, err := exists(q, userID, favID)
+	if err != nil {
+		return err
+	}
+	if found {
+		return ErrVersionMismatch
+	}
+	return ErrNotFound
+}
+
+func exists(q querier, userID, favID string) (bool, error) {
+	var found bool
+	err := q.QueryRow(`SELECT EXISTS (SELECT 1 FROM favorites WHERE user_id = ? AND id = ? AND deleted_at = 0)`, userID, favID).Scan(&found)
+	return found, err
+}
+
+// Batch runs an atomic batch in one transaction. A non-atomic batch is just
+// a sequence of single-op atomic batches.
+func (s *SQLiteStore) Batch(userID string, ops []BatchOp, atomic bool) ([]BatchResult, error) {
+	if !atomic {
+		results := make([]BatchResult, len(ops))
+		for i := range ops {
+			r, err := s.Batch(userID, ops[i:i+1], true)
+			if err != nil {
+				return nil, err
+			}
+			results[i] = r[0]
+		}
+		return results, nil
+	}
+
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	results := make([]BatchResult, len(ops))
+	for i, op := range ops {
+		asset, err := applyBatchOp(tx, userID, op)
+		if isStorageError(err) {
+			return nil, err
+		}
+		if err != nil {
+			for j := range results {
+				results[j] = BatchResult{Err: ErrBatchAborted}
+			}
+			results[i].Err = err
+			return results, nil
+		}
+		results[i].Asset = asset
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	for i, op := range ops {
+		if op.Kind == BatchDelete {
+			s.index.remove(userID, results[i].Asset.ID)
+		} else {
+			s.index.put(userID, results[i].Asset)
+		}
+	}
+	return results, nil
+}
+
+func applyBatchOp(tx *sql.Tx, userID string, op BatchOp) (models.RawAsset, error) {
+	switch op.Kind {
+	case BatchAdd:
+		return insertAsset(tx, userID, op.Asset)
+	case BatchUpdate:
+		op.Asset.UpdatedBy = userID
+		return updateAsset(tx, userID, op.Asset, op.ExpectedVersion)
+	case BatchDelete:
+		if err := trashAsset(tx, userID, op.Asset.ID, op.ExpectedVersion); err != nil {
+			return models.RawAsset{}, err
+		}
+		return models.RawAsset{ID: op.Asset.ID}, nil
+	}
+	return models.RawAsset{}, ErrInvalidBatchOp
+}
+
+func isStorageError(err error) bool {
+	return err != nil && !errors.Is(err, ErrNotFound) && !errors.Is(err, ErrVersionMismatch) &&
+		!errors.Is(err, ErrInvalidBatchOp) && !errors.Is(err, ErrTagLimit)
+}
+
+func (s *SQLiteStore) History(userID, favID string, limit, offset int) ([]models.Revision, int, error) {
+	var total int
+	err := s.db.QueryRow(`SELECT COUNT(r.version) FROM favorites f
+		LEFT JOIN revisions r ON r.user_id = f.user_id AND r.favorite_id = f.id
+		WHERE f.user_id = ? AND f.id = ?
+		GROUP BY f.id`, userID, favID).Scan(&total)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, 0, ErrNotFound
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	rows, err := s.db.Query(`SELECT `+revisionColumns+` FROM revisions WHERE user_id = ? AND favorite_id = ?
+		ORDER BY version DESC LIMIT ? OFFSET ?`, userID, favID, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+	revs := []models.Revision{}
+	for rows.Next() {
+		rev, err := scanRevision(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		revs = append(revs, rev)
+	}
+	return revs, total, rows.Err()
+}
+
+func (s *SQLiteStore) Revision(userID, favID string, version int64) (models.Revision, error) {
+	return getRevision(s.db, userID, favID, version)
+}
+
+func getRevision(q querier, userID, favID string, version int64) (models.Revision, error) {
+	rev, err := scanRevision(q.QueryRow(`SELECT `+revisionColumns+` FROM revisions
+		WHERE user_id = ? AND favorite_id = ? AND version = ?`, userID, favID, version))
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Revision{}, ErrNotFound
+	}
+	return rev, err
+}
+
+func (s *SQLiteStore) Revert(userID, favID string, version, expectedVersion int64) (models.RawAsset, error) {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	tx, err := s.db.Begin()
+	if err != nil {
+		return models.RawAsset{}, err
+	}
+	defer tx.Rollback()
+
+	current, err := scanAsset(tx.QueryRow(`SELECT `+assetColumns+` FROM favorites
+		WHERE user_id = ? AND id = ? AND deleted_at = 0`, userID, favID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.RawAsset{}, ErrNotFound
+	}
+	if err != nil {
+		return models.RawAsset{}, err
+	}
+	if expectedVersion != AnyVersion && current.Version != expectedVersion {
+		return models.RawAsset{}, ErrVersionMismatch
+	}
+	rev, err := getRevision(tx, userID, favID, version)
+	if err != nil {
+		return models.RawAsset{}, err
+	}
+	if err := checkTagLimit(tx, userID, rev.Asset.Tags); err != nil {
+		return models.RawAsset{}, err
+	}
+	payload, err := json.Marshal(rev.Asset.Payload)
+	if err != nil {
+		return models.RawAsset{}, fmt.Errorf("encode payload: %w", err)
+	}
+	asset, err := scanAsset(tx.QueryRow(`UPDATE favorites SET type = ?, description = ?, payload = ?, tags = ?, updated_at = ?, updated_by = ?, version = version + 1
+		WHERE user_id = ? AND id = ? RETURNING `+assetColumns,
+		string(rev.Asset.Type), rev.Asset.Description, string(payload), encodeTags(rev.Asset.Tags), time.Now().UTC().UnixNano(),
+		userID, userID, favID))
+	if err != nil {
+		return models.RawAsset{}, err
+	}
+	if err := setTags(tx, userID, favID, asset.Tags); err != nil {
+		return models.RawAsset{}, err
+	}
+	if err := recordRevision(tx, userID, asset, models.RevisionReverted, version); err != nil {
+		return models.RawAsset{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return models.RawAsset{}, err
+	}
+	s.index.put(userID, asset)
+	return asset, nil
+}
+
+// recordRevision must run in the transaction of the write it records.
+func recordRevision(q querier, userID string, asset models.RawAsset, action models.RevisionAction, revertedTo int64) error {
+	var prev *models.Revision
+	last, err := scanRevision(q.QueryRow(`SELECT `+revisionColumns+` FROM revisions
+		WHERE user_id = ? AND favorite_id = ? ORDER BY version DESC LIMIT 1`, userID, asset.ID))
+	switch {
+	case err == nil:
+		prev = &last
+	case !errors.Is(err, sql.ErrNoRows):
+		return err
+	}
+	rev := newRevision(prev, asset, action)
+	rev.RevertedTo = revertedTo
+	encoded, err := json.Marshal(rev.Asset)
+	if err != nil {
+		return fmt.Errorf("encode revision: %w", err)
+	}
+	_, err = q.Exec(`INSERT INTO revisions (user_id, favorite_id, version, action, author, created_at, changes, reverted_to, asset)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		userID, asset.ID, rev.Version, string(rev.Action), rev.Author, rev.Time.UnixNano(), encodeTags(rev.Changes), rev.RevertedTo, string(encoded))
+	if err != nil {
+		return err
+	}
+	_, err = q.Exec(`DELETE FROM revisions WHERE user_id = ? AND favorite_id = ? AND version NOT IN (
+		SELECT version FROM revisions WHERE user_id = ? AND favorite_id = ? ORDER BY version DESC LIMIT ?)`,
+		userID, asset.ID, userID, asset.ID, MaxRevisions)
+	return err
+}
+
+const revisionColumns = `version, action, author, created_at, changes, reverted_to, asset`
+
+func scanRevision(row rowScanner) (models.Revision, error) {
+	var (
+		rev       models.Revision
+		action    string
+		createdAt int64
+		changes   string
+		asset     string
+	)
+	if err := row.Scan(&rev.Version, &action, &rev.Author, &createdAt, &changes, &rev.RevertedTo, &asset); err != nil {
+		return models.Revision{}, err
+	}
+	rev.Action = models.RevisionAction(action)
+	rev.Time = time.Unix(0, createdAt).UTC()
+	if changes != "[]" {
+		if err := json.Unmarshal([]byte(changes), &rev.Changes); err != nil {
+			return models.Revision{}, fmt.Errorf("decode revision %d changes: %w", rev.Version, err)
+		}
+	}
+	if err := json.Unmarshal([]byte(asset), &rev.Asset); err != nil {
+		return models.Revision{}, fmt.Errorf("decode revision %d: %w", rev.Version, err)
+	}
+	return rev, nil
+}
+
+func (s *SQLiteStore) Search(userID, query string, limit int) ([]models.SearchResult, int, error) {
+	return searchResults(s.index, userID, query, limit, func(ids []string) (map[string]models.RawAsset, error) {
+		assets := make(map[string]models.RawAsset, len(ids))
+		if len(ids) == 0 {
+			return assets, nil
+		}
+		args := []interface{}{userID}
+		for _, id := range ids {
+			args = append(args, id)
+		}
+		rows, err := s.db.Query(`SELECT `+assetColumns+` FROM favorites
+			WHERE user_id = ? AND deleted_at = 0 AND id IN (?`+strings.Repeat(", ?", len(ids)-1)+`)`, args...)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			asset, err := scanAsset(rows)
+			if err != nil {
+				return nil, err
+			}
+			assets[asset.ID] = asset
+		}
+		return assets, rows.Err()
+	})
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// prefixedScanner scans a leading column into dest before handing the rest
+// of the row to scanAsset.
+type prefixedScanner struct {
+	rowScanner
+	dest []interface{}
+}
+
+func (p prefixedScanner) Scan(dest ...interface{}) error {
+	return p.rowScanner.Scan(append(slices.Clip(p.dest), dest...)...)
+}
+
+const assetColumns = `id, type, description, payload, created_at, updated_at, version, tags, deleted_at, updated_by`
+
+func scanAsset(row rowScanner) (models.RawAsset, error) {
+	var (
+		asset     models.RawAsset
+		assetType string
+		payload   string
+		createdAt int64
+		updatedAt int64
+		tags      string
+		deletedAt int64
+	)
+	if err := row.Scan(&asset.ID, &assetType, &asset.Description, &payload, &createdAt, &updatedAt, &asset.Version, &tags, &deletedAt, &asset.UpdatedBy); err != nil {
+		return models.RawAsset{}, err
+	}
+	if deletedAt > 0 {
+		t := time.Unix(0, deletedAt).UTC()
+		asset.DeletedAt = &t
+	}
+	if tags != "[]" {
+		if err := json.Unmarshal([]byte(tags), &asset.Tags); err != nil {
+			return models.RawAsset{}, fmt.Errorf("decode tags of %s: %w", asset.ID, err)
+		}
+	}
+	asset.Type = models.AssetType(assetType)
+	asset.CreatedAt = time.Unix(0, createdAt).UTC()
+	asset.UpdatedAt = time.Unix(0, updatedAt).UTC()
+	decoded, err := models.DecodePayload(asset.Type, []byte(payload), false)
+	if err != nil {
+		return models.RawAsset{}, fmt.Errorf("decode payload of %s: %w", asset.ID, err)
+	}
+	asset.Payload = decoded
+	return asset, nil
+}
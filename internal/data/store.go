@@ -1,10 +1,227 @@
 package data
 
-import "github.com/Zisimopoulou/platform-go-challenge/internal/models"
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Zisimopoulou/platform-go-challenge/internal/models"
+)
+
+var (
+	ErrNotFound         = errors.New("not found")
+	ErrVersionMismatch  = errors.New("version mismatch")
+	ErrBatchAborted     = errors.New("batch aborted: another operation failed")
+	ErrInvalidBatchOp   = errors.New("invalid batch operation")
+	ErrCollectionExists = errors.New("a collection with this name already exists")
+	ErrInvalidOrder     = errors.New("order must list every collection exactly once")
+	ErrTagLimit         = fmt.Errorf("a user can have at most %d distinct tags", MaxTagsPerUser)
+)
+
+// AnyVersion disables the compare-and-swap check on mutations.
+const AnyVersion int64 = 0
+
+// MaxTagsPerUser caps the distinct tags across all of a user's favorites.
+// Adds and updates that would introduce more fail with ErrTagLimit.
+const MaxTagsPerUser = 500
+
+// MaxRevisions is how many revisions are kept per favorite.
+const MaxRevisions = 100
+
+type SortField string
+
+const (
+	SortByCreatedAt   SortField = "createdAt"
+	SortByDescription SortField = "description"
+)
+
+// Keyset identifies a position in a user's list. Which fields matter depends
+// on the sort; the favorite ID is always the final tie-breaker.
+type Keyset struct {
+	CreatedAt   time.Time
+	Description string
+	ID          string
+}
+
+func KeysetOf(asset models.RawAsset) Keyset {
+	return Keyset{CreatedAt: asset.CreatedAt, Description: asset.Description, ID: asset.ID}
+}
+
+// ListFilter narrows a listing. Collection restricts it to the members of one
+// collection; the store checks that itself since the asset does not know.
+// Tags must be normalized; a favorite matches when it has any of them, or
+// all of them with AllTags.
+type ListFilter struct {
+	Types         []models.AssetType
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+	Description   string
+	Collection    string
+	Tags          []string
+	AllTags       bool
+}
+
+func (f ListFilter) IsZero() bool {
+	return len(f.Types) == 0 && f.CreatedAfter.IsZero() && f.CreatedBefore.IsZero() && f.Description == "" &&
+		f.Collection == "" && len(f.Tags) == 0
+}
+
+// Matches applies the filter except Collection: Types is a set, CreatedAfter
+// is inclusive, CreatedBefore exclusive and Description a case-insensitive
+// substring.
+func (f ListFilter) Matches(asset models.RawAsset) bool {
+	if len(f.Types) > 0 && !slices.Contains(f.Types, asset.Type) {
+		return false
+	}
+	if !f.CreatedAfter.IsZero() && asset.CreatedAt.Before(f.CreatedAfter) {
+		return false
+	}
+	if !f.CreatedBefore.IsZero() && !asset.CreatedAt.Before(f.CreatedBefore) {
+		return false
+	}
+	if f.Description != "" && !strings.Contains(strings.ToLower(asset.Description), strings.ToLower(f.Description)) {
+		return false
+	}
+	if len(f.Tags) > 0 && !f.matchesTags(asset.Tags) {
+		return false
+	}
+	return true
+}
+
+func (f ListFilter) matchesTags(tags []string) bool {
+	for _, tag := range f.Tags {
+		has := slices.Contains(tags, tag)
+		if has && !f.AllTags {
+			return true
+		}
+		if !has && f.AllTags {
+			return false
+		}
+	}
+	return f.AllTags
+}
+
+// ListQuery selects a page of the filtered, sorted list either by Offset or,
+// when After or Before is set, by keyset: After returns the Limit items
+// following the key, Before the Limit items preceding it. Results are always
+// in list order. The zero Sort is createdAt, newest first.
+type ListQuery struct {
+	Limit     int
+	Offset    int
+	After     *Keyset
+	Before    *Keyset
+	Filter    ListFilter
+	Sort      SortField
+	Ascending bool
+}
+
+// Precedes reports whether a sorts before b under the query's ordering.
+func (q ListQuery) Precedes(a, b Keyset) bool {
+	var c int
+	switch q.Sort {
+	case SortByDescription:
+		c = strings.Compare(a.Description, b.Description)
+	default:
+		c = a.CreatedAt.Compare(b.CreatedAt)
+	}
+	if c == 0 {
+		c = strings.Compare(a.ID, b.ID)
+	}
+	if q.Ascending {
+		return c < 0
+	}
+	return c > 0
+}
+
+type BatchOpKind string
+
+const (
+	BatchAdd    BatchOpKind = "add"
+	BatchUpdate BatchOpKind = "update"
+	BatchDelete BatchOpKind = "delete"
+)
+
+// BatchOp is one entry of a batch. Update and delete address the favorite by
+// Asset.ID and honor ExpectedVersion like their single-item counterparts.
+type BatchOp struct {
+	Kind            BatchOpKind
+	Asset           models.RawAsset
+	ExpectedVersion int64
+}
+
+// BatchResult holds the stored asset (only the ID for deletes) or the error
+// of the matching BatchOp.
+type BatchResult struct {
+	Asset models.RawAsset
+	Err   error
+}
 
 type Store interface {
 	Add(userID string, asset models.RawAsset) (string, error)
-	List(userID string, limit, offset int) ([]models.Favorite, int, error)
-	Delete(userID, favID string) error
-	UpdateDescription(userID, favID, desc string) error
-}
\ No newline at end of file
+	List(userID string, q ListQuery) ([]models.Favorite, int, error)
+	Get(userID, favID string) (models.RawAsset, error)
+	Delete(userID, favID string, expectedVersion int64) error
+	// UpdateDescription attributes the change to actor, or to userID when that
+	// is empty.
+	UpdateDescription(actor, userID, favID, desc string, expectedVersion int64) (models.RawAsset, error)
+	// Update attributes the change to asset.UpdatedBy, or to userID when that
+	// is empty. Every other write is attributed to userID.
+	Update(userID string, asset models.RawAsset, expectedVersion int64) (models.RawAsset, error)
+	Search(userID, query string, limit int) ([]models.SearchResult, int, error)
+	// Batch applies ops in order. When atomic, a failing op leaves the store
+	// untouched and every other op reports ErrBatchAborted; otherwise each op
+	// stands on its own. The returned error is reserved for storage failures.
+	Batch(userID string, ops []BatchOp, atomic bool) ([]BatchResult, error)
+	// Users lists every user with at least one favorite, ordered by user ID.
+	Users() ([]models.UserSummary, error)
+
+	// PutShare creates or replaces the grant of share.UserID on a favorite,
+	// which must exist. Deleting the favorite deletes its shares.
+	PutShare(share models.Share) (models.Share, error)
+	GetShare(ownerID, favID, userID string) (models.Share, error)
+	DeleteShare(ownerID, favID, userID string) error
+	// ListShares lists the grants on one favorite, ordered by user ID.
+	ListShares(ownerID, favID string) ([]models.Share, error)
+	// SharedWith lists the favorites shared with userID, most recent first.
+	SharedWith(userID string) ([]models.SharedFavorite, error)
+
+	// Collections are listed by position. Names are unique per user. Deleting
+	// a collection keeps its favorites; deleting a favorite takes it out of
+	// every collection.
+	CreateCollection(userID, name string) (models.Collection, error)
+	GetCollection(userID, collID string) (models.Collection, error)
+	ListCollections(userID string) ([]models.Collection, error)
+	RenameCollection(userID, collID, name string) (models.Collection, error)
+	// ReorderCollections takes every collection ID of the user in the new
+	// order and fails with ErrInvalidOrder otherwise.
+	ReorderCollections(userID string, collIDs []string) ([]models.Collection, error)
+	DeleteCollection(userID, collID string) error
+	// AddToCollection is a no-op when the favorite is already a member.
+	AddToCollection(userID, collID, favID string) error
+	RemoveFromCollection(userID, collID, favID string) error
+
+	// Tags counts the favorites carrying each of the user's tags, most used
+	// first.
+	Tags(userID string) ([]models.TagCount, error)
+
+	// Delete moves a favorite to the trash, where every other method but the
+	// ones below treats it as gone. Its shares and collection memberships
+	// come back with Restore; Purge and PurgeTrash drop them for good.
+	ListTrash(userID string, limit, offset int) ([]models.Favorite, int, error)
+	Restore(userID, favID string) (models.RawAsset, error)
+	Purge(userID, favID string) error
+	// PurgeTrash permanently deletes every favorite trashed before
+	// deletedBefore and returns how many there were.
+	PurgeTrash(deletedBefore time.Time) (int, error)
+
+	// Every write to a favorite records a revision, kept until the favorite
+	// is purged; only the latest MaxRevisions are kept. History lists them
+	// newest first, for live and trashed favorites alike.
+	History(userID, favID string, limit, offset int) ([]models.Revision, int, error)
+	Revision(userID, favID string, version int64) (models.Revision, error)
+	// Revert brings back the type, description, tags and payload of an
+	// earlier revision of a live favorite, as a new revision.
+	Revert(userID, favID string, version, expectedVersion int64) (models.RawAsset, error)
+}
@@ -0,0 +1,145 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"sync"
+)
+
+var (
+	ErrAssetTypeExists  = errors.New("asset type already registered")
+	ErrInvalidAssetType = errors.New("asset type names are 1-40 lowercase letters, digits, '-' or '_', and need a payload")
+)
+
+var assetTypeName = regexp.MustCompile(`^[a-z][a-z0-9_-]{0,39}$`)
+
+// AssetTypeInfo describes a kind of favorite. Payload returns a pointer to a
+// new value for the payload to be decoded into; when it is a struct its
+// validate tags are checked on every write. Validate, if set, runs after that
+// on the decoded value for rules the tags cannot express.
+type AssetTypeInfo struct {
+	Name        AssetType `json:"name"`
+	DisplayName string    `json:"displayName"`
+	Description string    `json:"description,omitempty"`
+	// SearchFields are the string payload fields indexed for search next to
+	// the description.
+	SearchFields []string `json:"searchFields,omitempty"`
+	// Schema is the JSON Schema of types defined by one, for clients to build
+	// forms from.
+	Schema json.RawMessage `json:"schema,omitempty"`
+
+	Payload  func() Payload              `json:"-"`
+	Validate func(payload Payload) error `json:"-"`
+}
+
+type assetTypeRegistry struct {
+	mu    sync.RWMutex
+	types map[AssetType]AssetTypeInfo
+}
+
+var assetTypes = &assetTypeRegistry{types: make(map[AssetType]AssetTypeInfo)}
+
+func init() {
+	for _, info := range []AssetTypeInfo{
+		{
+			Name:         TypeChart,
+			DisplayName:  "Chart",
+			Description:  "A line, bar, pie or scatter chart of one or more named series.",
+			SearchFields: []string{"title", "xAxis", "yAxis"},
+			Payload:      func() Payload { return new(Chart) },
+			Validate:     func(payload Payload) error { return payload.(Chart).Validate() },
+		},
+		{
+			Name:         TypeInsight,
+			DisplayName:  "Insight",
+			Description:  "A short piece of text.",
+			SearchFields: []string{"text"},
+			Payload:      func() Payload { return new(Insight) },
+		},
+		{
+			Name:         TypeAudience,
+			DisplayName:  "Audience",
+			Description:  "A set of characteristics describing a group of people.",
+			SearchFields: []string{"gender", "birthCountry", "ageGroup", "hoursDaily", "purchasesLastMonth"},
+			Payload:      func() Payload { return new(Audience) },
+		},
+	} {
+		if err := RegisterAssetType(info); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// RegisterAssetType makes a new kind of favorite available to validation,
+// search and the type listing.
+func RegisterAssetType(info AssetTypeInfo) error {
+	if !assetTypeName.MatchString(string(info.Name)) || info.Payload == nil {
+		return ErrInvalidAssetType
+	}
+	assetTypes.mu.Lock()
+	defer assetTypes.mu.Unlock()
+	if _, ok := assetTypes.types[info.Name]; ok {
+		return ErrAssetTypeExists
+	}
+	assetTypes.types[info.Name] = info
+	return nil
+}
+
+// UnregisterAssetType removes a type. Stored favorites of that type stay, but
+// can no longer be written until it is registered again.
+func UnregisterAssetType(name AssetType) {
+	assetTypes.mu.Lock()
+	defer assetTypes.mu.Unlock()
+	delete(assetTypes.types, name)
+}
+
+// ReplaceAssetTypes unregisters remove and registers add in one step, so a
+// reloaded type is never missing in between. Nothing changes if any of add is
+// invalid or clashes with a type that is not being removed.
+func ReplaceAssetTypes(remove []AssetType, add []AssetTypeInfo) error {
+	assetTypes.mu.Lock()
+	defer assetTypes.mu.Unlock()
+	removed := make(map[AssetType]bool, len(remove))
+	for _, name := range remove {
+		removed[name] = true
+	}
+	seen := make(map[AssetType]bool, len(add))
+	for _, info := range add {
+		if !assetTypeName.MatchString(string(info.Name)) || info.Payload == nil {
+			return fmt.Errorf("%w: %q", ErrInvalidAssetType, info.Name)
+		}
+		if _, ok := assetTypes.types[info.Name]; (ok && !removed[info.Name]) || seen[info.Name] {
+			return fmt.Errorf("%w: %s", ErrAssetTypeExists, info.Name)
+		}
+		seen[info.Name] = true
+	}
+	for name := range removed {
+		delete(assetTypes.types, name)
+	}
+	for _, info := range add {
+		assetTypes.types[info.Name] = info
+	}
+	return nil
+}
+
+func LookupAssetType(name AssetType) (AssetTypeInfo, bool) {
+	assetTypes.mu.RLock()
+	defer assetTypes.mu.RUnlock()
+	info, ok := assetTypes.types[name]
+	return info, ok
+}
+
+// AssetTypes lists the registered types by name.
+func AssetTypes() []AssetTypeInfo {
+	assetTypes.mu.RLock()
+	defer assetTypes.mu.RUnlock()
+	list := make([]AssetTypeInfo, 0, len(assetTypes.types))
+	for _, info := range assetTypes.types {
+		list = append(list, info)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
@@ -0,0 +1,104 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+type ChartKind string
+
+const (
+	ChartLine    ChartKind = "line"
+	ChartBar     ChartKind = "bar"
+	ChartPie     ChartKind = "pie"
+	ChartScatter ChartKind = "scatter"
+)
+
+var (
+	ErrChartData   = errors.New("chart needs either data or series")
+	ErrChartXAxis  = errors.New("chart can have labels or timestamps, not both")
+	ErrChartSeries = errors.New("invalid chart series")
+)
+
+// Series is one named line, set of bars or pie of a chart, with a value per
+// label or timestamp.
+type Series struct {
+	Name   string    `json:"name" validate:"required,max=100"`
+	Values []float64 `json:"values" validate:"required,min=1,max=10000"`
+}
+
+// Chart plots one or more series. The x axis is either category labels or
+// timestamps; without either, values are plotted by position. Data is the
+// original single series of integers and is still accepted in place of
+// Series.
+type Chart struct {
+	Title      string      `json:"title" validate:"required"`
+	Kind       ChartKind   `json:"kind,omitempty" validate:"omitempty,oneof=line bar pie scatter"`
+	XAxis      string      `json:"xAxis" validate:"required_unless=Kind pie"`
+	YAxis      string      `json:"yAxis" validate:"required_unless=Kind pie"`
+	XUnit      string      `json:"xUnit,omitempty" validate:"max=50"`
+	YUnit      string      `json:"yUnit,omitempty" validate:"max=50"`
+	Labels     []string    `json:"labels,omitempty" validate:"max=10000"`
+	Timestamps []time.Time `json:"timestamps,omitempty" validate:"max=10000"`
+	Series     []Series    `json:"series,omitempty" validate:"max=50,dive"`
+	Data       []int       `json:"data,omitempty" validate:"max=10000"`
+}
+
+// AllSeries returns the series of c, with legacy Data as a single unnamed
+// series.
+func (c Chart) AllSeries() []Series {
+	if len(c.Data) == 0 {
+		return c.Series
+	}
+	values := make([]float64, len(c.Data))
+	for i, v := range c.Data {
+		values[i] = float64(v)
+	}
+	return []Series{{Values: values}}
+}
+
+// Validate checks the rules that span fields: every series has a value per
+// label or timestamp, series names are unique, and a pie is a single series
+// of labelled, non-negative slices.
+func (c Chart) Validate() error {
+	if (len(c.Data) == 0) == (len(c.Series) == 0) {
+		return ErrChartData
+	}
+	if len(c.Labels) > 0 && len(c.Timestamps) > 0 {
+		return ErrChartXAxis
+	}
+	points := max(len(c.Labels), len(c.Timestamps))
+
+	series := c.AllSeries()
+	names := make(map[string]bool, len(series))
+	for _, s := range series {
+		if s.Name != "" && names[s.Name] {
+			return fmt.Errorf("%w: duplicate name %q", ErrChartSeries, s.Name)
+		}
+		names[s.Name] = true
+		if points > 0 && len(s.Values) != points {
+			return fmt.Errorf("%w: %s has %d values for %d points", ErrChartSeries, seriesName(s), len(s.Values), points)
+		}
+		for _, v := range s.Values {
+			if math.IsNaN(v) || math.IsInf(v, 0) {
+				return fmt.Errorf("%w: %s has a value that is not a finite number", ErrChartSeries, seriesName(s))
+			}
+			if c.Kind == ChartPie && v < 0 {
+				return fmt.Errorf("%w: pie slices cannot be negative", ErrChartSeries)
+			}
+		}
+	}
+	if c.Kind == ChartPie && (len(series) != 1 || len(c.Labels) == 0) {
+		return fmt.Errorf("%w: a pie chart is one series with a label per slice", ErrChartSeries)
+	}
+	return nil
+}
+
+func seriesName(s Series) string {
+	if s.Name == "" {
+		return "data"
+	}
+	return fmt.Sprintf("series %q", s.Name)
+}
@@ -12,13 +12,6 @@ const (
 	TypeAudience AssetType = "audience"
 )
 
-type Chart struct {
-	Title string `json:"title" validate:"required"`
-	XAxis string `json:"xAxis" validate:"required"`
-	YAxis string `json:"yAxis" validate:"required"`
-	Data  []int  `json:"data" validate:"required,min=1"`
-}
-
 type Insight struct {
 	Text string `json:"text" validate:"required,min=1,max=500"`
 }
@@ -32,11 +25,20 @@ type Audience struct {
 }
 
 type RawAsset struct {
-	ID          string      `json:"id"`
-	Type        AssetType   `json:"type" validate:"required,oneof=chart insight audience"`
-	Description string      `json:"description,omitempty" validate:"max=500"`
-	CreatedAt   time.Time   `json:"createdAt"`
-	Payload     interface{} `json:"payload" validate:"required"`
+	ID          string    `json:"id"`
+	Type        AssetType `json:"type" validate:"required"`
+	Description string    `json:"description,omitempty" validate:"max=500"`
+	CreatedAt   time.Time `json:"createdAt"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+	Version     int64     `json:"version"`
+	Tags        []string  `json:"tags,omitempty"`
+	// Payload holds the value of the type registered for Type, such as a
+	// Chart.
+	Payload Payload `json:"payload" validate:"required"`
+	// DeletedAt is set while the favorite is in the trash.
+	DeletedAt *time.Time `json:"deletedAt,omitempty"`
+	// UpdatedBy is the user who made the latest change.
+	UpdatedBy string `json:"updatedBy,omitempty"`
 }
 
 type Favorite struct {
@@ -50,4 +52,124 @@ type PaginatedFavorites struct {
 	Limit      int        `json:"limit"`
 	Offset     int        `json:"offset"`
 	HasMore    bool       `json:"hasMore"`
-}
\ No newline at end of file
+	NextCursor string     `json:"nextCursor,omitempty"`
+	PrevCursor string     `json:"prevCursor,omitempty"`
+}
+
+type RevisionAction string
+
+const (
+	RevisionCreated  RevisionAction = "created"
+	RevisionUpdated  RevisionAction = "updated"
+	RevisionDeleted  RevisionAction = "deleted"
+	RevisionRestored RevisionAction = "restored"
+	RevisionReverted RevisionAction = "reverted"
+)
+
+// Revision is a favorite as it was right after one change. Changes lists the
+// fields that differ from the revision before it.
+type Revision struct {
+	Version int64          `json:"version"`
+	Action  RevisionAction `json:"action"`
+	Author  string         `json:"author"`
+	Time    time.Time      `json:"time"`
+	Changes []string       `json:"changes,omitempty"`
+	// RevertedTo is the version a revert went back to.
+	RevertedTo int64    `json:"revertedTo,omitempty"`
+	Asset      RawAsset `json:"asset"`
+}
+
+type PaginatedRevisions struct {
+	Revisions  []Revision `json:"revisions"`
+	TotalCount int        `json:"totalCount"`
+	Limit      int        `json:"limit"`
+	Offset     int        `json:"offset"`
+	HasMore    bool       `json:"hasMore"`
+}
+
+// TagCount is one of a user's tags and how many favorites carry it.
+type TagCount struct {
+	Tag   string `json:"tag"`
+	Count int    `json:"count"`
+}
+
+// Collection is a named group of a user's favorites. A favorite can be in
+// any number of collections; Favorites counts the ones in this one.
+type Collection struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Position  int       `json:"position"`
+	Favorites int       `json:"favorites"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+type Permission string
+
+const (
+	PermissionRead Permission = "read"
+	PermissionEdit Permission = "edit"
+)
+
+// Share grants UserID access to one of OwnerID's favorites.
+type Share struct {
+	OwnerID    string     `json:"ownerId"`
+	FavoriteID string     `json:"favoriteId"`
+	UserID     string     `json:"userId"`
+	Permission Permission `json:"permission"`
+	CreatedAt  time.Time  `json:"createdAt"`
+}
+
+type SharedFavorite struct {
+	Share
+	Asset RawAsset `json:"asset"`
+}
+
+// UserSummary is one entry of the admin user listing.
+type UserSummary struct {
+	UserID    string `json:"userId"`
+	Favorites int    `json:"favorites"`
+}
+
+type SearchResult struct {
+	Favorite
+	Score      float64           `json:"score"`
+	Highlights map[string]string `json:"highlights"`
+}
+
+type SearchResults struct {
+	Query      string         `json:"query"`
+	Results    []SearchResult `json:"results"`
+	TotalCount int            `json:"totalCount"`
+}
+
+// BatchOperation is one entry of a batch request. Add takes an asset; update
+// takes an id and the replacement asset; delete takes only an id. Version is
+// an optional expected version, like If-Match on the single-item endpoints.
+type BatchOperation struct {
+	Op      string    `json:"op"`
+	ID      string    `json:"id,omitempty"`
+	Version int64     `json:"version,omitempty"`
+	Asset   *RawAsset `json:"asset,omitempty"`
+}
+
+type BatchRequest struct {
+	Atomic     bool             `json:"atomic"`
+	Operations []BatchOperation `json:"operations"`
+}
+
+type BatchItemResult struct {
+	Index      int       `json:"index"`
+	Op         string    `json:"op"`
+	Status     int       `json:"status"`
+	FavoriteID string    `json:"favoriteId,omitempty"`
+	Favorite   *Favorite `json:"favorite,omitempty"`
+	Error      string    `json:"error,omitempty"`
+}
+
+type BatchResponse struct {
+	Atomic    bool              `json:"atomic"`
+	Succeeded int               `json:"succeeded"`
+	Failed    int               `json:"failed"`
+	Results   []BatchItemResult `json:"results"`
+}
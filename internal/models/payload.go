@@ -0,0 +1,88 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync/atomic"
+)
+
+var ErrInvalidPayload = errors.New("invalid payload")
+
+// Payload is the content of a favorite. Every registered asset type decodes
+// its payloads into its own implementation, such as Chart for charts.
+type Payload interface {
+	AssetType() AssetType
+}
+
+func (Chart) AssetType() AssetType    { return TypeChart }
+func (Insight) AssetType() AssetType  { return TypeInsight }
+func (Audience) AssetType() AssetType { return TypeAudience }
+
+// Document is the payload of types without a Go type of their own, such as
+// those defined by a JSON Schema, and of types that are not registered. It
+// encodes as Value alone.
+type Document struct {
+	Type  AssetType
+	Value interface{}
+}
+
+func (d Document) AssetType() AssetType { return d.Type }
+
+func (d Document) MarshalJSON() ([]byte, error) { return json.Marshal(d.Value) }
+
+func (d *Document) UnmarshalJSON(data []byte) error { return json.Unmarshal(data, &d.Value) }
+
+var strictPayloads atomic.Bool
+
+// SetStrictPayloads makes decoding reject payload fields the asset type does
+// not define, instead of dropping them.
+func SetStrictPayloads(strict bool) {
+	strictPayloads.Store(strict)
+}
+
+func StrictPayloads() bool {
+	return strictPayloads.Load()
+}
+
+// DecodePayload decodes raw into the payload type registered for t, and
+// returns it by value: a Chart for charts. With strict set, fields the type
+// does not define are an error instead of being dropped.
+func DecodePayload(t AssetType, raw []byte, strict bool) (Payload, error) {
+	target := Payload(&Document{Type: t})
+	if info, ok := LookupAssetType(t); ok {
+		target = info.Payload()
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	if strict {
+		dec.DisallowUnknownFields()
+	}
+	if err := dec.Decode(target); err != nil {
+		return nil, fmt.Errorf("%w for %s: %v", ErrInvalidPayload, t, err)
+	}
+	return reflect.ValueOf(target).Elem().Interface().(Payload), nil
+}
+
+// UnmarshalJSON decodes the payload once, by type, into its Go type.
+func (a *RawAsset) UnmarshalJSON(data []byte) error {
+	type plain RawAsset
+	aux := struct {
+		*plain
+		Payload json.RawMessage `json:"payload"`
+	}{plain: (*plain)(a)}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	a.Payload = nil
+	if len(aux.Payload) == 0 || string(aux.Payload) == "null" {
+		return nil
+	}
+	payload, err := DecodePayload(a.Type, aux.Payload, StrictPayloads())
+	if err != nil {
+		return err
+	}
+	a.Payload = payload
+	return nil
+}
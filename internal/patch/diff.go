@@ -0,0 +1,77 @@
+package patch
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+var pointerEscaper = strings.NewReplacer("~", "~0", "/", "~1")
+
+// Diff returns an RFC 6902 JSON Patch that turns document a into b. Objects
+// are compared member by member; arrays and scalars that differ are replaced
+// whole, which keeps the patch readable at the cost of its length.
+func Diff(a, b []byte) ([]Operation, error) {
+	var from, to interface{}
+	if err := decode(a, &from); err != nil {
+		return nil, fmt.Errorf("invalid document: %w", err)
+	}
+	if err := decode(b, &to); err != nil {
+		return nil, fmt.Errorf("invalid document: %w", err)
+	}
+	ops := []Operation{}
+	if err := diffValue(&ops, "", from, to); err != nil {
+		return nil, err
+	}
+	return ops, nil
+}
+
+func diffValue(ops *[]Operation, path string, a, b interface{}) error {
+	if jsonEqual(a, b) {
+		return nil
+	}
+	am, aok := a.(map[string]interface{})
+	bm, bok := b.(map[string]interface{})
+	if !aok || !bok {
+		return appendOp(ops, "replace", path, b)
+	}
+
+	keys := make([]string, 0, len(am)+len(bm))
+	for k := range am {
+		keys = append(keys, k)
+	}
+	for k := range bm {
+		if _, ok := am[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	slices.Sort(keys)
+	for _, k := range keys {
+		child := path + "/" + pointerEscaper.Replace(k)
+		av, inA := am[k]
+		bv, inB := bm[k]
+		var err error
+		switch {
+		case !inB:
+			*ops = append(*ops, Operation{Op: "remove", Path: child})
+		case !inA:
+			err = appendOp(ops, "add", child, bv)
+		default:
+			err = diffValue(ops, child, av, bv)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func appendOp(ops *[]Operation, op, path string, value interface{}) error {
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	*ops = append(*ops, Operation{Op: op, Path: path, Value: raw})
+	return nil
+}
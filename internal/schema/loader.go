@@ -0,0 +1,82 @@
+package schema
+
+import (
+	"cmp"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/Zisimopoulou/platform-go-challenge/internal/models"
+)
+
+// Loader defines an asset type for every *.json schema in a directory, named
+// after the file: report.json defines the "report" type.
+type Loader struct {
+	dir string
+
+	mu     sync.Mutex
+	loaded []models.AssetType
+}
+
+func NewLoader(dir string) *Loader {
+	return &Loader{dir: dir}
+}
+
+// Load reads the directory and registers its types, replacing the ones the
+// previous Load registered. If any schema fails to load, or would redefine a
+// built-in type, the registered types stay as they were.
+func (l *Loader) Load() ([]models.AssetTypeInfo, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	files, err := filepath.Glob(filepath.Join(l.dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	infos := make([]models.AssetTypeInfo, 0, len(files))
+	for _, file := range files {
+		info, err := loadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", filepath.Base(file), err)
+		}
+		infos = append(infos, info)
+	}
+
+	if err := models.ReplaceAssetTypes(l.loaded, infos); err != nil {
+		return nil, err
+	}
+	l.loaded = l.loaded[:0]
+	for _, info := range infos {
+		l.loaded = append(l.loaded, info.Name)
+	}
+	return infos, nil
+}
+
+func loadFile(path string) (models.AssetTypeInfo, error) {
+	doc, err := os.ReadFile(path)
+	if err != nil {
+		return models.AssetTypeInfo{}, err
+	}
+	s, err := Compile(doc)
+	if err != nil {
+		return models.AssetTypeInfo{}, err
+	}
+	name := strings.TrimSuffix(filepath.Base(path), ".json")
+	searchFields := s.SearchFields
+	if searchFields == nil {
+		searchFields = s.StringProperties()
+	}
+	return models.AssetTypeInfo{
+		Name:         models.AssetType(name),
+		DisplayName:  cmp.Or(s.Title, name),
+		Description:  s.Description,
+		SearchFields: searchFields,
+		Schema:       doc,
+		Payload:      func() models.Payload { return &models.Document{Type: models.AssetType(name)} },
+		Validate: func(payload models.Payload) error {
+			return s.Validate(payload.(models.Document).Value)
+		},
+	}, nil
+}
@@ -0,0 +1,380 @@
+// Package schema validates JSON documents against the subset of JSON Schema
+// used to define custom asset types.
+package schema
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+var ErrInvalidSchema = errors.New("invalid schema")
+
+// keywords are the schema keywords understood here. Anything else, except
+// "x-" extensions, is rejected rather than silently ignored.
+var keywords = map[string]bool{
+	"$schema": true, "$id": true, "$comment": true,
+	"title": true, "description": true, "default": true, "examples": true, "format": true,
+	"type": true, "enum": true, "const": true,
+	"properties": true, "required": true, "additionalProperties": true,
+	"items": true, "minItems": true, "maxItems": true, "uniqueItems": true,
+	"minLength": true, "maxLength": true, "pattern": true,
+	"minimum": true, "maximum": true, "exclusiveMinimum": true, "exclusiveMaximum": true,
+}
+
+var types = map[string]bool{
+	"object": true, "array": true, "string": true, "number": true,
+	"integer": true, "boolean": true, "null": true,
+}
+
+// Schema is a compiled schema.
+type Schema struct {
+	Title       string
+	Description string
+	// SearchFields comes from the "x-searchFields" extension.
+	SearchFields []string
+
+	types                []string
+	enum                 []interface{}
+	constant             *interface{}
+	properties           map[string]*Schema
+	required             []string
+	additionalProperties *Schema
+	noAdditional         bool
+	items                *Schema
+	minItems, maxItems   *int
+	uniqueItems          bool
+	minLength, maxLength *int
+	pattern              *regexp.Regexp
+	minimum, maximum     *float64
+	exclusiveMinimum     *float64
+	exclusiveMaximum     *float64
+}
+
+type rawSchema struct {
+	Title                string                     `json:"title"`
+	Description          string                     `json:"description"`
+	SearchFields         []string                   `json:"x-searchFields"`
+	Type                 json.RawMessage            `json:"type"`
+	Enum                 []interface{}              `json:"enum"`
+	Const                json.RawMessage            `json:"const"`
+	Properties           map[string]json.RawMessage `json:"properties"`
+	Required             []string                   `json:"required"`
+	AdditionalProperties json.RawMessage            `json:"additionalProperties"`
+	Items                json.RawMessage            `json:"items"`
+	MinItems             *int                       `json:"minItems"`
+	MaxItems             *int                       `json:"maxItems"`
+	UniqueItems          bool                       `json:"uniqueItems"`
+	MinLength            *int                       `json:"minLength"`
+	MaxLength            *int                       `json:"maxLength"`
+	Pattern              *string                    `json:"pattern"`
+	Minimum              *float64                   `json:"minimum"`
+	Maximum              *float64                   `json:"maximum"`
+	ExclusiveMinimum     *float64                   `json:"exclusiveMinimum"`
+	ExclusiveMaximum     *float64                   `json:"exclusiveMaximum"`
+}
+
+// Compile parses a schema document.
+func Compile(doc []byte) (*Schema, error) {
+	return compile(doc, "")
+}
+
+func compile(doc []byte, at string) (*Schema, error) {
+	fail := func(format string, args ...interface{}) error {
+		return fmt.Errorf("%w: %s%s", ErrInvalidSchema, where(at), fmt.Sprintf(format, args...))
+	}
+
+	var keys map[string]json.RawMessage
+	if err := json.Unmarshal(doc, &keys); err != nil {
+		return nil, fail("%v", err)
+	}
+	for k := range keys {
+		if !keywords[k] && !strings.HasPrefix(k, "x-") {
+			return nil, fail("unsupported keyword %q", k)
+		}
+	}
+	var raw rawSchema
+	if err := json.Unmarshal(doc, &raw); err != nil {
+		return nil, fail("%v", err)
+	}
+
+	s := &Schema{
+		Title:            raw.Title,
+		Description:      raw.Description,
+		SearchFields:     raw.SearchFields,
+		enum:             raw.Enum,
+		required:         raw.Required,
+		minItems:         raw.MinItems,
+		maxItems:         raw.MaxItems,
+		uniqueItems:      raw.UniqueItems,
+		minLength:        raw.MinLength,
+		maxLength:        raw.MaxLength,
+		minimum:          raw.Minimum,
+		maximum:          raw.Maximum,
+		exclusiveMinimum: raw.ExclusiveMinimum,
+		exclusiveMaximum: raw.ExclusiveMaximum,
+	}
+
+	if len(raw.Type) > 0 {
+		var one string
+		if err := json.Unmarshal(raw.Type, &one); err == nil {
+			s.types = []string{one}
+		} else if err := json.Unmarshal(raw.Type, &s.types); err != nil {
+			return nil, fail("type must be a string or an array of strings")
+		}
+		for _, t := range s.types {
+			if !types[t] {
+				return nil, fail("unknown type %q", t)
+			}
+		}
+	}
+	if len(raw.Const) > 0 {
+		var c interface{}
+		if err := json.Unmarshal(raw.Const, &c); err != nil {
+			return nil, fail("const: %v", err)
+		}
+		s.constant = &c
+	}
+	if raw.Pattern != nil {
+		re, err := regexp.Compile(*raw.Pattern)
+		if err != nil {
+			return nil, fail("pattern: %v", err)
+		}
+		s.pattern = re
+	}
+
+	if len(raw.Properties) > 0 {
+		s.properties = make(map[string]*Schema, len(raw.Properties))
+		for name, sub := range raw.Properties {
+			p, err := compile(sub, join(at, name))
+			if err != nil {
+				return nil, err
+			}
+			s.properties[name] = p
+		}
+	}
+	if len(raw.AdditionalProperties) > 0 {
+		var allowed bool
+		if err := json.Unmarshal(raw.AdditionalProperties, &allowed); err == nil {
+			s.noAdditional = !allowed
+		} else {
+			p, err := compile(raw.AdditionalProperties, join(at, "*"))
+			if err != nil {
+				return nil, err
+			}
+			s.additionalProperties = p
+		}
+	}
+	if len(raw.Items) > 0 {
+		p, err := compile(raw.Items, join(at, "*"))
+		if err != nil {
+			return nil, err
+		}
+		s.items = p
+	}
+	return s, nil
+}
+
+// FieldError is one failed rule. Field is the dotted path to the offending
+// value within the document, or empty for the document itself.
+type FieldError struct {
+	Field   string
+	Message string
+}
+
+// ValidationErrors lists every rule a document failed, ordered by field.
+type ValidationErrors []FieldError
+
+func (e ValidationErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, fe := range e {
+		msgs[i] = where(fe.Field) + fe.Message
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Validate checks v, a document decoded by encoding/json into an interface{},
+// and returns ValidationErrors if it does not conform.
+func (s *Schema) Validate(v interface{}) error {
+	var errs ValidationErrors
+	s.validate(v, "", &errs)
+	if len(errs) == 0 {
+		return nil
+	}
+	sort.SliceStable(errs, func(i, j int) bool { return errs[i].Field < errs[j].Field })
+	return errs
+}
+
+func (s *Schema) validate(v interface{}, at string, errs *ValidationErrors) {
+	fail := func(format string, args ...interface{}) {
+		*errs = append(*errs, FieldError{Field: at, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if len(s.types) > 0 && !s.hasType(v) {
+		fail("must be %s", strings.Join(s.types, " or "))
+		return
+	}
+	if s.constant != nil && !reflect.DeepEqual(v, *s.constant) {
+		fail("must be %s", literal(*s.constant))
+	}
+	if len(s.enum) > 0 {
+		found := false
+		for _, e := range s.enum {
+			if reflect.DeepEqual(v, e) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			options := make([]string, len(s.enum))
+			for i, e := range s.enum {
+				options[i] = literal(e)
+			}
+			fail("must be one of %s", strings.Join(options, ", "))
+		}
+	}
+
+	switch v := v.(type) {
+	case string:
+		n := utf8.RuneCountInString(v)
+		if s.minLength != nil && n < *s.minLength {
+			fail("must be at least %d characters", *s.minLength)
+		}
+		if s.maxLength != nil && n > *s.maxLength {
+			fail("must be at most %d characters", *s.maxLength)
+		}
+		if s.pattern != nil && !s.pattern.MatchString(v) {
+			fail("must match %s", s.pattern)
+		}
+
+	case float64:
+		if s.minimum != nil && v < *s.minimum {
+			fail("must be at least %s", number(*s.minimum))
+		}
+		if s.maximum != nil && v > *s.maximum {
+			fail("must be at most %s", number(*s.maximum))
+		}
+		if s.exclusiveMinimum != nil && v <= *s.exclusiveMinimum {
+			fail("must be greater than %s", number(*s.exclusiveMinimum))
+		}
+		if s.exclusiveMaximum != nil && v >= *s.exclusiveMaximum {
+			fail("must be less than %s", number(*s.exclusiveMaximum))
+		}
+
+	case []interface{}:
+		if s.minItems != nil && len(v) < *s.minItems {
+			fail("must have at least %d items", *s.minItems)
+		}
+		if s.maxItems != nil && len(v) > *s.maxItems {
+			fail("must have at most %d items", *s.maxItems)
+		}
+		if s.uniqueItems {
+		unique:
+			for i := range v {
+				for j := i + 1; j < len(v); j++ {
+					if reflect.DeepEqual(v[i], v[j]) {
+						fail("must not contain duplicates")
+						break unique
+					}
+				}
+			}
+		}
+		if s.items != nil {
+			for i, item := range v {
+				s.items.validate(item, join(at, strconv.Itoa(i)), errs)
+			}
+		}
+
+	case map[string]interface{}:
+		for _, name := range s.required {
+			if _, ok := v[name]; !ok {
+				*errs = append(*errs, FieldError{Field: join(at, name), Message: "is required"})
+			}
+		}
+		for name, value := range v {
+			switch p, ok := s.properties[name]; {
+			case ok:
+				p.validate(value, join(at, name), errs)
+			case s.additionalProperties != nil:
+				s.additionalProperties.validate(value, join(at, name), errs)
+			case s.noAdditional:
+				*errs = append(*errs, FieldError{Field: join(at, name), Message: "is not allowed"})
+			}
+		}
+	}
+}
+
+func (s *Schema) hasType(v interface{}) bool {
+	for _, t := range s.types {
+		switch v := v.(type) {
+		case nil:
+			if t == "null" {
+				return true
+			}
+		case bool:
+			if t == "boolean" {
+				return true
+			}
+		case string:
+			if t == "string" {
+				return true
+			}
+		case float64:
+			if t == "number" || (t == "integer" && v == math.Trunc(v)) {
+				return true
+			}
+		case []interface{}:
+			if t == "array" {
+				return true
+			}
+		case map[string]interface{}:
+			if t == "object" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// StringProperties lists the top level properties declared as strings, the
+// default search fields of a type.
+func (s *Schema) StringProperties() []string {
+	var names []string
+	for name, p := range s.properties {
+		if len(p.types) == 1 && p.types[0] == "string" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func join(at, name string) string {
+	if at == "" {
+		return name
+	}
+	return at + "." + name
+}
+
+func where(at string) string {
+	if at == "" {
+		return ""
+	}
+	return at + ": "
+}
+
+func literal(v interface{}) string {
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+func number(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
@@ -0,0 +1,57 @@
+package validation
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	MaxTagsPerFavorite = 20
+	MaxTagLen          = 40
+)
+
+var (
+	ErrInvalidTag  = errors.New("tags may only contain letters, digits, '-', '_', '.' and ':'")
+	ErrTooManyTags = fmt.Errorf("a favorite can have at most %d tags", MaxTagsPerFavorite)
+)
+
+// NormalizeTag lowercases a tag and turns runs of whitespace into a single
+// dash, so "Q3 Review" and "q3-review" are the same tag.
+func NormalizeTag(tag string) (string, error) {
+	tag = strings.ToLower(strings.Join(strings.Fields(tag), "-"))
+	if tag == "" || utf8.RuneCountInString(tag) > MaxTagLen {
+		return "", fmt.Errorf("%w: tags must be 1 to %d characters", ErrInvalidTag, MaxTagLen)
+	}
+	for _, r := range tag {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && !strings.ContainsRune("-_.:", r) {
+			return "", fmt.Errorf("%w: %q", ErrInvalidTag, tag)
+		}
+	}
+	return tag, nil
+}
+
+// NormalizeTags normalizes every tag and returns them sorted and without
+// duplicates; no tags gives nil.
+func NormalizeTags(tags []string) ([]string, error) {
+	if len(tags) == 0 {
+		return nil, nil
+	}
+	out := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		t, err := NormalizeTag(tag)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, t)
+	}
+	slices.Sort(out)
+	out = slices.Compact(out)
+	if len(out) > MaxTagsPerFavorite {
+		return nil, ErrTooManyTags
+	}
+	return out, nil
+}
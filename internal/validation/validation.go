@@ -1,8 +1,8 @@
 package validation
 
 import (
-	"encoding/json"
 	"fmt"
+	"reflect"
 
 	"github.com/Zisimopoulou/platform-go-challenge/internal/models"
 	"github.com/go-playground/validator/v10"
@@ -23,41 +23,40 @@ func ValidateStruct(s interface{}) error {
 		return fmt.Errorf("asset validation failed: %v", err)
 	}
 
+	tags, err := NormalizeTags(asset.Tags)
+	if err != nil {
+		return err
+	}
+	asset.Tags = tags
+
  	return validatePayload(asset)
 }
 
+// validatePayload checks the payload against the rules of its registered
+// type, so adding a type never touches this function. Payloads given as a
+// pointer are stored by value.
 func validatePayload(asset *models.RawAsset) error {
-	switch asset.Type {
-	case models.TypeChart:
-		var chart models.Chart
-		if err := unmarshalPayload(asset.Payload, &chart); err != nil {
-			return fmt.Errorf("invalid chart payload: %v", err)
-		}
-		return validate.Struct(chart)
-		
-	case models.TypeInsight:
-		var insight models.Insight
-		if err := unmarshalPayload(asset.Payload, &insight); err != nil {
-			return fmt.Errorf("invalid insight payload: %v", err)
-		}
-		return validate.Struct(insight)
-		
-	case models.TypeAudience:
-		var audience models.Audience
-		if err := unmarshalPayload(asset.Payload, &audience); err != nil {
-			return fmt.Errorf("invalid audience payload: %v", err)
-		}
-		return validate.Struct(audience)
-		
-	default:
+	info, ok := models.LookupAssetType(asset.Type)
+	if !ok {
 		return fmt.Errorf("unknown asset type: %s", asset.Type)
 	}
-}
+	value := reflect.ValueOf(asset.Payload)
+	if value.Kind() == reflect.Pointer && !value.IsNil() {
+		value = value.Elem()
+	}
+	payload, ok := value.Interface().(models.Payload)
+	if !ok || value.Type() != reflect.TypeOf(info.Payload()).Elem() || payload.AssetType() != asset.Type {
+		return fmt.Errorf("%w for %s: got %T", models.ErrInvalidPayload, asset.Type, asset.Payload)
+	}
+	asset.Payload = payload
 
- func unmarshalPayload(payload interface{}, target interface{}) error {
-	bytes, err := json.Marshal(payload)
-	if err != nil {
-		return err
+	if value.Kind() == reflect.Struct {
+		if err := validate.Struct(payload); err != nil {
+			return err
+		}
+	}
+	if info.Validate != nil {
+		return info.Validate(payload)
 	}
-	return json.Unmarshal(bytes, target)
-}
\ No newline at end of file
+	return nil
+}
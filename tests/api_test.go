@@ -24,8 +24,7 @@ func TestFavoritesFlow(t *testing.T) {
 
 	mux := http.NewServeMux()
 	mux.Handle("/users/", http.StripPrefix("/users", api.NewHandler(svc)))
-	mux.HandleFunc("/auth/login", api.LoginHandler)
-	mux.HandleFunc("/auth/refresh", api.RefreshHandler)
+	mountAuth(mux)
 
 	handler := api.WithMiddleware(mux)
 	srv := httptest.NewServer(handler)
@@ -34,17 +33,7 @@ func TestFavoritesFlow(t *testing.T) {
 	user := "user123"
 
 	// Login to get token
-	loginReq := map[string]string{"userId": user}
-	loginBody, _ := json.Marshal(loginReq)
-	loginResp, err := http.Post(srv.URL+"/auth/login", "application/json", bytes.NewReader(loginBody))
-	require.NoError(t, err)
-	defer loginResp.Body.Close()
-
-	require.Equal(t, http.StatusOK, loginResp.StatusCode)
-
-	var tokenResp api.TokenResponse
-	err = json.NewDecoder(loginResp.Body).Decode(&tokenResp)
-	require.NoError(t, err)
+	tokenResp := loginAs(t, srv.URL, user)
 	require.NotEmpty(t, tokenResp.AccessToken)
 
 	authHeader := "Bearer " + tokenResp.AccessToken
@@ -164,3 +153,129 @@ func TestFavoritesFlow(t *testing.T) {
 	assert.Len(t, emptyResponse.Favorites, 0, "Expected 0 favorites after deletion")
 	assert.Equal(t, 0, emptyResponse.TotalCount, "Expected total count of 0 after deletion")
 }
+
+func TestGetFavorite(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret-32-chars-long-for-testing-only")
+
+	store := data.NewInMemoryStore()
+	mux := http.NewServeMux()
+	mux.Handle("/users/", http.StripPrefix("/users", api.NewHandler(core.NewService(store))))
+	mountAuth(mux)
+	srv := httptest.NewServer(api.WithMiddleware(mux))
+	defer srv.Close()
+
+	user := "getuser"
+	favID, err := store.Add(user, models.RawAsset{
+		Type:        models.TypeInsight,
+		Description: "initial",
+		Payload:     models.Insight{Text: "hello"},
+	})
+	require.NoError(t, err)
+
+	tokenResp := loginAs(t, srv.URL, user)
+
+	get := func(id string, headers map[string]string) *http.Response {
+		req, _ := http.NewRequest(http.MethodGet, srv.URL+"/users/"+user+"/favorites/"+id, nil)
+		req.Header.Set("Authorization", "Bearer "+tokenResp.AccessToken)
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		res, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		t.Cleanup(func() { res.Body.Close() })
+		return res
+	}
+
+	res := get(favID, nil)
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	etag := res.Header.Get("ETag")
+	lastModified := res.Header.Get("Last-Modified")
+	assert.NotEmpty(t, etag)
+	assert.NotEmpty(t, lastModified)
+
+	var fav models.Favorite
+	require.NoError(t, json.NewDecoder(res.Body).Decode(&fav))
+	assert.Equal(t, favID, fav.FavoriteID)
+	assert.Equal(t, "initial", fav.Asset.Description)
+
+	res = get(favID, map[string]string{"If-None-Match": etag})
+	assert.Equal(t, http.StatusNotModified, res.StatusCode)
+
+	res = get(favID, map[string]string{"If-Modified-Since": lastModified})
+	assert.Equal(t, http.StatusNotModified, res.StatusCode)
+
+	_, err = store.UpdateDescription(user, user, favID, "changed", data.AnyVersion)
+	require.NoError(t, err)
+	res = get(favID, map[string]string{"If-None-Match": etag})
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.NotEqual(t, etag, res.Header.Get("ETag"))
+
+	res = get("does-not-exist", nil)
+	assert.Equal(t, http.StatusNotFound, res.StatusCode)
+}
+
+func TestConditionalWrites(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret-32-chars-long-for-testing-only")
+
+	store := data.NewInMemoryStore()
+	mux := http.NewServeMux()
+	mux.Handle("/users/", http.StripPrefix("/users", api.NewHandler(core.NewService(store))))
+	mountAuth(mux)
+	srv := httptest.NewServer(api.WithMiddleware(mux))
+	defer srv.Close()
+
+	user := "casuser"
+	favID, err := store.Add(user, models.RawAsset{
+		Type:        models.TypeInsight,
+		Description: "initial",
+		Payload:     models.Insight{Text: "hello"},
+	})
+	require.NoError(t, err)
+
+	tokenResp := loginAs(t, srv.URL, user)
+
+	do := func(method, contentType, body string, headers map[string]string) *http.Response {
+		req, _ := http.NewRequest(method, srv.URL+"/users/"+user+"/favorites/"+favID, bytes.NewBufferString(body))
+		req.Header.Set("Authorization", "Bearer "+tokenResp.AccessToken)
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		res, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		t.Cleanup(func() { res.Body.Close() })
+		return res
+	}
+
+	res := do(http.MethodGet, "", "", nil)
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, `"1"`, res.Header.Get("ETag"))
+
+	res = do(http.MethodPut, "application/json", `{"description":"second"}`, map[string]string{"If-Match": `"1"`})
+	require.Equal(t, http.StatusNoContent, res.StatusCode)
+	assert.Equal(t, `"2"`, res.Header.Get("ETag"))
+
+	res = do(http.MethodPut, "application/json", `{"description":"lost update"}`, map[string]string{"If-Match": `"1"`})
+	assert.Equal(t, http.StatusPreconditionFailed, res.StatusCode)
+
+	res = do(http.MethodPatch, "application/merge-patch+json", `{"description":"patched"}`, map[string]string{"If-Match": `"1"`})
+	assert.Equal(t, http.StatusPreconditionFailed, res.StatusCode)
+
+	res = do(http.MethodPatch, "application/merge-patch+json", `{"description":"patched"}`, map[string]string{"If-Match": `"1", "2"`})
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, `"3"`, res.Header.Get("ETag"))
+
+	res = do(http.MethodDelete, "", "", map[string]string{"If-Match": `"2"`})
+	assert.Equal(t, http.StatusPreconditionFailed, res.StatusCode)
+
+	res = do(http.MethodDelete, "", "", map[string]string{"If-Match": `W/"3"`})
+	assert.Equal(t, http.StatusPreconditionFailed, res.StatusCode)
+
+	res = do(http.MethodDelete, "", "", map[string]string{"If-Match": `"3"`})
+	assert.Equal(t, http.StatusNoContent, res.StatusCode)
+
+	res = do(http.MethodPut, "application/json", `{"description":"gone"}`, map[string]string{"If-Match": `"3"`})
+	assert.Equal(t, http.StatusNotFound, res.StatusCode)
+}
@@ -0,0 +1,143 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Zisimopoulou/platform-go-challenge/internal/api"
+	"github.com/Zisimopoulou/platform-go-challenge/internal/auth"
+	"github.com/Zisimopoulou/platform-go-challenge/internal/core"
+	"github.com/Zisimopoulou/platform-go-challenge/internal/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPIKeys(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret-32-chars-long-for-testing-only")
+
+	keys := auth.NewAPIKeys(auth.NewInMemoryAPIKeyStore())
+	opts := []api.Option{api.WithAPIKeys(keys)}
+	mux := http.NewServeMux()
+	mux.Handle("/users/", http.StripPrefix("/users", api.NewHandler(core.NewService(data.NewInMemoryStore()), opts...)))
+	keyHandler := http.StripPrefix("/auth/api-keys", api.NewAPIKeyHandler(keys, opts...))
+	mux.Handle("/auth/api-keys", keyHandler)
+	mux.Handle("/auth/api-keys/", keyHandler)
+	mountAuth(mux)
+	srv := httptest.NewServer(api.WithMiddleware(mux))
+	defer srv.Close()
+
+	do := func(header, credential, method, path, body string) *http.Response {
+		req, _ := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+		req.Header.Set(header, credential)
+		req.Header.Set("Content-Type", "application/json")
+		res, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		t.Cleanup(func() { res.Body.Close() })
+		return res
+	}
+	bearer := "Bearer " + loginAs(t, srv.URL, "alice").AccessToken
+	withToken := func(method, path, body string) *http.Response {
+		return do("Authorization", bearer, method, path, body)
+	}
+	create := func(body string) api.APIKeyResponse {
+		res := withToken(http.MethodPost, "/auth/api-keys", body)
+		require.Equal(t, http.StatusCreated, res.StatusCode)
+		var k api.APIKeyResponse
+		require.NoError(t, json.NewDecoder(res.Body).Decode(&k))
+		return k
+	}
+
+	reader := create(`{"name":"etl-read","scopes":["favorites:read","favorites:read"]}`)
+	assert.True(t, strings.HasPrefix(reader.Key, auth.APIKeyPrefix))
+	assert.Equal(t, []core.Scope{core.ScopeFavoritesRead}, reader.Scopes)
+	assert.Nil(t, reader.LastUsedAt)
+	writer := create(`{"name":"etl-write","scopes":["favorites:write","favorites:read"]}`)
+
+	assert.Equal(t, http.StatusBadRequest, withToken(http.MethodPost, "/auth/api-keys", `{"name":"x","scopes":["favorites:admin"]}`).StatusCode)
+	assert.Equal(t, http.StatusBadRequest, withToken(http.MethodPost, "/auth/api-keys", `{"name":"x","scopes":[]}`).StatusCode)
+	assert.Equal(t, http.StatusBadRequest, withToken(http.MethodPost, "/auth/api-keys", `{"scopes":["favorites:read"]}`).StatusCode)
+
+	asset := `{"type":"insight","description":"from etl","payload":{"text":"t"}}`
+	assert.Equal(t, http.StatusForbidden, do(api.APIKeyHeader, reader.Key, http.MethodPost, "/users/alice/favorites", asset).StatusCode)
+	assert.Equal(t, http.StatusCreated, do(api.APIKeyHeader, writer.Key, http.MethodPost, "/users/alice/favorites", asset).StatusCode)
+	assert.Equal(t, http.StatusOK, do(api.APIKeyHeader, reader.Key, http.MethodGet, "/users/alice/favorites", "").StatusCode)
+	assert.Equal(t, http.StatusForbidden, do(api.APIKeyHeader, reader.Key, http.MethodGet, "/users/bob/favorites", "").StatusCode)
+	assert.Equal(t, http.StatusUnauthorized, do(api.APIKeyHeader, reader.Key+"x", http.MethodGet, "/users/alice/favorites", "").StatusCode)
+	assert.Equal(t, http.StatusUnauthorized, do(api.APIKeyHeader, "fav_nope", http.MethodGet, "/users/alice/favorites", "").StatusCode)
+
+	// A key cannot manage keys, even its own.
+	assert.Equal(t, http.StatusForbidden, do(api.APIKeyHeader, writer.Key, http.MethodGet, "/auth/api-keys", "").StatusCode)
+
+	res := withToken(http.MethodGet, "/auth/api-keys", "")
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	var listing struct {
+		Keys []api.APIKeyResponse `json:"keys"`
+	}
+	require.NoError(t, json.NewDecoder(res.Body).Decode(&listing))
+	require.Len(t, listing.Keys, 2)
+	assert.Equal(t, "etl-read", listing.Keys[0].Name)
+	assert.Empty(t, listing.Keys[0].Key, "secrets are only shown once")
+	require.NotNil(t, listing.Keys[0].LastUsedAt)
+	assert.False(t, listing.Keys[0].LastUsedAt.Before(listing.Keys[0].CreatedAt))
+
+	bob := "Bearer " + loginAs(t, srv.URL, "bob").AccessToken
+	assert.Equal(t, http.StatusNotFound, do("Authorization", bob, http.MethodDelete, "/auth/api-keys/"+reader.ID, "").StatusCode)
+
+	assert.Equal(t, http.StatusNoContent, withToken(http.MethodDelete, "/auth/api-keys/"+reader.ID, "").StatusCode)
+	assert.Equal(t, http.StatusUnauthorized, do(api.APIKeyHeader, reader.Key, http.MethodGet, "/users/alice/favorites", "").StatusCode)
+	assert.Equal(t, http.StatusNotFound, withToken(http.MethodDelete, "/auth/api-keys/"+reader.ID, "").StatusCode)
+	assert.Equal(t, http.StatusOK, do(api.APIKeyHeader, writer.Key, http.MethodGet, "/users/alice/favorites", "").StatusCode)
+}
+
+func TestAPIKeysPersistAcrossRestart(t *testing.T) {
+	backends := map[string]func(t *testing.T, dir string) (auth.APIKeyStore, func()){
+		"sqlite": func(t *testing.T, dir string) (auth.APIKeyStore, func()) {
+			store, err := data.NewSQLiteStore(filepath.Join(dir, "favorites.db"))
+			require.NoError(t, err)
+			return auth.NewSQLiteAPIKeyStore(store.DB()), func() { store.Close() }
+		},
+		"file": func(t *testing.T, dir string) (auth.APIKeyStore, func()) {
+			store, err := auth.NewFileAPIKeyStore(filepath.Join(dir, "api_keys.json"))
+			require.NoError(t, err)
+			return store, func() {}
+		},
+	}
+	for name, open := range backends {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			store, closeStore := open(t, dir)
+			keys := auth.NewAPIKeys(store)
+			kept, secret, err := keys.Create("alice", "etl", []string{"favorites:read"})
+			require.NoError(t, err)
+			revoked, _, err := keys.Create("alice", "old", nil)
+			require.NoError(t, err)
+			require.NoError(t, keys.Revoke("alice", revoked.ID))
+			assert.ErrorIs(t, keys.Revoke("bob", kept.ID), auth.ErrAPIKeyNotFound)
+			used, err := keys.Authenticate(secret)
+			require.NoError(t, err)
+			closeStore()
+
+			store, closeStore = open(t, dir)
+			defer closeStore()
+			keys = auth.NewAPIKeys(store)
+
+			list, err := keys.List("alice")
+			require.NoError(t, err)
+			require.Len(t, list, 1)
+			assert.Equal(t, kept.ID, list[0].ID)
+			assert.Equal(t, []string{"favorites:read"}, list[0].Scopes)
+			assert.Equal(t, kept.Hash, list[0].Hash)
+			assert.True(t, kept.CreatedAt.Equal(list[0].CreatedAt))
+			assert.True(t, used.LastUsedAt.Equal(list[0].LastUsedAt), "the first use is written")
+
+			again, err := keys.Authenticate(secret)
+			require.NoError(t, err)
+			assert.Equal(t, kept.ID, again.ID)
+			assert.ErrorIs(t, keys.Revoke("alice", revoked.ID), auth.ErrAPIKeyNotFound)
+		})
+	}
+}
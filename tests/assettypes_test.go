@@ -0,0 +1,128 @@
+package tests
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Zisimopoulou/platform-go-challenge/internal/api"
+	"github.com/Zisimopoulou/platform-go-challenge/internal/core"
+	"github.com/Zisimopoulou/platform-go-challenge/internal/data"
+	"github.com/Zisimopoulou/platform-go-challenge/internal/models"
+	"github.com/Zisimopoulou/platform-go-challenge/internal/validation"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type report struct {
+	Title string `json:"title" validate:"required"`
+	Pages int    `json:"pages" validate:"min=1"`
+}
+
+func (report) AssetType() models.AssetType { return "report" }
+
+var errTooLong = errors.New("reports cannot exceed 1000 pages")
+
+func registerReport(t *testing.T) {
+	t.Helper()
+	require.NoError(t, models.RegisterAssetType(models.AssetTypeInfo{
+		Name:         "report",
+		DisplayName:  "Report",
+		SearchFields: []string{"title"},
+		Payload:      func() models.Payload { return new(report) },
+		Validate: func(payload models.Payload) error {
+			if payload.(report).Pages > 1000 {
+				return errTooLong
+			}
+			return nil
+		},
+	}))
+	t.Cleanup(func() { models.UnregisterAssetType("report") })
+}
+
+func TestRegisterAssetType(t *testing.T) {
+	registerReport(t)
+
+	asset := models.RawAsset{Type: "report", Payload: report{Title: "Q3 revenue", Pages: 12}}
+	assert.NoError(t, validation.ValidateAsset(&asset))
+	asset.Payload = report{Pages: 12}
+	assert.Error(t, validation.ValidateAsset(&asset))
+	asset.Payload = report{Title: "War and Peace", Pages: 1225}
+	assert.ErrorIs(t, validation.ValidateAsset(&asset), errTooLong)
+	asset.Type = "dashboard"
+	assert.ErrorContains(t, validation.ValidateAsset(&asset), "unknown asset type")
+
+	assert.ErrorIs(t, models.RegisterAssetType(models.AssetTypeInfo{Name: "report", Payload: func() models.Payload { return new(report) }}), models.ErrAssetTypeExists)
+	assert.ErrorIs(t, models.RegisterAssetType(models.AssetTypeInfo{Name: "Bad Name", Payload: func() models.Payload { return new(report) }}), models.ErrInvalidAssetType)
+	assert.ErrorIs(t, models.RegisterAssetType(models.AssetTypeInfo{Name: "nopayload"}), models.ErrInvalidAssetType)
+
+	var names []models.AssetType
+	for _, info := range models.AssetTypes() {
+		names = append(names, info.Name)
+	}
+	assert.Equal(t, []models.AssetType{models.TypeAudience, models.TypeChart, models.TypeInsight, "report"}, names)
+
+	store := data.NewInMemoryStore()
+	_, err := store.Add("alice", models.RawAsset{Type: "report", Payload: report{Title: "Quarterly revenue", Pages: 3}})
+	require.NoError(t, err)
+	results, total, err := store.Search("alice", "quarterly", 10)
+	require.NoError(t, err)
+	assert.Equal(t, 1, total)
+	assert.Contains(t, results[0].Highlights, "title")
+}
+
+func TestAssetTypesEndpoint(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret-32-chars-long-for-testing-only")
+	registerReport(t)
+
+	svc := core.NewService(data.NewInMemoryStore())
+	mux := http.NewServeMux()
+	mux.Handle("/users/", http.StripPrefix("/users", api.NewHandler(svc)))
+	mux.Handle("/asset-types", api.NewAssetTypesHandler())
+	mountAuth(mux)
+	srv := httptest.NewServer(api.WithMiddleware(mux))
+	defer srv.Close()
+
+	token := loginAs(t, srv.URL, "alice").AccessToken
+	do := func(method, path, body string) *http.Response {
+		req, _ := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Content-Type", "application/json")
+		res, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		t.Cleanup(func() { res.Body.Close() })
+		return res
+	}
+
+	res := do(http.MethodGet, "/asset-types", "")
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	var listing struct {
+		AssetTypes []models.AssetTypeInfo `json:"assetTypes"`
+	}
+	require.NoError(t, json.NewDecoder(res.Body).Decode(&listing))
+	require.Len(t, listing.AssetTypes, 4)
+	assert.Equal(t, models.TypeChart, listing.AssetTypes[1].Name)
+	assert.Equal(t, "Chart", listing.AssetTypes[1].DisplayName)
+	assert.Equal(t, "Report", listing.AssetTypes[3].DisplayName)
+	assert.Equal(t, []string{"title"}, listing.AssetTypes[3].SearchFields)
+	assert.Equal(t, http.StatusMethodNotAllowed, do(http.MethodPost, "/asset-types", "").StatusCode)
+
+	anon, err := http.Get(srv.URL + "/asset-types")
+	require.NoError(t, err)
+	anon.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, anon.StatusCode)
+
+	assert.Equal(t, http.StatusCreated, do(http.MethodPost, "/users/alice/favorites", `{"type":"report","payload":{"title":"Q3","pages":4}}`).StatusCode)
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodPost, "/users/alice/favorites", `{"type":"report","payload":{"title":"Q3","pages":0}}`).StatusCode)
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodPost, "/users/alice/favorites", `{"type":"dashboard","payload":{}}`).StatusCode)
+
+	res = do(http.MethodGet, "/users/alice/favorites?type=report", "")
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	var page models.PaginatedFavorites
+	require.NoError(t, json.NewDecoder(res.Body).Decode(&page))
+	assert.Equal(t, 1, page.TotalCount)
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodGet, "/users/alice/favorites?type=dashboard", "").StatusCode)
+}
@@ -1,8 +1,6 @@
 package tests
 
 import (
-	"bytes"
-	"encoding/json"
 	"log"
 	"net/http"
 	"net/http/httptest"
@@ -25,7 +23,7 @@ func TestAuthScenarios(t *testing.T) {
 	
 	mux := http.NewServeMux()
 	mux.Handle("/users/", http.StripPrefix("/users", api.NewHandler(svc)))
-	mux.HandleFunc("/auth/login", api.LoginHandler)
+	mountAuth(mux)
 	
 	handler := api.WithMiddleware(mux)
 	srv := httptest.NewServer(handler)
@@ -56,16 +54,7 @@ func TestAuthScenarios(t *testing.T) {
 		t.Errorf("Expected 401 for invalid format, got %d", res.StatusCode)
 	}
 
-	loginReq := map[string]string{"userId": "user1"}
-	loginBody, _ := json.Marshal(loginReq)
-	loginResp, err := http.Post(srv.URL+"/auth/login", "application/json", bytes.NewReader(loginBody))
-	if err != nil {
-		t.Fatal(err)
-	}
-	defer loginResp.Body.Close()
-
-	var tokenResp api.TokenResponse
-	json.NewDecoder(loginResp.Body).Decode(&tokenResp)
+	tokenResp := loginAs(t, srv.URL, "user1")
 	accessToken := tokenResp.AccessToken
 
 	req, _ = http.NewRequest(http.MethodGet, srv.URL+"/users/user2/favorites", nil)
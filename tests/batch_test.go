@@ -0,0 +1,210 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Zisimopoulou/platform-go-challenge/internal/api"
+	"github.com/Zisimopoulou/platform-go-challenge/internal/core"
+	"github.com/Zisimopoulou/platform-go-challenge/internal/data"
+	"github.com/Zisimopoulou/platform-go-challenge/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStoreBatch(t *testing.T) {
+	for name, newStore := range storeImplementations() {
+		t.Run(name, func(t *testing.T) {
+			t.Run("non-atomic applies what it can", func(t *testing.T) {
+				store := newStore(t)
+				existing, err := store.Add("alice", insightAsset("existing"))
+				require.NoError(t, err)
+
+				updated := insightAsset("renamed")
+				updated.ID = existing
+				results, err := store.Batch("alice", []data.BatchOp{
+					{Kind: data.BatchAdd, Asset: insightAsset("first")},
+					{Kind: data.BatchDelete, Asset: models.RawAsset{ID: "missing"}},
+					{Kind: data.BatchUpdate, Asset: updated, ExpectedVersion: 1},
+					{Kind: data.BatchUpdate, Asset: updated, ExpectedVersion: 1},
+				}, false)
+				require.NoError(t, err)
+				require.Len(t, results, 4)
+
+				assert.NoError(t, results[0].Err)
+				assert.NotEmpty(t, results[0].Asset.ID)
+				assert.Equal(t, int64(1), results[0].Asset.Version)
+				assert.ErrorIs(t, results[1].Err, data.ErrNotFound)
+				assert.NoError(t, results[2].Err)
+				assert.Equal(t, int64(2), results[2].Asset.Version)
+				assert.ErrorIs(t, results[3].Err, data.ErrVersionMismatch)
+
+				favs, total, err := store.List("alice", data.ListQuery{Limit: 10})
+				require.NoError(t, err)
+				assert.Equal(t, 2, total)
+				assert.Equal(t, []string{"first", "renamed"}, descriptionsOf(favs))
+
+				hits, _, err := store.Search("alice", "renamed", 10)
+				require.NoError(t, err)
+				require.Len(t, hits, 1)
+				assert.Equal(t, existing, hits[0].FavoriteID)
+			})
+
+			t.Run("atomic failure leaves the store untouched", func(t *testing.T) {
+				store := newStore(t)
+				existing, err := store.Add("alice", insightAsset("existing"))
+				require.NoError(t, err)
+
+				results, err := store.Batch("alice", []data.BatchOp{
+					{Kind: data.BatchAdd, Asset: insightAsset("added")},
+					{Kind: data.BatchDelete, Asset: models.RawAsset{ID: existing}},
+					{Kind: data.BatchDelete, Asset: models.RawAsset{ID: existing}},
+				}, true)
+				require.NoError(t, err)
+				require.Len(t, results, 3)
+				assert.ErrorIs(t, results[0].Err, data.ErrBatchAborted)
+				assert.ErrorIs(t, results[1].Err, data.ErrBatchAborted)
+				assert.ErrorIs(t, results[2].Err, data.ErrNotFound)
+
+				favs, total, err := store.List("alice", data.ListQuery{Limit: 10})
+				require.NoError(t, err)
+				assert.Equal(t, 1, total)
+				assert.Equal(t, []string{"existing"}, descriptionsOf(favs))
+
+				hits, _, err := store.Search("alice", "added", 10)
+				require.NoError(t, err)
+				assert.Empty(t, hits)
+			})
+
+			t.Run("atomic success", func(t *testing.T) {
+				store := newStore(t)
+				existing, err := store.Add("alice", insightAsset("existing"))
+				require.NoError(t, err)
+
+				results, err := store.Batch("alice", []data.BatchOp{
+					{Kind: data.BatchAdd, Asset: insightAsset("one")},
+					{Kind: data.BatchAdd, Asset: insightAsset("two")},
+					{Kind: data.BatchDelete, Asset: models.RawAsset{ID: existing}, ExpectedVersion: 1},
+				}, true)
+				require.NoError(t, err)
+				for _, r := range results {
+					require.NoError(t, r.Err)
+				}
+				assert.NotEqual(t, results[0].Asset.ID, results[1].Asset.ID)
+
+				_, total, err := store.List("alice", data.ListQuery{Limit: 10})
+				require.NoError(t, err)
+				assert.Equal(t, 2, total)
+				_, err = store.Get("alice", existing)
+				assert.ErrorIs(t, err, data.ErrNotFound)
+			})
+		})
+	}
+}
+
+func TestJournalReplaysAtomicBatch(t *testing.T) {
+	dir := t.TempDir()
+	store := openJournaled(t, dir, 0)
+
+	existing, err := store.Add("alice", insightAsset("existing"))
+	require.NoError(t, err)
+	_, err = store.Batch("alice", []data.BatchOp{
+		{Kind: data.BatchAdd, Asset: insightAsset("added")},
+		{Kind: data.BatchDelete, Asset: models.RawAsset{ID: existing}},
+	}, true)
+	require.NoError(t, err)
+	results, err := store.Batch("alice", []data.BatchOp{
+		{Kind: data.BatchAdd, Asset: insightAsset("rolled back")},
+		{Kind: data.BatchDelete, Asset: models.RawAsset{ID: existing}},
+	}, true)
+	require.NoError(t, err)
+	assert.ErrorIs(t, results[1].Err, data.ErrNotFound)
+
+	reopened := openJournaled(t, dir, 0)
+	defer reopened.Close()
+
+	favs, total, err := reopened.List("alice", data.ListQuery{Limit: 10})
+	require.NoError(t, err)
+	assert.Equal(t, 1, total)
+	assert.Equal(t, []string{"added"}, descriptionsOf(favs))
+}
+
+func TestBatchEndpoint(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret-32-chars-long-for-testing-only")
+
+	store := data.NewInMemoryStore()
+	mux := http.NewServeMux()
+	mux.Handle("/users/", http.StripPrefix("/users", api.NewHandler(core.NewService(store))))
+	mountAuth(mux)
+	srv := httptest.NewServer(api.WithMiddleware(mux))
+	defer srv.Close()
+
+	user := "batchuser"
+	existing, err := store.Add(user, insightAsset("existing"))
+	require.NoError(t, err)
+
+	tokenResp := loginAs(t, srv.URL, user)
+
+	post := func(body interface{}) (int, models.BatchResponse) {
+		raw, _ := json.Marshal(body)
+		req, _ := http.NewRequest(http.MethodPost, srv.URL+"/users/"+user+"/favorites/batch", bytes.NewReader(raw))
+		req.Header.Set("Authorization", "Bearer "+tokenResp.AccessToken)
+		req.Header.Set("Content-Type", "application/json")
+		res, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer res.Body.Close()
+		var out models.BatchResponse
+		if res.StatusCode == http.StatusOK || res.StatusCode == http.StatusMultiStatus {
+			require.NoError(t, json.NewDecoder(res.Body).Decode(&out))
+		}
+		return res.StatusCode, out
+	}
+
+	valid := insightAsset("from batch")
+	invalid := models.RawAsset{Type: models.TypeChart, Payload: models.Chart{Title: "no axes"}}
+
+	code, resp := post(models.BatchRequest{Atomic: true, Operations: []models.BatchOperation{
+		{Op: "add", Asset: &valid},
+		{Op: "add", Asset: &invalid},
+	}})
+	assert.Equal(t, http.StatusMultiStatus, code)
+	assert.Equal(t, 0, resp.Succeeded)
+	require.Len(t, resp.Results, 2)
+	assert.Equal(t, http.StatusFailedDependency, resp.Results[0].Status)
+	assert.Equal(t, http.StatusBadRequest, resp.Results[1].Status)
+	_, total, err := store.List(user, data.ListQuery{Limit: 10})
+	require.NoError(t, err)
+	assert.Equal(t, 1, total)
+
+	code, resp = post(models.BatchRequest{Operations: []models.BatchOperation{
+		{Op: "add", Asset: &valid},
+		{Op: "add", Asset: &invalid},
+		{Op: "update", ID: existing, Version: 1, Asset: &valid},
+		{Op: "delete", ID: existing, Version: 1},
+		{Op: "rename", ID: existing},
+	}})
+	assert.Equal(t, http.StatusMultiStatus, code)
+	assert.Equal(t, 2, resp.Succeeded)
+	assert.Equal(t, 3, resp.Failed)
+	require.Len(t, resp.Results, 5)
+	assert.Equal(t, http.StatusCreated, resp.Results[0].Status)
+	require.NotNil(t, resp.Results[0].Favorite)
+	assert.Equal(t, "from batch", resp.Results[0].Favorite.Asset.Description)
+	assert.Equal(t, http.StatusBadRequest, resp.Results[1].Status)
+	assert.Equal(t, http.StatusOK, resp.Results[2].Status)
+	assert.Equal(t, http.StatusPreconditionFailed, resp.Results[3].Status)
+	assert.Equal(t, http.StatusBadRequest, resp.Results[4].Status)
+
+	code, resp = post(models.BatchRequest{Operations: []models.BatchOperation{
+		{Op: "delete", ID: existing, Version: 2},
+	}})
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, http.StatusNoContent, resp.Results[0].Status)
+	assert.Equal(t, existing, resp.Results[0].FavoriteID)
+
+	code, _ = post(models.BatchRequest{})
+	assert.Equal(t, http.StatusBadRequest, code)
+}
@@ -0,0 +1,137 @@
+package tests
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/Zisimopoulou/platform-go-challenge/internal/models"
+	"github.com/Zisimopoulou/platform-go-challenge/internal/validation"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateChart(t *testing.T) {
+	day := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		chart   models.Chart
+		wantErr error
+	}{
+		{
+			name:  "legacy integer data",
+			chart: models.Chart{Title: "T", XAxis: "x", YAxis: "y", Data: []int{1, 2, 3}},
+		},
+		{
+			name: "named series over labels",
+			chart: models.Chart{
+				Title: "Revenue", Kind: models.ChartBar, XAxis: "Quarter", YAxis: "Revenue", YUnit: "EUR",
+				Labels: []string{"Q1", "Q2", "Q3"},
+				Series: []models.Series{{Name: "2025", Values: []float64{1.5, 2, 2.25}}, {Name: "2026", Values: []float64{2, 2.5, 3}}},
+			},
+		},
+		{
+			name: "series over timestamps",
+			chart: models.Chart{
+				Title: "Visits", Kind: models.ChartLine, XAxis: "Day", YAxis: "Visits",
+				Timestamps: []time.Time{day, day.AddDate(0, 0, 1)},
+				Series:     []models.Series{{Name: "web", Values: []float64{10, 12}}},
+			},
+		},
+		{
+			name:  "scatter by position",
+			chart: models.Chart{Title: "Spread", Kind: models.ChartScatter, XAxis: "x", YAxis: "y", Series: []models.Series{{Name: "s", Values: []float64{0.1, -3}}}},
+		},
+		{
+			name: "pie without axes",
+			chart: models.Chart{
+				Title: "Share", Kind: models.ChartPie, Labels: []string{"a", "b"},
+				Series: []models.Series{{Name: "share", Values: []float64{60, 40}}},
+			},
+		},
+		{
+			name:    "no data",
+			chart:   models.Chart{Title: "T", XAxis: "x", YAxis: "y"},
+			wantErr: models.ErrChartData,
+		},
+		{
+			name:    "data and series",
+			chart:   models.Chart{Title: "T", XAxis: "x", YAxis: "y", Data: []int{1}, Series: []models.Series{{Name: "s", Values: []float64{1}}}},
+			wantErr: models.ErrChartData,
+		},
+		{
+			name:    "series shorter than labels",
+			chart:   models.Chart{Title: "T", XAxis: "x", YAxis: "y", Labels: []string{"a", "b"}, Series: []models.Series{{Name: "s", Values: []float64{1}}}},
+			wantErr: models.ErrChartSeries,
+		},
+		{
+			name:    "legacy data longer than labels",
+			chart:   models.Chart{Title: "T", XAxis: "x", YAxis: "y", Labels: []string{"a"}, Data: []int{1, 2}},
+			wantErr: models.ErrChartSeries,
+		},
+		{
+			name:    "labels and timestamps",
+			chart:   models.Chart{Title: "T", XAxis: "x", YAxis: "y", Labels: []string{"a"}, Timestamps: []time.Time{day}, Data: []int{1}},
+			wantErr: models.ErrChartXAxis,
+		},
+		{
+			name:    "duplicate series names",
+			chart:   models.Chart{Title: "T", XAxis: "x", YAxis: "y", Series: []models.Series{{Name: "s", Values: []float64{1}}, {Name: "s", Values: []float64{2}}}},
+			wantErr: models.ErrChartSeries,
+		},
+		{
+			name: "pie with two series",
+			chart: models.Chart{
+				Title: "Share", Kind: models.ChartPie, Labels: []string{"a"},
+				Series: []models.Series{{Name: "s", Values: []float64{1}}, {Name: "t", Values: []float64{2}}},
+			},
+			wantErr: models.ErrChartSeries,
+		},
+		{
+			name:    "negative pie slice",
+			chart:   models.Chart{Title: "Share", Kind: models.ChartPie, Labels: []string{"a", "b"}, Series: []models.Series{{Name: "s", Values: []float64{1, -1}}}},
+			wantErr: models.ErrChartSeries,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			asset := models.RawAsset{Type: models.TypeChart, Payload: tt.chart}
+			err := validation.ValidateAsset(&asset)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+
+	for _, chart := range []models.Chart{
+		{Title: "T", Kind: "area", XAxis: "x", YAxis: "y", Data: []int{1}},
+		{Title: "T", Kind: models.ChartLine, Series: []models.Series{{Name: "s", Values: []float64{1}}}},
+		{Title: "T", XAxis: "x", YAxis: "y", Series: []models.Series{{Values: []float64{1}}}},
+		{Title: "T", XAxis: "x", YAxis: "y", Series: []models.Series{{Name: "s"}}},
+	} {
+		asset := models.RawAsset{Type: models.TypeChart, Payload: chart}
+		assert.Error(t, validation.ValidateAsset(&asset), "%+v", chart)
+	}
+}
+
+func TestChartJSON(t *testing.T) {
+	var asset models.RawAsset
+	require.NoError(t, json.Unmarshal([]byte(`{"type":"chart","payload":{"title":"Old","xAxis":"x","yAxis":"y","data":[1,2]}}`), &asset))
+	require.NoError(t, validation.ValidateAsset(&asset))
+	legacy := asset.Payload.(models.Chart)
+	assert.Equal(t, []int{1, 2}, legacy.Data)
+	assert.Equal(t, []models.Series{{Values: []float64{1, 2}}}, legacy.AllSeries())
+
+	const doc = `{"title":"Revenue","kind":"line","xAxis":"Month","yAxis":"Revenue","yUnit":"EUR","timestamps":["2026-01-01T00:00:00Z","2026-02-01T00:00:00Z"],"series":[{"name":"EU","values":[1.25,2.5]},{"name":"US","values":[3,4.75]}]}`
+	require.NoError(t, json.Unmarshal([]byte(`{"type":"chart","payload":`+doc+`}`), &asset))
+	require.NoError(t, validation.ValidateAsset(&asset))
+	chart := asset.Payload.(models.Chart)
+	assert.Equal(t, models.ChartLine, chart.Kind)
+	assert.Equal(t, []float64{1.25, 2.5}, chart.Series[0].Values)
+	encoded, err := json.Marshal(chart)
+	require.NoError(t, err)
+	assert.JSONEq(t, doc, string(encoded))
+}
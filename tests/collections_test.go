@@ -0,0 +1,262 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Zisimopoulou/platform-go-challenge/internal/api"
+	"github.com/Zisimopoulou/platform-go-challenge/internal/core"
+	"github.com/Zisimopoulou/platform-go-challenge/internal/data"
+	"github.com/Zisimopoulou/platform-go-challenge/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func collectionIDs(list []models.Collection) []string {
+	out := make([]string, 0, len(list))
+	for _, c := range list {
+		out = append(out, c.ID)
+	}
+	return out
+}
+
+func TestStoreCollections(t *testing.T) {
+	for name, newStore := range storeImplementations() {
+		t.Run(name, func(t *testing.T) {
+			store := newStore(t)
+			a, err := store.Add("alice", insightAsset("a"))
+			require.NoError(t, err)
+			b, err := store.Add("alice", insightAsset("b"))
+			require.NoError(t, err)
+
+			work, err := store.CreateCollection("alice", "work")
+			require.NoError(t, err)
+			assert.NotEmpty(t, work.ID)
+			assert.Equal(t, 0, work.Position)
+			home, err := store.CreateCollection("alice", "home")
+			require.NoError(t, err)
+			assert.Equal(t, 1, home.Position)
+			_, err = store.CreateCollection("alice", "work")
+			assert.ErrorIs(t, err, data.ErrCollectionExists)
+			_, err = store.CreateCollection("bob", "work")
+			assert.NoError(t, err, "names are unique per user")
+
+			_, err = store.RenameCollection("alice", home.ID, "work")
+			assert.ErrorIs(t, err, data.ErrCollectionExists)
+			home, err = store.RenameCollection("alice", home.ID, "personal")
+			require.NoError(t, err)
+			assert.Equal(t, "personal", home.Name)
+			_, err = store.RenameCollection("alice", "missing", "x")
+			assert.ErrorIs(t, err, data.ErrNotFound)
+
+			_, err = store.ReorderCollections("alice", []string{home.ID})
+			assert.ErrorIs(t, err, data.ErrInvalidOrder)
+			_, err = store.ReorderCollections("alice", []string{home.ID, home.ID})
+			assert.ErrorIs(t, err, data.ErrInvalidOrder)
+			list, err := store.ReorderCollections("alice", []string{home.ID, work.ID})
+			require.NoError(t, err)
+			assert.Equal(t, []string{home.ID, work.ID}, collectionIDs(list))
+
+			// A favorite may sit in several collections; adding twice is a no-op.
+			require.NoError(t, store.AddToCollection("alice", work.ID, a))
+			require.NoError(t, store.AddToCollection("alice", work.ID, a))
+			require.NoError(t, store.AddToCollection("alice", work.ID, b))
+			require.NoError(t, store.AddToCollection("alice", home.ID, a))
+			assert.ErrorIs(t, store.AddToCollection("alice", work.ID, "missing"), data.ErrNotFound)
+			assert.ErrorIs(t, store.AddToCollection("alice", "missing", a), data.ErrNotFound)
+			assert.ErrorIs(t, store.AddToCollection("bob", work.ID, a), data.ErrNotFound)
+
+			work, err = store.GetCollection("alice", work.ID)
+			require.NoError(t, err)
+			assert.Equal(t, 2, work.Favorites)
+
+			favs, total, err := store.List("alice", data.ListQuery{Limit: 10, Filter: data.ListFilter{Collection: work.ID}})
+			require.NoError(t, err)
+			assert.Equal(t, 2, total)
+			assert.Equal(t, []string{"b", "a"}, descriptionsOf(favs))
+
+			// A failed atomic batch must restore the memberships of a
+			// favorite it deleted along the way.
+			results, err := store.Batch("alice", []data.BatchOp{
+				{Kind: data.BatchDelete, Asset: models.RawAsset{ID: a}},
+				{Kind: data.BatchDelete, Asset: models.RawAsset{ID: "missing"}},
+			}, true)
+			require.NoError(t, err)
+			assert.ErrorIs(t, results[1].Err, data.ErrNotFound)
+			home, err = store.GetCollection("alice", home.ID)
+			require.NoError(t, err)
+			assert.Equal(t, 1, home.Favorites)
+
+			// Deleting a favorite takes it out of every collection.
+			require.NoError(t, store.Delete("alice", a, data.AnyVersion))
+			list, err = store.ListCollections("alice")
+			require.NoError(t, err)
+			require.Len(t, list, 2)
+			assert.Equal(t, 0, list[0].Favorites)
+			assert.Equal(t, 1, list[1].Favorites)
+
+			require.NoError(t, store.RemoveFromCollection("alice", work.ID, b))
+			assert.ErrorIs(t, store.RemoveFromCollection("alice", work.ID, b), data.ErrNotFound)
+			require.NoError(t, store.AddToCollection("alice", work.ID, b))
+
+			// Deleting a collection keeps its favorites.
+			require.NoError(t, store.DeleteCollection("alice", work.ID))
+			assert.ErrorIs(t, store.DeleteCollection("alice", work.ID), data.ErrNotFound)
+			_, err = store.Get("alice", b)
+			assert.NoError(t, err)
+			list, err = store.ListCollections("alice")
+			require.NoError(t, err)
+			assert.Equal(t, []string{home.ID}, collectionIDs(list))
+		})
+	}
+}
+
+func TestJournalReplaysCollections(t *testing.T) {
+	dir := t.TempDir()
+	store := openJournaled(t, dir, 0)
+	a, err := store.Add("alice", insightAsset("a"))
+	require.NoError(t, err)
+	b, err := store.Add("alice", insightAsset("b"))
+	require.NoError(t, err)
+	work, err := store.CreateCollection("alice", "work")
+	require.NoError(t, err)
+	gone, err := store.CreateCollection("alice", "gone")
+	require.NoError(t, err)
+	home, err := store.CreateCollection("alice", "home")
+	require.NoError(t, err)
+	_, err = store.RenameCollection("alice", home.ID, "personal")
+	require.NoError(t, err)
+	_, err = store.ReorderCollections("alice", []string{home.ID, gone.ID, work.ID})
+	require.NoError(t, err)
+	require.NoError(t, store.AddToCollection("alice", work.ID, a))
+	require.NoError(t, store.AddToCollection("alice", work.ID, b))
+	require.NoError(t, store.AddToCollection("alice", gone.ID, a))
+	require.NoError(t, store.DeleteCollection("alice", gone.ID))
+	require.NoError(t, store.Delete("alice", a, data.AnyVersion))
+
+	check := func(s data.Store) {
+		list, err := s.ListCollections("alice")
+		require.NoError(t, err)
+		require.Len(t, list, 2)
+		assert.Equal(t, "personal", list[0].Name)
+		assert.Equal(t, work.ID, list[1].ID)
+		assert.Equal(t, 1, list[1].Favorites)
+	}
+
+	fromJournal := openJournaled(t, dir, 0)
+	check(fromJournal)
+	require.NoError(t, fromJournal.Close())
+
+	fromSnapshot := openJournaled(t, dir, 0)
+	defer fromSnapshot.Close()
+	check(fromSnapshot)
+}
+
+func TestCollectionEndpoints(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret-32-chars-long-for-testing-only")
+
+	svc := core.NewService(data.NewInMemoryStore())
+	mux := http.NewServeMux()
+	mux.Handle("/users/", http.StripPrefix("/users", api.NewHandler(svc)))
+	mountAuth(mux)
+	srv := httptest.NewServer(api.WithMiddleware(mux))
+	defer srv.Close()
+
+	token := loginAs(t, srv.URL, "alice").AccessToken
+	do := func(method, path, body string) *http.Response {
+		req, _ := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Content-Type", "application/json")
+		res, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		t.Cleanup(func() { res.Body.Close() })
+		return res
+	}
+
+	res := do(http.MethodPost, "/users/alice/collections", `{"name":"  Q3 review "}`)
+	require.Equal(t, http.StatusCreated, res.StatusCode)
+	var coll models.Collection
+	require.NoError(t, json.NewDecoder(res.Body).Decode(&coll))
+	assert.Equal(t, "Q3 review", coll.Name)
+	collPath := "/users/alice/collections/" + coll.ID
+
+	assert.Equal(t, http.StatusConflict, do(http.MethodPost, "/users/alice/collections", `{"name":"Q3 review"}`).StatusCode)
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodPost, "/users/alice/collections", `{"name":"  "}`).StatusCode)
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodPost, "/users/alice/collections", `{"name":"`+strings.Repeat("x", 101)+`"}`).StatusCode)
+
+	var ids []string
+	for _, desc := range []string{"one", "two", "three", "outside"} {
+		res := do(http.MethodPost, "/users/alice/favorites", `{"type":"insight","description":"`+desc+`","payload":{"text":"t"}}`)
+		require.Equal(t, http.StatusCreated, res.StatusCode)
+		var created map[string]string
+		require.NoError(t, json.NewDecoder(res.Body).Decode(&created))
+		ids = append(ids, created["favoriteId"])
+	}
+	for _, id := range ids[:3] {
+		assert.Equal(t, http.StatusNoContent, do(http.MethodPut, collPath+"/favorites/"+id, "").StatusCode)
+	}
+	assert.Equal(t, http.StatusNotFound, do(http.MethodPut, collPath+"/favorites/missing", "").StatusCode)
+
+	res = do(http.MethodGet, collPath+"/favorites?limit=2", "")
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	var page models.PaginatedFavorites
+	require.NoError(t, json.NewDecoder(res.Body).Decode(&page))
+	assert.Equal(t, 3, page.TotalCount)
+	assert.True(t, page.HasMore)
+	assert.Equal(t, []string{"three", "two"}, descriptionsOf(page.Favorites))
+	require.NotEmpty(t, page.NextCursor)
+
+	res = do(http.MethodGet, collPath+"/favorites?limit=2&cursor="+page.NextCursor, "")
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	page = models.PaginatedFavorites{}
+	require.NoError(t, json.NewDecoder(res.Body).Decode(&page))
+	assert.Equal(t, []string{"one"}, descriptionsOf(page.Favorites))
+	assert.False(t, page.HasMore)
+
+	// A cursor from one collection does not page through another listing.
+	res = do(http.MethodGet, "/users/alice/favorites?limit=2", "")
+	var all models.PaginatedFavorites
+	require.NoError(t, json.NewDecoder(res.Body).Decode(&all))
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodGet, collPath+"/favorites?cursor="+all.NextCursor, "").StatusCode)
+	assert.Equal(t, http.StatusNotFound, do(http.MethodGet, "/users/alice/collections/missing/favorites", "").StatusCode)
+
+	res = do(http.MethodPost, "/users/alice/collections", `{"name":"later"}`)
+	var later models.Collection
+	require.NoError(t, json.NewDecoder(res.Body).Decode(&later))
+	res = do(http.MethodPut, "/users/alice/collections/order", `{"ids":["`+later.ID+`","`+coll.ID+`"]}`)
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	var listing struct {
+		Collections []models.Collection `json:"collections"`
+	}
+	require.NoError(t, json.NewDecoder(res.Body).Decode(&listing))
+	assert.Equal(t, []string{later.ID, coll.ID}, collectionIDs(listing.Collections))
+	assert.Equal(t, 3, listing.Collections[1].Favorites)
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodPut, "/users/alice/collections/order", `{"ids":["`+later.ID+`"]}`).StatusCode)
+
+	res = do(http.MethodPatch, collPath, `{"name":"Q4 review"}`)
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	require.NoError(t, json.NewDecoder(res.Body).Decode(&coll))
+	assert.Equal(t, "Q4 review", coll.Name)
+
+	bob := loginAs(t, srv.URL, "bob").AccessToken
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+collPath, nil)
+	req.Header.Set("Authorization", "Bearer "+bob)
+	res, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusForbidden, res.StatusCode)
+
+	assert.Equal(t, http.StatusNoContent, do(http.MethodDelete, collPath+"/favorites/"+ids[0], "").StatusCode)
+	assert.Equal(t, http.StatusNoContent, do(http.MethodDelete, "/users/alice/favorites/"+ids[1], "").StatusCode)
+	res = do(http.MethodGet, collPath, "")
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	require.NoError(t, json.NewDecoder(res.Body).Decode(&coll))
+	assert.Equal(t, 1, coll.Favorites)
+
+	assert.Equal(t, http.StatusNoContent, do(http.MethodDelete, collPath, "").StatusCode)
+	assert.Equal(t, http.StatusNotFound, do(http.MethodGet, collPath, "").StatusCode)
+	assert.Equal(t, http.StatusOK, do(http.MethodGet, "/users/alice/favorites/"+ids[2], "").StatusCode)
+}
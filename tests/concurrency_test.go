@@ -3,16 +3,22 @@ package tests
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"log"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strings"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/Zisimopoulou/platform-go-challenge/internal/core"
 	"github.com/Zisimopoulou/platform-go-challenge/internal/api"
 	"github.com/Zisimopoulou/platform-go-challenge/internal/data"
+	"github.com/Zisimopoulou/platform-go-challenge/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestConcurrentAccess(t *testing.T) {
@@ -26,7 +32,7 @@ func TestConcurrentAccess(t *testing.T) {
 	
 	mux := http.NewServeMux()
 	mux.Handle("/users/", http.StripPrefix("/users", api.NewHandler(svc)))
-	mux.HandleFunc("/auth/login", api.LoginHandler)
+	mountAuth(mux)
 	
 	handler := api.WithMiddleware(mux)
 	srv := httptest.NewServer(handler)
@@ -34,16 +40,7 @@ func TestConcurrentAccess(t *testing.T) {
 
 	user := "concurrentuser"
 	
-	loginReq := map[string]string{"userId": user}
-	loginBody, _ := json.Marshal(loginReq)
-	loginResp, err := http.Post(srv.URL+"/auth/login", "application/json", bytes.NewReader(loginBody))
-	if err != nil {
-		t.Fatal(err)
-	}
-	defer loginResp.Body.Close()
-
-	var tokenResp api.TokenResponse
-	json.NewDecoder(loginResp.Body).Decode(&tokenResp)
+	tokenResp := loginAs(t, srv.URL, user)
 	accessToken := tokenResp.AccessToken
 
 	client := &http.Client{}
@@ -86,4 +83,62 @@ func TestConcurrentAccess(t *testing.T) {
 	}
 
 	wg.Wait()
-}
\ No newline at end of file
+}
+// slowReads widens the window between reading a favorite and writing it
+// back, so read-modify-write races show up even on one CPU.
+type slowReads struct {
+	data.Store
+}
+
+func (s slowReads) Get(userID, favID string) (models.RawAsset, error) {
+	asset, err := s.Store.Get(userID, favID)
+	time.Sleep(time.Millisecond)
+	return asset, err
+}
+
+func TestConcurrentUnconditionalUpdates(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret-32-chars-long-for-testing-only")
+	for name, newStore := range storeImplementations() {
+		t.Run(name, func(t *testing.T) {
+			store := newStore(t)
+			mux := http.NewServeMux()
+			mux.Handle("/users/", http.StripPrefix("/users", api.NewHandler(core.NewService(slowReads{store}))))
+			mountAuth(mux)
+			srv := httptest.NewServer(api.WithMiddleware(mux))
+			defer srv.Close()
+
+			id, err := store.Add("alice", insightAsset("initial"))
+			require.NoError(t, err)
+			token := loginAs(t, srv.URL, "alice").AccessToken
+
+			// Without If-Match the last writer wins; nobody gets a 412.
+			const writers = 16
+			statuses := make([]int, writers)
+			var wg sync.WaitGroup
+			for i := range writers {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					body := fmt.Sprintf(`{"description":"writer %d"}`, i)
+					req, _ := http.NewRequest(http.MethodPut, srv.URL+"/users/alice/favorites/"+id, strings.NewReader(body))
+					req.Header.Set("Authorization", "Bearer "+token)
+					req.Header.Set("Content-Type", "application/json")
+					res, err := http.DefaultClient.Do(req)
+					if !assert.NoError(t, err) {
+						return
+					}
+					res.Body.Close()
+					statuses[i] = res.StatusCode
+				}()
+			}
+			wg.Wait()
+			for _, status := range statuses {
+				assert.Equal(t, http.StatusNoContent, status)
+			}
+
+			got, err := store.Get("alice", id)
+			require.NoError(t, err)
+			assert.Equal(t, int64(1+writers), got.Version)
+		})
+	}
+}
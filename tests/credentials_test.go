@@ -0,0 +1,80 @@
+package tests
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Zisimopoulou/platform-go-challenge/internal/api"
+	"github.com/Zisimopoulou/platform-go-challenge/internal/auth"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestRegisterAndLogin(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret-32-chars-long-for-testing-only")
+
+	mux := http.NewServeMux()
+	mountAuth(mux)
+	srv := httptest.NewServer(api.WithMiddleware(mux))
+	defer srv.Close()
+
+	res := postCredentials(t, srv.URL+"/auth/register", "alice", testPassword)
+	require.Equal(t, http.StatusCreated, res.StatusCode)
+
+	res = postCredentials(t, srv.URL+"/auth/register", "alice", "another password")
+	assert.Equal(t, http.StatusConflict, res.StatusCode)
+	res = postCredentials(t, srv.URL+"/auth/register", "bob", "short")
+	assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+	res = postCredentials(t, srv.URL+"/auth/register", "no/slashes", testPassword)
+	assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+
+	res = postCredentials(t, srv.URL+"/auth/login", "alice", testPassword)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	res = postCredentials(t, srv.URL+"/auth/login", "alice", "wrong password")
+	assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
+	res = postCredentials(t, srv.URL+"/auth/login", "mallory", testPassword)
+	assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
+}
+
+func TestLoginLockout(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret-32-chars-long-for-testing-only")
+
+	users := auth.NewService(auth.NewInMemoryCredentialStore(),
+		auth.WithBcryptCost(bcrypt.MinCost), auth.WithLockout(3, 200*time.Millisecond))
+	_, err := users.Register("alice", testPassword)
+	require.NoError(t, err)
+
+	h := api.NewAuthHandler(users, auth.NewSessions(auth.NewInMemoryRefreshStore(), 0))
+	mux := http.NewServeMux()
+	mux.HandleFunc("/auth/login", h.Login)
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	// A success in between resets the counter.
+	for i := 0; i < 2; i++ {
+		res := postCredentials(t, srv.URL+"/auth/login", "alice", "wrong password")
+		assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
+	}
+	res := postCredentials(t, srv.URL+"/auth/login", "alice", testPassword)
+	require.Equal(t, http.StatusOK, res.StatusCode)
+
+	for i := 0; i < 3; i++ {
+		res := postCredentials(t, srv.URL+"/auth/login", "alice", "wrong password")
+		assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
+	}
+	res = postCredentials(t, srv.URL+"/auth/login", "alice", testPassword)
+	assert.Equal(t, http.StatusLocked, res.StatusCode)
+	assert.NotEmpty(t, res.Header.Get("Retry-After"))
+
+	_, err = users.Login("alice", testPassword)
+	var locked *auth.LockedError
+	assert.True(t, errors.As(err, &locked))
+
+	time.Sleep(250 * time.Millisecond)
+	res = postCredentials(t, srv.URL+"/auth/login", "alice", testPassword)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+}
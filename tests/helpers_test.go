@@ -0,0 +1,50 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/Zisimopoulou/platform-go-challenge/internal/api"
+	"github.com/Zisimopoulou/platform-go-challenge/internal/auth"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const testPassword = "correct horse battery"
+
+// mountAuth serves the /auth endpoints from fresh in-memory stores, hashing
+// with the cheapest bcrypt cost to keep tests fast.
+func mountAuth(mux *http.ServeMux) {
+	h := api.NewAuthHandler(
+		auth.NewService(auth.NewInMemoryCredentialStore(), auth.WithBcryptCost(bcrypt.MinCost)),
+		auth.NewSessions(auth.NewInMemoryRefreshStore(), 0),
+	)
+	mux.HandleFunc("/auth/register", h.Register)
+	mux.HandleFunc("/auth/login", h.Login)
+	mux.HandleFunc("/auth/refresh", h.Refresh)
+	mux.HandleFunc("/auth/logout", h.Logout)
+}
+
+func postCredentials(t *testing.T, url, username, password string) *http.Response {
+	t.Helper()
+	body, _ := json.Marshal(api.Credentials{Username: username, Password: password})
+	res, err := http.Post(url, "application/json", bytes.NewReader(body))
+	require.NoError(t, err)
+	t.Cleanup(func() { res.Body.Close() })
+	return res
+}
+
+// loginAs registers user with testPassword and returns its tokens.
+func loginAs(t *testing.T, baseURL, user string) api.TokenResponse {
+	t.Helper()
+	res := postCredentials(t, baseURL+"/auth/register", user, testPassword)
+	require.Equal(t, http.StatusCreated, res.StatusCode)
+
+	res = postCredentials(t, baseURL+"/auth/login", user, testPassword)
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	var tokens api.TokenResponse
+	require.NoError(t, json.NewDecoder(res.Body).Decode(&tokens))
+	return tokens
+}
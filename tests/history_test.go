@@ -0,0 +1,284 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Zisimopoulou/platform-go-challenge/internal/api"
+	"github.com/Zisimopoulou/platform-go-challenge/internal/core"
+	"github.com/Zisimopoulou/platform-go-challenge/internal/data"
+	"github.com/Zisimopoulou/platform-go-challenge/internal/models"
+	"github.com/Zisimopoulou/platform-go-challenge/internal/patch"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func revisionActions(revs []models.Revision) []models.RevisionAction {
+	out := make([]models.RevisionAction, 0, len(revs))
+	for _, rev := range revs {
+		out = append(out, rev.Action)
+	}
+	return out
+}
+
+func TestStoreHistory(t *testing.T) {
+	for name, newStore := range storeImplementations() {
+		t.Run(name, func(t *testing.T) {
+			store := newStore(t)
+			id, err := store.Add("alice", taggedAsset("first", "draft"))
+			require.NoError(t, err)
+			_, err = store.UpdateDescription("alice", "alice", id, "second", data.AnyVersion)
+			require.NoError(t, err)
+			asset, err := store.Get("alice", id)
+			require.NoError(t, err)
+			asset.Tags = []string{"final"}
+			asset.Payload = models.Insight{Text: "edited"}
+			asset.UpdatedBy = "bob"
+			asset, err = store.Update("alice", asset, data.AnyVersion)
+			require.NoError(t, err)
+			assert.Equal(t, "bob", asset.UpdatedBy)
+			require.NoError(t, store.Delete("alice", id, data.AnyVersion))
+			_, err = store.Restore("alice", id)
+			require.NoError(t, err)
+
+			revs, total, err := store.History("alice", id, 10, 0)
+			require.NoError(t, err)
+			assert.Equal(t, 5, total)
+			assert.Equal(t, []models.RevisionAction{
+				models.RevisionRestored, models.RevisionDeleted, models.RevisionUpdated, models.RevisionUpdated, models.RevisionCreated,
+			}, revisionActions(revs))
+			assert.Equal(t, int64(5), revs[0].Version)
+			assert.Equal(t, "bob", revs[2].Author)
+			assert.Equal(t, []string{"tags", "payload"}, revs[2].Changes)
+			assert.Equal(t, "alice", revs[3].Author)
+			assert.Equal(t, []string{"description"}, revs[3].Changes)
+			assert.Empty(t, revs[4].Changes)
+			assert.NotNil(t, revs[1].Asset.DeletedAt)
+
+			page, total, err := store.History("alice", id, 2, 3)
+			require.NoError(t, err)
+			assert.Equal(t, 5, total)
+			assert.Equal(t, []models.RevisionAction{models.RevisionUpdated, models.RevisionCreated}, revisionActions(page))
+
+			first, err := store.Revision("alice", id, 1)
+			require.NoError(t, err)
+			assert.Equal(t, "first", first.Asset.Description)
+			assert.Equal(t, []string{"draft"}, first.Asset.Tags)
+			_, err = store.Revision("alice", id, 42)
+			assert.ErrorIs(t, err, data.ErrNotFound)
+			_, _, err = store.History("alice", "missing", 10, 0)
+			assert.ErrorIs(t, err, data.ErrNotFound)
+			_, _, err = store.History("bob", id, 10, 0)
+			assert.ErrorIs(t, err, data.ErrNotFound)
+
+			_, err = store.Revert("alice", id, 1, 4)
+			assert.ErrorIs(t, err, data.ErrVersionMismatch)
+			_, err = store.Revert("alice", id, 42, data.AnyVersion)
+			assert.ErrorIs(t, err, data.ErrNotFound)
+			reverted, err := store.Revert("alice", id, 1, 5)
+			require.NoError(t, err)
+			assert.Equal(t, int64(6), reverted.Version)
+			assert.Equal(t, "first", reverted.Description)
+			assert.Equal(t, []string{"draft"}, reverted.Tags)
+			assert.Equal(t, models.Insight{Text: "first"}, reverted.Payload)
+			got, err := store.Get("alice", id)
+			require.NoError(t, err)
+			assert.Equal(t, "first", got.Description)
+			tags, err := store.Tags("alice")
+			require.NoError(t, err)
+			assert.Equal(t, []models.TagCount{{Tag: "draft", Count: 1}}, tags)
+
+			latest, err := store.Revision("alice", id, 6)
+			require.NoError(t, err)
+			assert.Equal(t, models.RevisionReverted, latest.Action)
+			assert.Equal(t, int64(1), latest.RevertedTo)
+			assert.Equal(t, []string{"description", "tags", "payload"}, latest.Changes)
+
+			require.NoError(t, store.Delete("alice", id, data.AnyVersion))
+			_, err = store.Revert("alice", id, 1, data.AnyVersion)
+			assert.ErrorIs(t, err, data.ErrNotFound, "trashed favorites cannot be reverted")
+			_, total, err = store.History("alice", id, 10, 0)
+			require.NoError(t, err)
+			assert.Equal(t, 7, total)
+			require.NoError(t, store.Purge("alice", id))
+			_, _, err = store.History("alice", id, 10, 0)
+			assert.ErrorIs(t, err, data.ErrNotFound)
+		})
+	}
+}
+
+func TestStoreHistoryKeepsLatestRevisions(t *testing.T) {
+	for name, newStore := range storeImplementations() {
+		t.Run(name, func(t *testing.T) {
+			store := newStore(t)
+			id, err := store.Add("alice", insightAsset("v"))
+			require.NoError(t, err)
+			for i := 0; i < data.MaxRevisions+4; i++ {
+				_, err := store.UpdateDescription("alice", "alice", id, "v", data.AnyVersion)
+				require.NoError(t, err)
+			}
+			revs, total, err := store.History("alice", id, 1000, 0)
+			require.NoError(t, err)
+			assert.Equal(t, data.MaxRevisions, total)
+			require.Len(t, revs, data.MaxRevisions)
+			assert.Equal(t, int64(data.MaxRevisions+5), revs[0].Version)
+			assert.Equal(t, int64(6), revs[len(revs)-1].Version)
+			_, err = store.Revision("alice", id, 5)
+			assert.ErrorIs(t, err, data.ErrNotFound)
+		})
+	}
+}
+
+func TestAbortedBatchLeavesNoRevisions(t *testing.T) {
+	for name, newStore := range storeImplementations() {
+		t.Run(name, func(t *testing.T) {
+			store := newStore(t)
+			id, err := store.Add("alice", insightAsset("kept"))
+			require.NoError(t, err)
+			other, err := store.Add("alice", insightAsset("other"))
+			require.NoError(t, err)
+
+			results, err := store.Batch("alice", []data.BatchOp{
+				{Kind: data.BatchUpdate, Asset: models.RawAsset{ID: id, Type: models.TypeInsight, Description: "changed", Payload: models.Insight{Text: "x"}}},
+				{Kind: data.BatchDelete, Asset: models.RawAsset{ID: other}},
+				{Kind: data.BatchDelete, Asset: models.RawAsset{ID: "missing"}},
+			}, true)
+			require.NoError(t, err)
+			assert.ErrorIs(t, results[2].Err, data.ErrNotFound)
+
+			for _, favID := range []string{id, other} {
+				revs, total, err := store.History("alice", favID, 10, 0)
+				require.NoError(t, err)
+				assert.Equal(t, 1, total)
+				assert.Equal(t, []models.RevisionAction{models.RevisionCreated}, revisionActions(revs))
+			}
+		})
+	}
+}
+
+func TestJournalReplaysHistory(t *testing.T) {
+	dir := t.TempDir()
+	store := openJournaled(t, dir, 0)
+	id, err := store.Add("alice", insightAsset("first"))
+	require.NoError(t, err)
+	_, err = store.UpdateDescription("alice", "alice", id, "second", data.AnyVersion)
+	require.NoError(t, err)
+	_, err = store.Revert("alice", id, 1, data.AnyVersion)
+	require.NoError(t, err)
+
+	check := func(s data.Store) {
+		revs, total, err := s.History("alice", id, 10, 0)
+		require.NoError(t, err)
+		assert.Equal(t, 3, total)
+		assert.Equal(t, []models.RevisionAction{models.RevisionReverted, models.RevisionUpdated, models.RevisionCreated}, revisionActions(revs))
+		assert.Equal(t, int64(1), revs[0].RevertedTo)
+		assert.Equal(t, []string{"description"}, revs[0].Changes)
+	}
+	fromJournal := openJournaled(t, dir, 0)
+	check(fromJournal)
+	require.NoError(t, fromJournal.Close())
+
+	fromSnapshot := openJournaled(t, dir, 0)
+	defer fromSnapshot.Close()
+	check(fromSnapshot)
+}
+
+func TestJSONPatchDiff(t *testing.T) {
+	a := []byte(`{"description":"old","tags":["a"],"payload":{"text":"x","n":1,"a/b":true}}`)
+	b := []byte(`{"description":"new","tags":["a","b"],"payload":{"text":"x","n":1.0,"extra":null}}`)
+	ops, err := patch.Diff(a, b)
+	require.NoError(t, err)
+	got, _ := json.Marshal(ops)
+	assert.JSONEq(t, `[
+		{"op":"replace","path":"/description","value":"new"},
+		{"op":"remove","path":"/payload/a~1b"},
+		{"op":"add","path":"/payload/extra","value":null},
+		{"op":"replace","path":"/tags","value":["a","b"]}
+	]`, string(got))
+
+	patched, err := patch.ApplyJSONPatch(a, got)
+	require.NoError(t, err)
+	assert.JSONEq(t, string(b), string(patched))
+
+	ops, err = patch.Diff(a, a)
+	require.NoError(t, err)
+	assert.Empty(t, ops)
+}
+
+func TestHistoryEndpoints(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret-32-chars-long-for-testing-only")
+
+	svc := core.NewService(data.NewInMemoryStore())
+	mux := http.NewServeMux()
+	mux.Handle("/users/", http.StripPrefix("/users", api.NewHandler(svc)))
+	mountAuth(mux)
+	srv := httptest.NewServer(api.WithMiddleware(mux))
+	defer srv.Close()
+
+	alice := loginAs(t, srv.URL, "alice").AccessToken
+	bob := loginAs(t, srv.URL, "bob").AccessToken
+	do := func(token, method, path, body string, header ...string) *http.Response {
+		req, _ := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Content-Type", "application/json")
+		for i := 0; i+1 < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		res, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		t.Cleanup(func() { res.Body.Close() })
+		return res
+	}
+
+	res := do(alice, http.MethodPost, "/users/alice/favorites", `{"type":"insight","description":"original","payload":{"text":"t"}}`)
+	require.Equal(t, http.StatusCreated, res.StatusCode)
+	var created map[string]string
+	require.NoError(t, json.NewDecoder(res.Body).Decode(&created))
+	favPath := "/users/alice/favorites/" + created["favoriteId"]
+
+	require.Equal(t, http.StatusCreated, do(alice, http.MethodPut, favPath+"/shares/bob", `{"permission":"edit"}`).StatusCode)
+	require.Equal(t, http.StatusNoContent, do(bob, http.MethodPut, favPath, `{"description":"overwritten"}`).StatusCode)
+
+	res = do(alice, http.MethodGet, favPath+"/history", "")
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	var history models.PaginatedRevisions
+	require.NoError(t, json.NewDecoder(res.Body).Decode(&history))
+	assert.Equal(t, 2, history.TotalCount)
+	require.Len(t, history.Revisions, 2)
+	assert.Equal(t, "bob", history.Revisions[0].Author)
+	assert.Equal(t, []string{"description"}, history.Revisions[0].Changes)
+	assert.Equal(t, "alice", history.Revisions[1].Author)
+	assert.Equal(t, http.StatusForbidden, do(bob, http.MethodGet, favPath+"/history", "").StatusCode)
+
+	res = do(alice, http.MethodGet, favPath+"/history/1", "")
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	var rev models.Revision
+	require.NoError(t, json.NewDecoder(res.Body).Decode(&rev))
+	assert.Equal(t, "original", rev.Asset.Description)
+	assert.Equal(t, http.StatusNotFound, do(alice, http.MethodGet, favPath+"/history/9", "").StatusCode)
+	assert.Equal(t, http.StatusBadRequest, do(alice, http.MethodGet, favPath+"/history/latest", "").StatusCode)
+
+	res = do(alice, http.MethodGet, favPath+"/diff?from=1", "")
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	var diff core.RevisionDiff
+	require.NoError(t, json.NewDecoder(res.Body).Decode(&diff))
+	assert.Equal(t, int64(2), diff.To)
+	require.Len(t, diff.Changes, 1)
+	assert.Equal(t, "/description", diff.Changes[0].Path)
+	assert.JSONEq(t, `"overwritten"`, string(diff.Changes[0].Value))
+	assert.Equal(t, http.StatusBadRequest, do(alice, http.MethodGet, favPath+"/diff", "").StatusCode)
+	assert.Equal(t, http.StatusNotFound, do(alice, http.MethodGet, favPath+"/diff?from=1&to=9", "").StatusCode)
+
+	assert.Equal(t, http.StatusPreconditionFailed, do(alice, http.MethodPost, favPath+"/history/1/revert", "", "If-Match", `"1"`).StatusCode)
+	assert.Equal(t, http.StatusNotFound, do(alice, http.MethodPost, favPath+"/history/9/revert", "").StatusCode)
+	res = do(alice, http.MethodPost, favPath+"/history/1/revert", "", "If-Match", `"2"`)
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, `"3"`, res.Header.Get("ETag"))
+	var fav models.Favorite
+	require.NoError(t, json.NewDecoder(res.Body).Decode(&fav))
+	assert.Equal(t, "original", fav.Asset.Description)
+	assert.Equal(t, "alice", fav.Asset.UpdatedBy)
+}
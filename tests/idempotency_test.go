@@ -0,0 +1,103 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Zisimopoulou/platform-go-challenge/internal/api"
+	"github.com/Zisimopoulou/platform-go-challenge/internal/core"
+	"github.com/Zisimopoulou/platform-go-challenge/internal/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIdempotentAdd(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret-32-chars-long-for-testing-only")
+
+	store := data.NewInMemoryStore()
+	mux := http.NewServeMux()
+	mux.Handle("/users/", http.StripPrefix("/users", api.NewHandler(core.NewService(store, core.WithIdempotencyWindow(200*time.Millisecond)))))
+	mountAuth(mux)
+	srv := httptest.NewServer(api.WithMiddleware(mux))
+	defer srv.Close()
+
+	user := "retryuser"
+	tokenResp := loginAs(t, srv.URL, user)
+
+	add := func(key, text string) (*http.Response, string) {
+		body, _ := json.Marshal(insightAsset(text))
+		req, _ := http.NewRequest(http.MethodPost, srv.URL+"/users/"+user+"/favorites", bytes.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+tokenResp.AccessToken)
+		req.Header.Set("Content-Type", "application/json")
+		if key != "" {
+			req.Header.Set("Idempotency-Key", key)
+		}
+		res, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer res.Body.Close()
+		var out map[string]string
+		json.NewDecoder(res.Body).Decode(&out)
+		return res, out["favoriteId"]
+	}
+
+	res, first := add("key-1", "hello")
+	require.Equal(t, http.StatusCreated, res.StatusCode)
+	assert.Empty(t, res.Header.Get("Idempotent-Replayed"))
+
+	res, replay := add("key-1", "hello")
+	require.Equal(t, http.StatusCreated, res.StatusCode)
+	assert.Equal(t, "true", res.Header.Get("Idempotent-Replayed"))
+	assert.Equal(t, first, replay)
+
+	res, _ = add("key-1", "a different body")
+	assert.Equal(t, http.StatusUnprocessableEntity, res.StatusCode)
+
+	res, other := add("key-2", "hello")
+	require.Equal(t, http.StatusCreated, res.StatusCode)
+	assert.NotEqual(t, first, other)
+
+	_, total, err := store.List(user, data.ListQuery{Limit: 10})
+	require.NoError(t, err)
+	assert.Equal(t, 2, total)
+
+	time.Sleep(300 * time.Millisecond)
+	res, expired := add("key-1", "hello")
+	require.Equal(t, http.StatusCreated, res.StatusCode)
+	assert.Empty(t, res.Header.Get("Idempotent-Replayed"))
+	assert.NotEqual(t, first, expired)
+}
+
+func TestIdempotentAddConcurrentRetries(t *testing.T) {
+	store := data.NewInMemoryStore()
+	svc := core.NewService(store)
+
+	var wg sync.WaitGroup
+	ids := make([]string, 20)
+	for i := range ids {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			id, _, err := svc.AddFavoriteIdempotent("alice", "same-key", insightAsset("once"))
+			assert.NoError(t, err)
+			ids[i] = id
+		}(i)
+	}
+	wg.Wait()
+
+	for _, id := range ids {
+		assert.Equal(t, ids[0], id)
+	}
+	_, total, err := store.List("alice", data.ListQuery{Limit: 10})
+	require.NoError(t, err)
+	assert.Equal(t, 1, total)
+
+	id, replayed, err := svc.AddFavoriteIdempotent("bob", "same-key", insightAsset("once"))
+	require.NoError(t, err)
+	assert.False(t, replayed, "keys are scoped per user")
+	assert.NotEqual(t, ids[0], id)
+}
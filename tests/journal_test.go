@@ -0,0 +1,176 @@
+package tests
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Zisimopoulou/platform-go-challenge/internal/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func openJournaled(t *testing.T, dir string, snapshotEvery int) *data.InMemoryStore {
+	store, err := data.NewJournaledInMemoryStore(data.JournalConfig{Dir: dir, SnapshotEvery: snapshotEvery})
+	require.NoError(t, err)
+	return store
+}
+
+func TestJournalReplayAfterCrash(t *testing.T) {
+	dir := t.TempDir()
+	// The first store is never closed: Close would take a final snapshot,
+	// and this test wants the state to come back from the journal alone.
+	store := openJournaled(t, dir, 0)
+
+	kept, err := store.Add("alice", insightAsset("kept"))
+	require.NoError(t, err)
+	removed, err := store.Add("alice", insightAsset("removed"))
+	require.NoError(t, err)
+	_, err = store.UpdateDescription("alice", "alice", kept, "kept and updated", data.AnyVersion)
+	require.NoError(t, err)
+	require.NoError(t, store.Delete("alice", removed, data.AnyVersion))
+
+	_, err = os.Stat(filepath.Join(dir, "snapshot.json"))
+	assert.True(t, os.IsNotExist(err), "no snapshot expected before compaction")
+
+	reopened := openJournaled(t, dir, 0)
+	defer reopened.Close()
+
+	favs, total, err := reopened.List("alice", data.ListQuery{Limit: 10})
+	require.NoError(t, err)
+	assert.Equal(t, 1, total)
+	require.Len(t, favs, 1)
+	assert.Equal(t, kept, favs[0].FavoriteID)
+	assert.Equal(t, "kept and updated", favs[0].Asset.Description)
+
+	next, err := reopened.Add("alice", insightAsset("next"))
+	require.NoError(t, err)
+	assert.NotEqual(t, removed, next, "counters must survive replay")
+}
+
+func TestJournalSnapshotCompaction(t *testing.T) {
+	dir := t.TempDir()
+	store := openJournaled(t, dir, 3)
+
+	for i := 0; i < 4; i++ {
+		_, err := store.Add("alice", insightAsset("item"))
+		require.NoError(t, err)
+	}
+
+	_, err := os.Stat(filepath.Join(dir, "snapshot.json"))
+	require.NoError(t, err, "snapshot expected after 3 records")
+	info, err := os.Stat(filepath.Join(dir, "journal.log"))
+	require.NoError(t, err)
+	journalAfterOne := info.Size()
+	assert.Greater(t, journalAfterOne, int64(0))
+
+	require.NoError(t, store.Snapshot())
+	info, err = os.Stat(filepath.Join(dir, "journal.log"))
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), info.Size())
+
+	_, err = store.Add("alice", insightAsset("after snapshot"))
+	require.NoError(t, err)
+
+	reopened := openJournaled(t, dir, 3)
+	defer reopened.Close()
+	_, total, err := reopened.List("alice", data.ListQuery{Limit: 10})
+	require.NoError(t, err)
+	assert.Equal(t, 5, total)
+}
+
+func TestJournalTruncatedTrailingRecord(t *testing.T) {
+	dir := t.TempDir()
+	store := openJournaled(t, dir, 0)
+	_, err := store.Add("alice", insightAsset("first"))
+	require.NoError(t, err)
+	_, err = store.Add("alice", insightAsset("second"))
+	require.NoError(t, err)
+
+	path := filepath.Join(dir, "journal.log")
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	require.NoError(t, os.Truncate(path, info.Size()-5))
+
+	reopened := openJournaled(t, dir, 0)
+	defer reopened.Close()
+
+	favs, total, err := reopened.List("alice", data.ListQuery{Limit: 10})
+	require.NoError(t, err)
+	assert.Equal(t, 1, total)
+	require.Len(t, favs, 1)
+	assert.Equal(t, "first", favs[0].Asset.Description)
+
+	_, err = reopened.Add("alice", insightAsset("third"))
+	require.NoError(t, err)
+	_, total, err = reopened.List("alice", data.ListQuery{Limit: 10})
+	require.NoError(t, err)
+	assert.Equal(t, 2, total)
+}
+
+func TestJournalCorruptionInTheMiddle(t *testing.T) {
+	dir := t.TempDir()
+	store := openJournaled(t, dir, 0)
+	_, err := store.Add("alice", insightAsset("first"))
+	require.NoError(t, err)
+	_, err = store.Add("alice", insightAsset("second"))
+	require.NoError(t, err)
+
+	path := filepath.Join(dir, "journal.log")
+	raw, err := os.ReadFile(path)
+	require.NoError(t, err)
+	raw[12] ^= 0xff
+	require.NoError(t, os.WriteFile(path, raw, 0o644))
+
+	_, err = data.NewJournaledInMemoryStore(data.JournalConfig{Dir: dir})
+	assert.ErrorIs(t, err, data.ErrJournalCorrupt)
+}
+
+func TestJournalBadLengthInTheMiddle(t *testing.T) {
+	for name, length := range map[string]uint32{"oversized": 1 << 30, "past the end": 1 << 20} {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			store := openJournaled(t, dir, 0)
+			_, err := store.Add("alice", insightAsset("first"))
+			require.NoError(t, err)
+			_, err = store.Add("alice", insightAsset("second"))
+			require.NoError(t, err)
+
+			path := filepath.Join(dir, "journal.log")
+			raw, err := os.ReadFile(path)
+			require.NoError(t, err)
+			binary.LittleEndian.PutUint32(raw[0:4], length)
+			require.NoError(t, os.WriteFile(path, raw, 0o644))
+
+			_, err = data.NewJournaledInMemoryStore(data.JournalConfig{Dir: dir})
+			assert.ErrorIs(t, err, data.ErrJournalCorrupt)
+			info, err := os.Stat(path)
+			require.NoError(t, err)
+			assert.Equal(t, int64(len(raw)), info.Size(), "valid records must not be discarded")
+		})
+	}
+}
+
+func TestJournalBadLengthAtTheTail(t *testing.T) {
+	dir := t.TempDir()
+	store := openJournaled(t, dir, 0)
+	_, err := store.Add("alice", insightAsset("first"))
+	require.NoError(t, err)
+	path := filepath.Join(dir, "journal.log")
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	_, err = store.Add("alice", insightAsset("second"))
+	require.NoError(t, err)
+
+	raw, err := os.ReadFile(path)
+	require.NoError(t, err)
+	binary.LittleEndian.PutUint32(raw[info.Size():], 1<<30)
+	require.NoError(t, os.WriteFile(path, raw, 0o644))
+
+	reopened := openJournaled(t, dir, 0)
+	defer reopened.Close()
+	_, total, err := reopened.List("alice", data.ListQuery{Limit: 10})
+	require.NoError(t, err)
+	assert.Equal(t, 1, total)
+}
@@ -0,0 +1,170 @@
+package tests
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Zisimopoulou/platform-go-challenge/internal/api"
+	"github.com/Zisimopoulou/platform-go-challenge/internal/auth"
+	"github.com/Zisimopoulou/platform-go-challenge/internal/core"
+	"github.com/Zisimopoulou/platform-go-challenge/internal/data"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func writePEM(t *testing.T, blockType string, der []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), strings.ReplaceAll(strings.ToLower(blockType), " ", "-")+".pem")
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600))
+	return path
+}
+
+// keyedServer serves favorites and /auth with ks, sharing one credential
+// store across servers so a user can log in to either.
+func keyedServer(t *testing.T, ks *auth.KeySet, users *auth.Service) *httptest.Server {
+	h := api.NewAuthHandler(users, auth.NewSessions(auth.NewInMemoryRefreshStore(), 0), api.WithKeySet(ks))
+	mux := http.NewServeMux()
+	mux.Handle("/users/", http.StripPrefix("/users", api.NewHandler(core.NewService(data.NewInMemoryStore()), api.WithKeySet(ks))))
+	mux.HandleFunc("/auth/login", h.Login)
+	mux.HandleFunc("/.well-known/jwks.json", api.JWKSHandler(ks))
+	srv := httptest.NewServer(api.WithMiddleware(mux))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestAsymmetricSigningAndRotation(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	rsaPrivatePath := writePEM(t, "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(rsaKey))
+	rsaPublicDER, err := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	require.NoError(t, err)
+	rsaPublicPath := writePEM(t, "PUBLIC KEY", rsaPublicDER)
+
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	edDER, err := x509.MarshalPKCS8PrivateKey(edKey)
+	require.NoError(t, err)
+	edPath := writePEM(t, "PRIVATE KEY", edDER)
+
+	users := auth.NewService(auth.NewInMemoryCredentialStore(), auth.WithBcryptCost(bcrypt.MinCost))
+	_, err = users.Register("alice", testPassword)
+	require.NoError(t, err)
+
+	get := func(srv *httptest.Server, token string) int {
+		req, _ := http.NewRequest(http.MethodGet, srv.URL+"/users/alice/favorites", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		res, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		res.Body.Close()
+		return res.StatusCode
+	}
+	login := func(srv *httptest.Server) string {
+		res := postCredentials(t, srv.URL+"/auth/login", "alice", testPassword)
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		var tokens api.TokenResponse
+		require.NoError(t, json.NewDecoder(res.Body).Decode(&tokens))
+		return tokens.AccessToken
+	}
+	jwks := func(srv *httptest.Server) auth.JWKS {
+		res, err := http.Get(srv.URL + "/.well-known/jwks.json")
+		require.NoError(t, err)
+		defer res.Body.Close()
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		var set auth.JWKS
+		require.NoError(t, json.NewDecoder(res.Body).Decode(&set))
+		return set
+	}
+
+	rsaSigning, err := auth.LoadKeyFile(rsaPrivatePath, "")
+	require.NoError(t, err)
+	before, err := auth.NewKeySet(rsaSigning)
+	require.NoError(t, err)
+	oldServer := keyedServer(t, before, users)
+
+	oldToken := login(oldServer)
+	assert.Equal(t, http.StatusOK, get(oldServer, oldToken))
+
+	set := jwks(oldServer)
+	require.Len(t, set.Keys, 1)
+	published := set.Keys[0]
+	assert.Equal(t, "RSA", published.KeyType)
+	assert.Equal(t, "RS256", published.Algorithm)
+	assert.Equal(t, rsaSigning.ID, published.KeyID)
+
+	// Another service can verify our tokens from the JWKS alone.
+	n, err := base64.RawURLEncoding.DecodeString(published.N)
+	require.NoError(t, err)
+	e, err := base64.RawURLEncoding.DecodeString(published.E)
+	require.NoError(t, err)
+	pub := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+	parsed, err := jwt.Parse(oldToken, func(tok *jwt.Token) (interface{}, error) {
+		assert.Equal(t, published.KeyID, tok.Header["kid"])
+		return pub, nil
+	}, jwt.WithValidMethods([]string{"RS256"}))
+	require.NoError(t, err)
+	assert.True(t, parsed.Valid)
+
+	// Rotate: sign with Ed25519, keep verifying with the old RSA public key.
+	edSigning, err := auth.LoadKeyFile(edPath, "2026-10")
+	require.NoError(t, err)
+	rsaVerify, err := auth.LoadKeyFile(rsaPublicPath, "")
+	require.NoError(t, err)
+	assert.Equal(t, rsaSigning.ID, rsaVerify.ID, "thumbprint kid is stable across private and public PEMs")
+	assert.False(t, rsaVerify.CanSign())
+	rotated, err := auth.NewKeySet(edSigning, rsaVerify)
+	require.NoError(t, err)
+	newServer := keyedServer(t, rotated, users)
+
+	assert.Equal(t, http.StatusOK, get(newServer, oldToken), "tokens from before the rotation stay valid")
+	newToken := login(newServer)
+	assert.Equal(t, http.StatusOK, get(newServer, newToken))
+	header, err := base64.RawURLEncoding.DecodeString(strings.Split(newToken, ".")[0])
+	require.NoError(t, err)
+	assert.Contains(t, string(header), `"kid":"2026-10"`)
+	assert.Contains(t, string(header), `"alg":"EdDSA"`)
+
+	set = jwks(newServer)
+	require.Len(t, set.Keys, 2)
+	assert.Equal(t, "2026-10", set.Keys[0].KeyID)
+	assert.Equal(t, "OKP", set.Keys[0].KeyType)
+	assert.Equal(t, "Ed25519", set.Keys[0].Curve)
+	assert.Equal(t, rsaSigning.ID, set.Keys[1].KeyID)
+
+	// Once the old key is dropped, its tokens are rejected.
+	retired, err := auth.NewKeySet(edSigning)
+	require.NoError(t, err)
+	retiredServer := keyedServer(t, retired, users)
+	assert.Equal(t, http.StatusUnauthorized, get(retiredServer, oldToken))
+	assert.Equal(t, http.StatusOK, get(retiredServer, newToken))
+
+	// A token naming the RSA key but using HS256 must not be accepted.
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"sub": "alice", "type": "access", "exp": 4102444800})
+	forged.Header["kid"] = rsaSigning.ID
+	forgedString, err := forged.SignedString([]byte(published.N))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, get(newServer, forgedString))
+}
+
+func TestParsePEMKeyRejectsWeakRSA(t *testing.T) {
+	weak, err := rsa.GenerateKey(rand.Reader, 1024)
+	require.NoError(t, err)
+	_, err = auth.ParsePEMKey(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(weak)}), "")
+	assert.Error(t, err)
+
+	_, err = auth.ParsePEMKey([]byte("not pem"), "")
+	assert.Error(t, err)
+}
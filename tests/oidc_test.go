@@ -0,0 +1,162 @@
+package tests
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Zisimopoulou/platform-go-challenge/internal/api"
+	"github.com/Zisimopoulou/platform-go-challenge/internal/auth"
+	"github.com/Zisimopoulou/platform-go-challenge/internal/core"
+	"github.com/Zisimopoulou/platform-go-challenge/internal/data"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stubIdP publishes a discovery document and the JWKS of its current keys.
+type stubIdP struct {
+	*httptest.Server
+	mu   sync.Mutex
+	keys *auth.KeySet
+}
+
+func newStubIdP(t *testing.T) *stubIdP {
+	idp := &stubIdP{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{"issuer": idp.URL, "jwks_uri": idp.URL + "/keys"})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		idp.mu.Lock()
+		defer idp.mu.Unlock()
+		json.NewEncoder(w).Encode(idp.keys.JWKS())
+	})
+	idp.Server = httptest.NewServer(mux)
+	t.Cleanup(idp.Close)
+	idp.rotate(t, "idp-1")
+	return idp
+}
+
+func (idp *stubIdP) rotate(t *testing.T, kid string) {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	k, err := auth.NewKey(priv, kid)
+	require.NoError(t, err)
+	ks, err := auth.NewKeySet(k)
+	require.NoError(t, err)
+	idp.mu.Lock()
+	idp.keys = ks
+	idp.mu.Unlock()
+}
+
+func (idp *stubIdP) token(t *testing.T, claims jwt.MapClaims) string {
+	base := jwt.MapClaims{
+		"iss":                idp.URL,
+		"aud":                "favorites-api",
+		"sub":                "00u1a2b3c",
+		"preferred_username": "alice",
+		"iat":                time.Now().Unix(),
+		"exp":                time.Now().Add(time.Hour).Unix(),
+	}
+	for k, v := range claims {
+		if v == nil {
+			delete(base, k)
+		} else {
+			base[k] = v
+		}
+	}
+	idp.mu.Lock()
+	defer idp.mu.Unlock()
+	s, err := idp.keys.Sign(base)
+	require.NoError(t, err)
+	return s
+}
+
+func TestExternalIssuer(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret-32-chars-long-for-testing-only")
+	idp := newStubIdP(t)
+
+	verifier, err := auth.NewVerifier(auth.IssuerConfig{
+		DiscoveryURL:       idp.URL + "/.well-known/openid-configuration",
+		Audience:           "favorites-api",
+		UserClaim:          "preferred_username",
+		MinRefreshInterval: time.Millisecond,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, idp.URL, verifier.Issuer())
+
+	mux := http.NewServeMux()
+	mux.Handle("/users/", http.StripPrefix("/users", api.NewHandler(core.NewService(data.NewInMemoryStore()), api.WithExternalIssuer(verifier))))
+	mountAuth(mux)
+	srv := httptest.NewServer(api.WithMiddleware(mux))
+	defer srv.Close()
+
+	get := func(user, token string) int {
+		req, _ := http.NewRequest(http.MethodGet, srv.URL+"/users/"+user+"/favorites", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		res, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		res.Body.Close()
+		return res.StatusCode
+	}
+
+	valid := idp.token(t, nil)
+	assert.Equal(t, http.StatusOK, get("alice", valid), "user ID comes from the configured claim")
+	assert.Equal(t, http.StatusForbidden, get("00u1a2b3c", valid))
+
+	for name, claims := range map[string]jwt.MapClaims{
+		"wrong audience": {"aud": "someone-else"},
+		"expired":        {"exp": time.Now().Add(-time.Hour).Unix()},
+		"no expiry":      {"exp": nil},
+		"not yet valid":  {"nbf": time.Now().Add(time.Hour).Unix()},
+		"missing claim":  {"preferred_username": nil},
+	} {
+		assert.Equal(t, http.StatusUnauthorized, get("alice", idp.token(t, claims)), name)
+	}
+
+	// A token naming another issuer is not handed to the verifier at all,
+	// and the local key set cannot verify it either.
+	assert.Equal(t, http.StatusUnauthorized, get("alice", idp.token(t, jwt.MapClaims{"iss": "https://evil.example"})))
+
+	// The IdP rotates its key; the verifier refetches on the unknown kid.
+	idp.rotate(t, "idp-2")
+	assert.Equal(t, http.StatusOK, get("alice", idp.token(t, nil)))
+	assert.Equal(t, http.StatusUnauthorized, get("alice", valid), "the retired key is gone from the JWKS")
+
+	// Locally issued tokens keep working alongside the external issuer.
+	local := loginAs(t, srv.URL, "bob")
+	assert.Equal(t, http.StatusOK, get("bob", local.AccessToken))
+}
+
+func TestExternalIssuerFromJWKSFile(t *testing.T) {
+	idp := newStubIdP(t)
+	raw, err := json.Marshal(idp.keys.JWKS())
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	require.NoError(t, os.WriteFile(path, raw, 0o600))
+
+	_, err = auth.NewVerifier(auth.IssuerConfig{JWKSFile: path})
+	assert.Error(t, err, "the issuer cannot be discovered from a file")
+
+	verifier, err := auth.NewVerifier(auth.IssuerConfig{Issuer: idp.URL, JWKSFile: path})
+	require.NoError(t, err)
+
+	userID, err := verifier.Verify(idp.token(t, nil))
+	require.NoError(t, err)
+	assert.Equal(t, "00u1a2b3c", userID, "sub is the default user claim")
+
+	_, err = verifier.Verify(idp.token(t, jwt.MapClaims{"iss": "https://evil.example"}))
+	assert.Error(t, err)
+	assert.False(t, verifier.Issues("not-a-jwt"))
+
+	_, err = auth.NewVerifier(auth.IssuerConfig{DiscoveryURL: idp.URL + "/.well-known/openid-configuration", Issuer: "https://other.example"})
+	assert.Error(t, err, "discovery must agree with the configured issuer")
+}
@@ -24,7 +24,7 @@ func TestPagination(t *testing.T) {
 
 	mux := http.NewServeMux()
 	mux.Handle("/users/", http.StripPrefix("/users", api.NewHandler(svc)))
-	mux.HandleFunc("/auth/login", api.LoginHandler)
+	mountAuth(mux)
 
 	handler := api.WithMiddleware(mux)
 	srv := httptest.NewServer(handler)
@@ -32,14 +32,7 @@ func TestPagination(t *testing.T) {
 
 	user := "paginationuser"
 
-	loginReq := map[string]string{"userId": user}
-	loginBody, _ := json.Marshal(loginReq)
-	loginResp, err := http.Post(srv.URL+"/auth/login", "application/json", bytes.NewReader(loginBody))
-	require.NoError(t, err)
-	defer loginResp.Body.Close()
-
-	var tokenResp api.TokenResponse
-	json.NewDecoder(loginResp.Body).Decode(&tokenResp)
+	tokenResp := loginAs(t, srv.URL, user)
 	authHeader := "Bearer " + tokenResp.AccessToken
 
 	client := &http.Client{}
@@ -131,3 +124,172 @@ func TestPagination(t *testing.T) {
 		assert.Equal(t, 0, result.Offset)
 	})
 }
+
+func TestCursorPagination(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret-32-chars-long-for-testing-only")
+
+	store := data.NewInMemoryStore()
+	svc := core.NewService(store, core.WithCursorSecret([]byte("cursor-secret")))
+
+	mux := http.NewServeMux()
+	mux.Handle("/users/", http.StripPrefix("/users", api.NewHandler(svc)))
+	mountAuth(mux)
+
+	srv := httptest.NewServer(api.WithMiddleware(mux))
+	defer srv.Close()
+
+	user := "cursoruser"
+	tokenResp := loginAs(t, srv.URL, user)
+	authHeader := "Bearer " + tokenResp.AccessToken
+
+	client := &http.Client{}
+	add := func(text string) {
+		b, _ := json.Marshal(map[string]interface{}{
+			"type":        "insight",
+			"description": text,
+			"payload":     map[string]string{"text": text},
+		})
+		req, _ := http.NewRequest(http.MethodPost, srv.URL+"/users/"+user+"/favorites", bytes.NewReader(b))
+		req.Header.Set("Authorization", authHeader)
+		res, err := client.Do(req)
+		require.NoError(t, err)
+		res.Body.Close()
+		require.Equal(t, http.StatusCreated, res.StatusCode)
+	}
+	list := func(query string) (*http.Response, models.PaginatedFavorites) {
+		req, _ := http.NewRequest(http.MethodGet, srv.URL+"/users/"+user+"/favorites"+query, nil)
+		req.Header.Set("Authorization", authHeader)
+		res, err := client.Do(req)
+		require.NoError(t, err)
+		defer res.Body.Close()
+		var result models.PaginatedFavorites
+		json.NewDecoder(res.Body).Decode(&result)
+		return res, result
+	}
+
+	for i := 1; i <= 12; i++ {
+		add(fmt.Sprintf("Insight %d", i))
+	}
+
+	res, first := list("?limit=5")
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	require.Len(t, first.Favorites, 5)
+	assert.True(t, first.HasMore)
+	assert.NotEmpty(t, first.NextCursor)
+	assert.Empty(t, first.PrevCursor)
+
+	// Items added between requests must not shift the next page.
+	add("Inserted meanwhile")
+
+	res, second := list("?limit=5&cursor=" + first.NextCursor)
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	require.Len(t, second.Favorites, 5)
+	assert.Equal(t, "Insight 7", second.Favorites[0].Asset.Description)
+	assert.Equal(t, "Insight 3", second.Favorites[4].Asset.Description)
+	assert.True(t, second.HasMore)
+	assert.NotEmpty(t, second.PrevCursor)
+
+	res, third := list("?limit=5&cursor=" + second.NextCursor)
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	require.Len(t, third.Favorites, 2)
+	assert.False(t, third.HasMore)
+	assert.Empty(t, third.NextCursor)
+
+	res, back := list("?limit=5&cursor=" + second.PrevCursor)
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	require.Len(t, back.Favorites, 5)
+	assert.Equal(t, "Insight 12", back.Favorites[0].Asset.Description)
+	assert.Equal(t, "Insight 8", back.Favorites[4].Asset.Description)
+	assert.True(t, back.HasMore)
+	assert.NotEmpty(t, back.PrevCursor, "the inserted item is still ahead of this page")
+
+	tampered := first.NextCursor[:len(first.NextCursor)-2] + "xx"
+	res, _ = list("?limit=5&cursor=" + tampered)
+	assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+}
+
+func TestListFilteringAndSorting(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret-32-chars-long-for-testing-only")
+
+	svc := core.NewService(data.NewInMemoryStore())
+	mux := http.NewServeMux()
+	mux.Handle("/users/", http.StripPrefix("/users", api.NewHandler(svc)))
+	mountAuth(mux)
+
+	srv := httptest.NewServer(api.WithMiddleware(mux))
+	defer srv.Close()
+
+	user := "filteruser"
+	tokenResp := loginAs(t, srv.URL, user)
+	authHeader := "Bearer " + tokenResp.AccessToken
+
+	client := &http.Client{}
+	assets := []map[string]interface{}{
+		{"type": "insight", "description": "Mobile shopping", "payload": map[string]string{"text": "40% prefer mobile"}},
+		{"type": "chart", "description": "Revenue growth", "payload": map[string]interface{}{"title": "Revenue", "xAxis": "Month", "yAxis": "USD", "data": []int{1, 2}}},
+		{"type": "insight", "description": "Social media usage", "payload": map[string]string{"text": "3 hours daily"}},
+		{"type": "chart", "description": "Churn by month", "payload": map[string]interface{}{"title": "Churn", "xAxis": "Month", "yAxis": "%", "data": []int{3, 4}}},
+		{"type": "insight", "description": "Mobile ads", "payload": map[string]string{"text": "ads work"}},
+	}
+	for _, asset := range assets {
+		b, _ := json.Marshal(asset)
+		req, _ := http.NewRequest(http.MethodPost, srv.URL+"/users/"+user+"/favorites", bytes.NewReader(b))
+		req.Header.Set("Authorization", authHeader)
+		res, err := client.Do(req)
+		require.NoError(t, err)
+		res.Body.Close()
+		require.Equal(t, http.StatusCreated, res.StatusCode)
+	}
+
+	list := func(query string) (int, models.PaginatedFavorites) {
+		req, _ := http.NewRequest(http.MethodGet, srv.URL+"/users/"+user+"/favorites"+query, nil)
+		req.Header.Set("Authorization", authHeader)
+		res, err := client.Do(req)
+		require.NoError(t, err)
+		defer res.Body.Close()
+		var result models.PaginatedFavorites
+		json.NewDecoder(res.Body).Decode(&result)
+		return res.StatusCode, result
+	}
+
+	t.Run("Filter by type", func(t *testing.T) {
+		code, result := list("?type=insight&limit=2")
+		require.Equal(t, http.StatusOK, code)
+		assert.Equal(t, 3, result.TotalCount)
+		assert.True(t, result.HasMore)
+		assert.Equal(t, []string{"Mobile ads", "Social media usage"}, descriptionsOf(result.Favorites))
+
+		code, next := list("?type=insight&limit=2&cursor=" + result.NextCursor)
+		require.Equal(t, http.StatusOK, code)
+		assert.Equal(t, []string{"Mobile shopping"}, descriptionsOf(next.Favorites))
+		assert.False(t, next.HasMore)
+
+		code, _ = list("?type=chart&limit=2&cursor=" + result.NextCursor)
+		assert.Equal(t, http.StatusBadRequest, code, "cursor must not be reused with different filters")
+	})
+
+	t.Run("Filter by description and sort", func(t *testing.T) {
+		code, result := list("?description=MOBILE&sort=description&order=asc")
+		require.Equal(t, http.StatusOK, code)
+		assert.Equal(t, 2, result.TotalCount)
+		assert.Equal(t, []string{"Mobile ads", "Mobile shopping"}, descriptionsOf(result.Favorites))
+
+		code, result = list("?sort=createdAt&order=asc&type=chart,insight")
+		require.Equal(t, http.StatusOK, code)
+		assert.Equal(t, "Mobile shopping", result.Favorites[0].Asset.Description)
+	})
+
+	t.Run("Filter by creation range", func(t *testing.T) {
+		code, result := list("?createdAfter=2000-01-01T00:00:00Z&createdBefore=2001-01-01T00:00:00Z")
+		require.Equal(t, http.StatusOK, code)
+		assert.Equal(t, 0, result.TotalCount)
+		assert.Empty(t, result.Favorites)
+	})
+
+	t.Run("Invalid parameters", func(t *testing.T) {
+		for _, query := range []string{"?type=report", "?sort=title", "?order=up", "?createdAfter=yesterday"} {
+			code, _ := list(query)
+			assert.Equal(t, http.StatusBadRequest, code, query)
+		}
+	})
+}
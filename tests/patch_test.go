@@ -0,0 +1,165 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Zisimopoulou/platform-go-challenge/internal/api"
+	"github.com/Zisimopoulou/platform-go-challenge/internal/core"
+	"github.com/Zisimopoulou/platform-go-challenge/internal/data"
+	"github.com/Zisimopoulou/platform-go-challenge/internal/models"
+	"github.com/Zisimopoulou/platform-go-challenge/internal/patch"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMergePatch(t *testing.T) {
+	tests := []struct {
+		name, doc, patch, want string
+	}{
+		{"replace member", `{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{"add member", `{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{"remove member", `{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{"nested", `{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{"arrays are replaced", `{"a":[1,2]}`, `{"a":[3]}`, `{"a":[3]}`},
+		{"non-object patch replaces", `{"a":"b"}`, `["c"]`, `["c"]`},
+		{"numbers survive untouched", `{"n":12345678901234567890,"x":1}`, `{"x":2}`, `{"n":12345678901234567890,"x":2}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := patch.MergePatch([]byte(tt.doc), []byte(tt.patch))
+			require.NoError(t, err)
+			assert.JSONEq(t, tt.want, string(got))
+		})
+	}
+
+	_, err := patch.MergePatch([]byte(`{}`), []byte(`{`))
+	assert.ErrorIs(t, err, patch.ErrInvalidPatch)
+}
+
+func TestJSONPatch(t *testing.T) {
+	tests := []struct {
+		name, doc, patch, want string
+		wantErr             error
+	}{
+		{name: "add member", doc: `{"foo":"bar"}`, patch: `[{"op":"add","path":"/baz","value":"qux"}]`, want: `{"foo":"bar","baz":"qux"}`},
+		{name: "add array element", doc: `{"foo":["bar","baz"]}`, patch: `[{"op":"add","path":"/foo/1","value":"qux"}]`, want: `{"foo":["bar","qux","baz"]}`},
+		{name: "append to array", doc: `{"foo":[1]}`, patch: `[{"op":"add","path":"/foo/-","value":2}]`, want: `{"foo":[1,2]}`},
+		{name: "remove", doc: `{"baz":"qux","foo":"bar"}`, patch: `[{"op":"remove","path":"/baz"}]`, want: `{"foo":"bar"}`},
+		{name: "remove array element", doc: `{"foo":["bar","qux","baz"]}`, patch: `[{"op":"remove","path":"/foo/1"}]`, want: `{"foo":["bar","baz"]}`},
+		{name: "replace", doc: `{"baz":"qux","foo":"bar"}`, patch: `[{"op":"replace","path":"/baz","value":"boo"}]`, want: `{"baz":"boo","foo":"bar"}`},
+		{name: "move", doc: `{"foo":{"bar":"baz","waldo":"fred"},"qux":{"corge":"grault"}}`, patch: `[{"op":"move","from":"/foo/waldo","path":"/qux/thud"}]`, want: `{"foo":{"bar":"baz"},"qux":{"corge":"grault","thud":"fred"}}`},
+		{name: "copy", doc: `{"a":{"b":1}}`, patch: `[{"op":"copy","from":"/a","path":"/c"}]`, want: `{"a":{"b":1},"c":{"b":1}}`},
+		{name: "escaped pointer", doc: `{"a/b":1,"m~n":2}`, patch: `[{"op":"replace","path":"/a~1b","value":3},{"op":"remove","path":"/m~0n"}]`, want: `{"a/b":3}`},
+		{name: "test passes", doc: `{"baz":"qux","foo":["a",2,"c"]}`, patch: `[{"op":"test","path":"/baz","value":"qux"},{"op":"test","path":"/foo/1","value":2.0}]`, want: `{"baz":"qux","foo":["a",2,"c"]}`},
+		{name: "test fails", doc: `{"baz":"qux"}`, patch: `[{"op":"test","path":"/baz","value":"bar"}]`, wantErr: patch.ErrTestFailed},
+		{name: "missing target", doc: `{"foo":"bar"}`, patch: `[{"op":"replace","path":"/baz","value":1}]`, wantErr: patch.ErrPathNotFound},
+		{name: "add to missing parent", doc: `{"foo":"bar"}`, patch: `[{"op":"add","path":"/baz/bat","value":"qux"}]`, wantErr: patch.ErrPathNotFound},
+		{name: "unknown op", doc: `{}`, patch: `[{"op":"frobnicate","path":"/a"}]`, wantErr: patch.ErrInvalidPatch},
+		{name: "missing value", doc: `{}`, patch: `[{"op":"add","path":"/a"}]`, wantErr: patch.ErrInvalidPatch},
+		{name: "move into own child", doc: `{"a":{"b":1}}`, patch: `[{"op":"move","from":"/a","path":"/a/c"}]`, wantErr: patch.ErrInvalidPatch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := patch.ApplyJSONPatch([]byte(tt.doc), []byte(tt.patch))
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.JSONEq(t, tt.want, string(got))
+		})
+	}
+}
+
+func TestPatchFavorite(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret-32-chars-long-for-testing-only")
+
+	store := data.NewInMemoryStore()
+	mux := http.NewServeMux()
+	mux.Handle("/users/", http.StripPrefix("/users", api.NewHandler(core.NewService(store))))
+	mountAuth(mux)
+	srv := httptest.NewServer(api.WithMiddleware(mux))
+	defer srv.Close()
+
+	user := "patchuser"
+	favID, err := store.Add(user, models.RawAsset{
+		Type:        models.TypeChart,
+		Description: "Revenue",
+		Payload:     models.Chart{Title: "Revenue", XAxis: "Month", YAxis: "USD", Data: []int{1, 2, 3}},
+	})
+	require.NoError(t, err)
+
+	tokenResp := loginAs(t, srv.URL, user)
+
+	send := func(contentType, body string) (*http.Response, models.Favorite) {
+		req, _ := http.NewRequest(http.MethodPatch, srv.URL+"/users/"+user+"/favorites/"+favID, bytes.NewReader([]byte(body)))
+		req.Header.Set("Authorization", "Bearer "+tokenResp.AccessToken)
+		req.Header.Set("Content-Type", contentType)
+		res, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer res.Body.Close()
+		var fav models.Favorite
+		json.NewDecoder(res.Body).Decode(&fav)
+		return res, fav
+	}
+
+	t.Run("Merge patch updates payload fields", func(t *testing.T) {
+		res, fav := send("application/merge-patch+json", `{"description":"Revenue 2024","payload":{"title":"Revenue 2024"}}`)
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		assert.NotEmpty(t, res.Header.Get("ETag"))
+		assert.Equal(t, "Revenue 2024", fav.Asset.Description)
+		payload := fav.Asset.Payload.(models.Chart)
+		assert.Equal(t, "Revenue 2024", payload.Title)
+		assert.Equal(t, "Month", payload.XAxis)
+	})
+
+	t.Run("JSON patch edits array elements", func(t *testing.T) {
+		res, fav := send("application/json-patch+json", `[
+			{"op":"test","path":"/payload/data/0","value":1},
+			{"op":"replace","path":"/payload/data/0","value":10},
+			{"op":"add","path":"/payload/data/-","value":4}
+		]`)
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, []int{10, 2, 3, 4}, fav.Asset.Payload.(models.Chart).Data)
+	})
+
+	t.Run("Result is validated", func(t *testing.T) {
+		res, _ := send("application/merge-patch+json", `{"payload":{"title":null}}`)
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+
+		res, _ = send("application/json-patch+json", `[{"op":"replace","path":"/payload/data","value":[1.5]}]`)
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+	})
+
+	t.Run("Read-only fields are rejected", func(t *testing.T) {
+		res, _ := send("application/merge-patch+json", `{"id":"other"}`)
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+	})
+
+	t.Run("Type change needs a matching payload", func(t *testing.T) {
+		res, _ := send("application/merge-patch+json", `{"type":"insight"}`)
+		assert.Equal(t, http.StatusUnprocessableEntity, res.StatusCode)
+
+		res, fav := send("application/json-patch+json", `[
+			{"op":"replace","path":"/type","value":"insight"},
+			{"op":"replace","path":"/payload","value":{"text":"Revenue doubled"}}
+		]`)
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, models.TypeInsight, fav.Asset.Type)
+	})
+
+	t.Run("Failed test operation", func(t *testing.T) {
+		res, _ := send("application/json-patch+json", `[{"op":"test","path":"/type","value":"chart"}]`)
+		assert.Equal(t, http.StatusConflict, res.StatusCode)
+	})
+
+	t.Run("Unsupported content type", func(t *testing.T) {
+		res, _ := send("application/json", `{"description":"x"}`)
+		assert.Equal(t, http.StatusUnsupportedMediaType, res.StatusCode)
+		assert.Contains(t, res.Header.Get("Accept-Patch"), "application/merge-patch+json")
+	})
+}
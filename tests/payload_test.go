@@ -0,0 +1,103 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Zisimopoulou/platform-go-challenge/internal/api"
+	"github.com/Zisimopoulou/platform-go-challenge/internal/core"
+	"github.com/Zisimopoulou/platform-go-challenge/internal/data"
+	"github.com/Zisimopoulou/platform-go-challenge/internal/models"
+	"github.com/Zisimopoulou/platform-go-challenge/internal/validation"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTypedPayloadDecoding(t *testing.T) {
+	const doc = `{"id":"1","type":"chart","description":"Sales","createdAt":"2026-01-02T03:04:05Z","updatedAt":"2026-01-02T03:04:05Z","version":3,"payload":{"title":"Sales","xAxis":"Month","yAxis":"EUR","data":[1,2,3]}}`
+	var asset models.RawAsset
+	require.NoError(t, json.Unmarshal([]byte(doc), &asset))
+	assert.Equal(t, models.Chart{Title: "Sales", XAxis: "Month", YAxis: "EUR", Data: []int{1, 2, 3}}, asset.Payload)
+	assert.Equal(t, int64(3), asset.Version)
+	encoded, err := json.Marshal(asset)
+	require.NoError(t, err)
+	assert.JSONEq(t, doc, string(encoded))
+
+	var again models.RawAsset
+	require.NoError(t, json.Unmarshal(encoded, &again))
+	reencoded, err := json.Marshal(again)
+	require.NoError(t, err)
+	assert.Equal(t, string(encoded), string(reencoded))
+
+	// A payload that does not fit its type is a decoding error.
+	err = json.Unmarshal([]byte(`{"type":"chart","payload":{"title":"T","xAxis":"x","yAxis":"y","data":[1.5]}}`), &asset)
+	assert.ErrorIs(t, err, models.ErrInvalidPayload)
+	assert.ErrorContains(t, err, "Chart.data")
+	require.NoError(t, json.Unmarshal([]byte(`{"type":"dashboard","payload":{"widgets":2}}`), &asset))
+	assert.Equal(t, models.Document{Type: "dashboard", Value: map[string]interface{}{"widgets": 2.0}}, asset.Payload)
+	require.NoError(t, json.Unmarshal([]byte(`{"type":"insight"}`), &asset))
+	assert.Nil(t, asset.Payload)
+
+	// Validation takes the registered type only, and stores it by value.
+	asset = models.RawAsset{Type: models.TypeInsight, Payload: models.Document{Type: models.TypeInsight, Value: map[string]interface{}{"text": "untyped"}}}
+	assert.ErrorIs(t, validation.ValidateAsset(&asset), models.ErrInvalidPayload)
+	asset.Payload = models.Chart{Title: "T", XAxis: "x", YAxis: "y", Data: []int{1}}
+	assert.ErrorIs(t, validation.ValidateAsset(&asset), models.ErrInvalidPayload)
+	asset.Payload = &models.Insight{Text: "pointer"}
+	require.NoError(t, validation.ValidateAsset(&asset))
+	assert.Equal(t, models.Insight{Text: "pointer"}, asset.Payload)
+
+	for name, newStore := range storeImplementations() {
+		t.Run(name, func(t *testing.T) {
+			store := newStore(t)
+			chart := models.RawAsset{Type: models.TypeChart, Payload: models.Chart{Title: "T", XAxis: "x", YAxis: "y", Data: []int{4, 5}}}
+			id, err := store.Add("alice", chart)
+			require.NoError(t, err)
+			got, err := store.Get("alice", id)
+			require.NoError(t, err)
+			assert.Equal(t, chart.Payload, got.Payload)
+		})
+	}
+}
+
+func TestStrictPayloads(t *testing.T) {
+	const doc = `{"type":"insight","payload":{"text":"hello","colour":"red"}}`
+	var asset models.RawAsset
+	require.NoError(t, json.Unmarshal([]byte(doc), &asset))
+	assert.Equal(t, models.Insight{Text: "hello"}, asset.Payload, "unknown fields are dropped by default")
+
+	models.SetStrictPayloads(true)
+	t.Cleanup(func() { models.SetStrictPayloads(false) })
+	err := json.Unmarshal([]byte(doc), &asset)
+	assert.ErrorIs(t, err, models.ErrInvalidPayload)
+	assert.ErrorContains(t, err, `unknown field "colour"`)
+
+	t.Setenv("JWT_SECRET", "test-secret-32-chars-long-for-testing-only")
+	mux := http.NewServeMux()
+	mux.Handle("/users/", http.StripPrefix("/users", api.NewHandler(core.NewService(data.NewInMemoryStore()))))
+	mountAuth(mux)
+	srv := httptest.NewServer(api.WithMiddleware(mux))
+	defer srv.Close()
+	token := loginAs(t, srv.URL, "alice").AccessToken
+	do := func(method, path, contentType, body string) *http.Response {
+		req, _ := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Content-Type", contentType)
+		res, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		t.Cleanup(func() { res.Body.Close() })
+		return res
+	}
+
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodPost, "/users/alice/favorites", "application/json", doc).StatusCode)
+	res := do(http.MethodPost, "/users/alice/favorites", "application/json", `{"type":"insight","payload":{"text":"hello"}}`)
+	require.Equal(t, http.StatusCreated, res.StatusCode)
+	var created map[string]string
+	require.NoError(t, json.NewDecoder(res.Body).Decode(&created))
+	path := "/users/alice/favorites/" + created["favoriteId"]
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodPatch, path, "application/merge-patch+json", `{"payload":{"colour":"red"}}`).StatusCode)
+	assert.Equal(t, http.StatusOK, do(http.MethodPatch, path, "application/merge-patch+json", `{"payload":{"text":"bye"}}`).StatusCode)
+}
@@ -0,0 +1,145 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Zisimopoulou/platform-go-challenge/internal/api"
+	"github.com/Zisimopoulou/platform-go-challenge/internal/auth"
+	"github.com/Zisimopoulou/platform-go-challenge/internal/core"
+	"github.com/Zisimopoulou/platform-go-challenge/internal/data"
+	"github.com/Zisimopoulou/platform-go-challenge/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestRoleBasedAccess(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret-32-chars-long-for-testing-only")
+
+	var (
+		mu     sync.Mutex
+		events []core.AuditEvent
+	)
+	audit := core.AuditLogFunc(func(e core.AuditEvent) {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, e)
+	})
+	lastEvent := func() core.AuditEvent {
+		mu.Lock()
+		defer mu.Unlock()
+		require.NotEmpty(t, events)
+		return events[len(events)-1]
+	}
+	eventCount := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(events)
+	}
+
+	svc := core.NewService(data.NewInMemoryStore(), core.WithAuditLog(audit))
+	users := auth.NewService(auth.NewInMemoryCredentialStore(), auth.WithBcryptCost(bcrypt.MinCost))
+	authHandler := api.NewAuthHandler(users, auth.NewSessions(auth.NewInMemoryRefreshStore(), 0))
+	mux := http.NewServeMux()
+	mux.Handle("/users/", http.StripPrefix("/users", api.NewHandler(svc)))
+	mux.Handle("/admin/", http.StripPrefix("/admin", api.NewAdminHandler(svc, users)))
+	mux.HandleFunc("/auth/register", authHandler.Register)
+	mux.HandleFunc("/auth/login", authHandler.Login)
+	mux.HandleFunc("/auth/refresh", authHandler.Refresh)
+	srv := httptest.NewServer(api.WithMiddleware(mux))
+	defer srv.Close()
+
+	do := func(token, method, path, body string) *http.Response {
+		req, _ := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Content-Type", "application/json")
+		res, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		t.Cleanup(func() { res.Body.Close() })
+		return res
+	}
+
+	alice := loginAs(t, srv.URL, "alice").AccessToken
+	res := do(alice, http.MethodPost, "/users/alice/favorites", `{"type":"insight","description":"mine","payload":{"text":"t"}}`)
+	require.Equal(t, http.StatusCreated, res.StatusCode)
+	var created map[string]string
+	require.NoError(t, json.NewDecoder(res.Body).Decode(&created))
+	favPath := "/users/alice/favorites/" + created["favoriteId"]
+	assert.Zero(t, eventCount(), "owners acting on their own favorites are not audited")
+
+	bob := loginAs(t, srv.URL, "bob").AccessToken
+	assert.Equal(t, http.StatusForbidden, do(bob, http.MethodGet, "/users/alice/favorites", "").StatusCode)
+	denied := lastEvent()
+	assert.Equal(t, "bob", denied.Actor)
+	assert.Equal(t, []core.Role{core.RoleUser}, denied.Roles)
+	assert.Equal(t, "alice", denied.Owner)
+	assert.Equal(t, core.ActionRead, denied.Action)
+	assert.Equal(t, "/users/alice/favorites", denied.Resource)
+	assert.False(t, denied.Allowed)
+	assert.Equal(t, http.StatusForbidden, do(bob, http.MethodGet, "/admin/users", "").StatusCode)
+
+	loginAs(t, srv.URL, "root")
+	_, err := users.SetRoles("root", []string{string(core.RoleAdmin)})
+	require.NoError(t, err)
+	res = postCredentials(t, srv.URL+"/auth/login", "root", testPassword)
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	var rootTokens api.TokenResponse
+	require.NoError(t, json.NewDecoder(res.Body).Decode(&rootTokens))
+	root := rootTokens.AccessToken
+
+	res = do(root, http.MethodGet, "/admin/users", "")
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	var listing struct {
+		Users []models.UserSummary `json:"users"`
+	}
+	require.NoError(t, json.NewDecoder(res.Body).Decode(&listing))
+	assert.Equal(t, []models.UserSummary{{UserID: "alice", Favorites: 1}}, listing.Users)
+	assert.Equal(t, core.ActionManage, lastEvent().Action)
+	assert.True(t, lastEvent().Allowed)
+
+	// Support gets read-only access once an admin grants the role; the new
+	// role arrives with the next refreshed access token.
+	res = do(root, http.MethodPut, "/admin/users/bob/roles", `{"roles":["support-readonly"]}`)
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	var roles api.RolesResponse
+	require.NoError(t, json.NewDecoder(res.Body).Decode(&roles))
+	assert.Equal(t, []core.Role{core.RoleSupportReadOnly}, roles.Roles)
+	assert.Equal(t, http.StatusBadRequest, do(root, http.MethodPut, "/admin/users/bob/roles", `{"roles":["superuser"]}`).StatusCode)
+	assert.Equal(t, http.StatusNotFound, do(root, http.MethodPut, "/admin/users/nobody/roles", `{"roles":["admin"]}`).StatusCode)
+	assert.Equal(t, http.StatusForbidden, do(alice, http.MethodPut, "/admin/users/alice/roles", `{"roles":["admin"]}`).StatusCode)
+
+	res = postCredentials(t, srv.URL+"/auth/login", "bob", testPassword)
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	var bobTokens api.TokenResponse
+	require.NoError(t, json.NewDecoder(res.Body).Decode(&bobTokens))
+	refreshBody, _ := json.Marshal(api.RefreshRequest{RefreshToken: bobTokens.RefreshToken})
+	res, err = http.Post(srv.URL+"/auth/refresh", "application/json", bytes.NewReader(refreshBody))
+	require.NoError(t, err)
+	defer res.Body.Close()
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	require.NoError(t, json.NewDecoder(res.Body).Decode(&bobTokens))
+	support := bobTokens.AccessToken
+
+	assert.Equal(t, http.StatusOK, do(support, http.MethodGet, "/users/alice/favorites", "").StatusCode)
+	assert.Equal(t, http.StatusOK, do(support, http.MethodGet, favPath, "").StatusCode)
+	assert.True(t, lastEvent().Allowed)
+	assert.Equal(t, http.StatusForbidden, do(support, http.MethodDelete, favPath, "").StatusCode)
+	assert.Equal(t, core.ActionDelete, lastEvent().Action)
+	assert.False(t, lastEvent().Allowed)
+
+	// Admins may inspect and delete, but not write on somebody's behalf.
+	assert.Equal(t, http.StatusOK, do(root, http.MethodGet, favPath, "").StatusCode)
+	assert.Equal(t, http.StatusForbidden, do(root, http.MethodPost, "/users/alice/favorites", `{"type":"insight","description":"x","payload":{"text":"t"}}`).StatusCode)
+	assert.Equal(t, core.ActionWrite, lastEvent().Action)
+	assert.Equal(t, http.StatusNoContent, do(root, http.MethodDelete, favPath, "").StatusCode)
+	assert.Equal(t, "root", lastEvent().Actor)
+	assert.Equal(t, favPath, lastEvent().Resource)
+	assert.True(t, lastEvent().Allowed)
+	assert.Equal(t, http.StatusNotFound, do(alice, http.MethodGet, favPath, "").StatusCode)
+}
@@ -0,0 +1,246 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Zisimopoulou/platform-go-challenge/internal/api"
+	"github.com/Zisimopoulou/platform-go-challenge/internal/auth"
+	"github.com/Zisimopoulou/platform-go-challenge/internal/core"
+	"github.com/Zisimopoulou/platform-go-challenge/internal/data"
+	"github.com/Zisimopoulou/platform-go-challenge/internal/models"
+	"github.com/Zisimopoulou/platform-go-challenge/internal/schema"
+	"github.com/Zisimopoulou/platform-go-challenge/internal/validation"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const kpiSchema = `{
+  "$schema": "https://json-schema.org/draft/2020-12/schema",
+  "title": "KPI",
+  "description": "A key performance indicator with a target.",
+  "type": "object",
+  "required": ["name", "target"],
+  "additionalProperties": false,
+  "properties": {
+    "name": {"type": "string", "minLength": 1, "maxLength": 40},
+    "unit": {"enum": ["%", "EUR", "count"]},
+    "target": {"type": "number", "minimum": 0},
+    "owner": {"type": "string", "pattern": "^[a-z]+$"},
+    "quarters": {"type": "array", "maxItems": 4, "uniqueItems": true, "items": {"type": "integer", "minimum": 1, "maximum": 4}}
+  }
+}`
+
+func TestSchemaValidate(t *testing.T) {
+	s, err := schema.Compile([]byte(kpiSchema))
+	require.NoError(t, err)
+	assert.Equal(t, "KPI", s.Title)
+	assert.Equal(t, []string{"name", "owner"}, s.StringProperties())
+
+	decode := func(doc string) interface{} {
+		var v interface{}
+		require.NoError(t, json.Unmarshal([]byte(doc), &v))
+		return v
+	}
+	assert.NoError(t, s.Validate(decode(`{"name":"NPS","unit":"%","target":40,"owner":"sales","quarters":[1,2]}`)))
+
+	err = s.Validate(decode(`{"name":"","unit":"USD","target":-1,"owner":"Sales","quarters":[1,1,2.5,5,3],"extra":true}`))
+	var errs schema.ValidationErrors
+	require.ErrorAs(t, err, &errs)
+	assert.Equal(t, schema.ValidationErrors{
+		{Field: "extra", Message: "is not allowed"},
+		{Field: "name", Message: "must be at least 1 characters"},
+		{Field: "owner", Message: "must match ^[a-z]+$"},
+		{Field: "quarters", Message: "must have at most 4 items"},
+		{Field: "quarters", Message: "must not contain duplicates"},
+		{Field: "quarters.2", Message: "must be integer"},
+		{Field: "quarters.3", Message: "must be at most 4"},
+		{Field: "target", Message: "must be at least 0"},
+		{Field: "unit", Message: `must be one of "%", "EUR", "count"`},
+	}, errs)
+
+	err = s.Validate(decode(`["not", "an", "object"]`))
+	require.ErrorAs(t, err, &errs)
+	assert.Equal(t, schema.ValidationErrors{{Message: "must be object"}}, errs)
+	require.ErrorAs(t, s.Validate(decode(`{}`)), &errs)
+	assert.Len(t, errs, 2)
+
+	for _, bad := range []string{
+		`{"$ref": "#/definitions/kpi"}`,
+		`{"type": "date"}`,
+		`{"properties": {"name": {"pattern": "("}}}`,
+		`{"items": {"oneOf": []}}`,
+		`[]`,
+	} {
+		_, err := schema.Compile([]byte(bad))
+		assert.ErrorIs(t, err, schema.ErrInvalidSchema, bad)
+	}
+}
+
+func writeSchema(t *testing.T, dir, name, doc string) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(doc), 0o644))
+}
+
+func TestSchemaLoader(t *testing.T) {
+	dir := t.TempDir()
+	writeSchema(t, dir, "kpi.json", kpiSchema)
+	writeSchema(t, dir, "notes.txt", "ignored")
+	loader := schema.NewLoader(dir)
+	t.Cleanup(func() {
+		models.UnregisterAssetType("kpi")
+		models.UnregisterAssetType("memo")
+	})
+
+	infos, err := loader.Load()
+	require.NoError(t, err)
+	require.Len(t, infos, 1)
+	info, ok := models.LookupAssetType("kpi")
+	require.True(t, ok)
+	assert.Equal(t, "KPI", info.DisplayName)
+	assert.Equal(t, "A key performance indicator with a target.", info.Description)
+	assert.Equal(t, []string{"name", "owner"}, info.SearchFields)
+
+	asset := models.RawAsset{Type: "kpi", Payload: models.Document{Type: "kpi", Value: map[string]interface{}{"name": "NPS", "target": 40.0}}}
+	require.NoError(t, validation.ValidateAsset(&asset))
+	asset.Payload = models.Document{Type: "kpi", Value: map[string]interface{}{"target": -5.0}}
+	var errs schema.ValidationErrors
+	require.ErrorAs(t, validation.ValidateAsset(&asset), &errs)
+	assert.Equal(t, schema.ValidationErrors{
+		{Field: "name", Message: "is required"},
+		{Field: "target", Message: "must be at least 0"},
+	}, errs)
+
+	// Reloading picks up edited and new schemas.
+	writeSchema(t, dir, "kpi.json", strings.Replace(kpiSchema, `"required": ["name", "target"]`, `"required": ["name", "target", "owner"]`, 1))
+	writeSchema(t, dir, "memo.json", `{"type": "object", "required": ["body"], "x-searchFields": ["body"]}`)
+	_, err = loader.Load()
+	require.NoError(t, err)
+	asset.Payload = models.Document{Type: "kpi", Value: map[string]interface{}{"name": "NPS", "target": 40.0}}
+	assert.Error(t, validation.ValidateAsset(&asset))
+	memo, ok := models.LookupAssetType("memo")
+	require.True(t, ok)
+	assert.Equal(t, "memo", memo.DisplayName)
+	assert.Equal(t, []string{"body"}, memo.SearchFields)
+
+	// A broken schema, or one redefining a built-in type, keeps the loaded ones.
+	writeSchema(t, dir, "broken.json", `{"type": `)
+	_, err = loader.Load()
+	assert.ErrorContains(t, err, "broken.json")
+	require.NoError(t, os.Remove(filepath.Join(dir, "broken.json")))
+	writeSchema(t, dir, "chart.json", `{"type": "object"}`)
+	_, err = loader.Load()
+	assert.ErrorIs(t, err, models.ErrAssetTypeExists)
+	_, ok = models.LookupAssetType("memo")
+	assert.True(t, ok)
+	info, _ = models.LookupAssetType(models.TypeChart)
+	assert.Empty(t, info.Schema)
+
+	require.NoError(t, os.Remove(filepath.Join(dir, "chart.json")))
+	require.NoError(t, os.Remove(filepath.Join(dir, "memo.json")))
+	_, err = loader.Load()
+	require.NoError(t, err)
+	_, ok = models.LookupAssetType("memo")
+	assert.False(t, ok)
+}
+
+func TestSchemaAssetTypeEndpoints(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret-32-chars-long-for-testing-only")
+	dir := t.TempDir()
+	writeSchema(t, dir, "kpi.json", kpiSchema)
+	loader := schema.NewLoader(dir)
+	_, err := loader.Load()
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		models.UnregisterAssetType("kpi")
+		models.UnregisterAssetType("memo")
+	})
+
+	svc := core.NewService(data.NewInMemoryStore())
+	users := auth.NewService(auth.NewInMemoryCredentialStore(), auth.WithBcryptCost(bcrypt.MinCost))
+	authHandler := api.NewAuthHandler(users, auth.NewSessions(auth.NewInMemoryRefreshStore(), 0))
+	opts := []api.Option{api.WithSchemaLoader(loader)}
+	mux := http.NewServeMux()
+	mux.Handle("/users/", http.StripPrefix("/users", api.NewHandler(svc, opts...)))
+	mux.Handle("/admin/", http.StripPrefix("/admin", api.NewAdminHandler(svc, users, opts...)))
+	mux.Handle("/asset-types", api.NewAssetTypesHandler(opts...))
+	mux.HandleFunc("/auth/register", authHandler.Register)
+	mux.HandleFunc("/auth/login", authHandler.Login)
+	srv := httptest.NewServer(api.WithMiddleware(mux))
+	defer srv.Close()
+
+	do := func(token, method, path, body string) *http.Response {
+		req, _ := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Content-Type", "application/json")
+		res, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		t.Cleanup(func() { res.Body.Close() })
+		return res
+	}
+	alice := loginAs(t, srv.URL, "alice").AccessToken
+
+	res := do(alice, http.MethodPost, "/users/alice/favorites", `{"type":"kpi","payload":{"name":"Churn","target":5,"owner":"ops"}}`)
+	require.Equal(t, http.StatusCreated, res.StatusCode)
+	res = do(alice, http.MethodPost, "/users/alice/favorites", `{"type":"kpi","payload":{"target":"high","colour":"red"}}`)
+	require.Equal(t, http.StatusBadRequest, res.StatusCode)
+	var invalid struct {
+		Error   string            `json:"error"`
+		Details map[string]string `json:"details"`
+	}
+	require.NoError(t, json.NewDecoder(res.Body).Decode(&invalid))
+	assert.Equal(t, "validation failed", invalid.Error)
+	assert.Equal(t, map[string]string{
+		"colour": "is not allowed",
+		"name":   "is required",
+		"target": "must be number",
+	}, invalid.Details)
+
+	res = do(alice, http.MethodGet, "/users/alice/favorites/search?q=churn", "")
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	var found struct {
+		TotalCount int `json:"totalCount"`
+	}
+	require.NoError(t, json.NewDecoder(res.Body).Decode(&found))
+	assert.Equal(t, 1, found.TotalCount)
+
+	res = do(alice, http.MethodGet, "/asset-types", "")
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	var listing struct {
+		AssetTypes []struct {
+			Name   models.AssetType `json:"name"`
+			Schema json.RawMessage  `json:"schema"`
+		} `json:"assetTypes"`
+	}
+	require.NoError(t, json.NewDecoder(res.Body).Decode(&listing))
+	require.Len(t, listing.AssetTypes, 4)
+	assert.Equal(t, models.AssetType("kpi"), listing.AssetTypes[3].Name)
+	assert.JSONEq(t, kpiSchema, string(listing.AssetTypes[3].Schema))
+	assert.Empty(t, listing.AssetTypes[0].Schema)
+
+	writeSchema(t, dir, "memo.json", `{"type": "object"}`)
+	assert.Equal(t, http.StatusForbidden, do(alice, http.MethodPost, "/admin/asset-types/reload", "").StatusCode)
+	loginAs(t, srv.URL, "root")
+	_, err = users.SetRoles("root", []string{string(core.RoleAdmin)})
+	require.NoError(t, err)
+	res = postCredentials(t, srv.URL+"/auth/login", "root", testPassword)
+	var rootTokens api.TokenResponse
+	require.NoError(t, json.NewDecoder(res.Body).Decode(&rootTokens))
+	root := rootTokens.AccessToken
+
+	res = do(root, http.MethodPost, "/admin/asset-types/reload", "")
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	require.NoError(t, json.NewDecoder(res.Body).Decode(&listing))
+	assert.Len(t, listing.AssetTypes, 5)
+
+	writeSchema(t, dir, "memo.json", `{"type": "memo"}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, do(root, http.MethodPost, "/admin/asset-types/reload", "").StatusCode)
+	_, ok := models.LookupAssetType("memo")
+	assert.True(t, ok)
+}
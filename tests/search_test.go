@@ -0,0 +1,186 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/Zisimopoulou/platform-go-challenge/internal/api"
+	"github.com/Zisimopoulou/platform-go-challenge/internal/core"
+	"github.com/Zisimopoulou/platform-go-challenge/internal/data"
+	"github.com/Zisimopoulou/platform-go-challenge/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func searchFixtures() []models.RawAsset {
+	return []models.RawAsset{
+		{
+			Type:        models.TypeChart,
+			Description: "Quarterly numbers",
+			Payload:     models.Chart{Title: "Revenue growth", XAxis: "Quarter", YAxis: "Revenue ($)", Data: []int{1, 2}},
+		},
+		{
+			Type:        models.TypeInsight,
+			Description: "Retail trends",
+			Payload:     models.Insight{Text: "Mobile revenue overtook desktop & tablet combined"},
+		},
+		{
+			Type:        models.TypeAudience,
+			Description: "Core segment",
+			Payload: models.Audience{
+				Gender: "Female", BirthCountry: "Greece", AgeGroup: "24-35", HoursDaily: "3+", PurchasesLastM: "2",
+			},
+		},
+	}
+}
+
+func TestStoreSearch(t *testing.T) {
+	for name, newStore := range storeImplementations() {
+		t.Run(name, func(t *testing.T) {
+			store := newStore(t)
+			var ids []string
+			for _, asset := range searchFixtures() {
+				id, err := store.Add("alice", asset)
+				require.NoError(t, err)
+				ids = append(ids, id)
+			}
+			_, err := store.Add("bob", searchFixtures()[0])
+			require.NoError(t, err)
+
+			results, total, err := store.Search("alice", "revenue", 10)
+			require.NoError(t, err)
+			assert.Equal(t, 2, total)
+			require.Len(t, results, 2)
+			assert.Equal(t, ids[0], results[0].FavoriteID, "title and axis matches outrank a single text match")
+			assert.Equal(t, "<mark>Revenue</mark> growth", results[0].Highlights["title"])
+			assert.Equal(t, "<mark>Revenue</mark> ($)", results[0].Highlights["yAxis"])
+			assert.Equal(t, "Mobile <mark>revenue</mark> overtook desktop &amp; tablet combined", results[1].Highlights["text"])
+			assert.Greater(t, results[0].Score, results[1].Score)
+
+			results, total, err = store.Search("alice", "greece", 10)
+			require.NoError(t, err)
+			assert.Equal(t, 1, total)
+			require.Len(t, results, 1)
+			assert.Equal(t, ids[2], results[0].FavoriteID)
+			assert.Equal(t, "<mark>Greece</mark>", results[0].Highlights["birthCountry"])
+
+			results, total, err = store.Search("alice", "revenue", 1)
+			require.NoError(t, err)
+			assert.Equal(t, 2, total)
+			assert.Len(t, results, 1)
+
+			_, err = store.UpdateDescription("alice", "alice", ids[2], "Greek shoppers", data.AnyVersion)
+			require.NoError(t, err)
+			results, _, err = store.Search("alice", "shoppers", 10)
+			require.NoError(t, err)
+			require.Len(t, results, 1)
+			assert.Equal(t, "Greek <mark>shoppers</mark>", results[0].Highlights["description"])
+			results, _, err = store.Search("alice", "segment", 10)
+			require.NoError(t, err)
+			assert.Empty(t, results)
+
+			require.NoError(t, store.Delete("alice", ids[0], data.AnyVersion))
+			results, total, err = store.Search("alice", "revenue", 10)
+			require.NoError(t, err)
+			assert.Equal(t, 1, total)
+			require.Len(t, results, 1)
+			assert.Equal(t, ids[1], results[0].FavoriteID)
+		})
+	}
+}
+
+func TestSQLiteSearchIndexRebuiltOnOpen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "favorites.db")
+	store, err := data.NewSQLiteStore(path)
+	require.NoError(t, err)
+	_, err = store.Add("alice", searchFixtures()[1])
+	require.NoError(t, err)
+	require.NoError(t, store.Close())
+
+	store, err = data.NewSQLiteStore(path)
+	require.NoError(t, err)
+	defer store.Close()
+
+	results, total, err := store.Search("alice", "desktop", 10)
+	require.NoError(t, err)
+	assert.Equal(t, 1, total)
+	assert.Len(t, results, 1)
+}
+
+func TestSearchIndexFollowsConcurrentWrites(t *testing.T) {
+	for name, newStore := range storeImplementations() {
+		t.Run(name, func(t *testing.T) {
+			store := newStore(t)
+			id, err := store.Add("alice", insightAsset("start"))
+			require.NoError(t, err)
+
+			words := []string{"alpha", "bravo", "charlie", "delta", "echo", "foxtrot", "golf", "hotel"}
+			var wg sync.WaitGroup
+			for _, word := range words {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					_, err := store.UpdateDescription("alice", "alice", id, word, data.AnyVersion)
+					assert.NoError(t, err)
+				}()
+			}
+			wg.Wait()
+
+			final, err := store.Get("alice", id)
+			require.NoError(t, err)
+			for _, word := range words {
+				_, total, err := store.Search("alice", word, 10)
+				require.NoError(t, err)
+				if word == final.Description {
+					assert.Equal(t, 1, total, word)
+				} else {
+					assert.Zero(t, total, word)
+				}
+			}
+		})
+	}
+}
+
+func TestSearchEndpoint(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret-32-chars-long-for-testing-only")
+
+	store := data.NewInMemoryStore()
+	for _, asset := range searchFixtures() {
+		_, err := store.Add("searchuser", asset)
+		require.NoError(t, err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/users/", http.StripPrefix("/users", api.NewHandler(core.NewService(store))))
+	mountAuth(mux)
+	srv := httptest.NewServer(api.WithMiddleware(mux))
+	defer srv.Close()
+
+	tokenResp := loginAs(t, srv.URL, "searchuser")
+
+	search := func(query string) (int, models.SearchResults) {
+		req, _ := http.NewRequest(http.MethodGet, srv.URL+"/users/searchuser/favorites/search"+query, nil)
+		req.Header.Set("Authorization", "Bearer "+tokenResp.AccessToken)
+		res, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer res.Body.Close()
+		var results models.SearchResults
+		json.NewDecoder(res.Body).Decode(&results)
+		return res.StatusCode, results
+	}
+
+	code, results := search("?q=mobile+revenue")
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "mobile revenue", results.Query)
+	assert.Equal(t, 2, results.TotalCount)
+	require.NotEmpty(t, results.Results)
+	assert.Equal(t, models.TypeInsight, results.Results[0].Asset.Type)
+	assert.Contains(t, results.Results[0].Highlights["text"], "<mark>Mobile</mark> <mark>revenue</mark>")
+
+	code, _ = search("?q=")
+	assert.Equal(t, http.StatusBadRequest, code)
+}
@@ -0,0 +1,102 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Zisimopoulou/platform-go-challenge/internal/api"
+	"github.com/Zisimopoulou/platform-go-challenge/internal/auth"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRefreshTokenRotation(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret-32-chars-long-for-testing-only")
+
+	mux := http.NewServeMux()
+	mountAuth(mux)
+	srv := httptest.NewServer(api.WithMiddleware(mux))
+	defer srv.Close()
+
+	post := func(path, refreshToken string) (int, api.TokenResponse) {
+		body, _ := json.Marshal(api.RefreshRequest{RefreshToken: refreshToken})
+		res, err := http.Post(srv.URL+path, "application/json", bytes.NewReader(body))
+		require.NoError(t, err)
+		defer res.Body.Close()
+		var tokens api.TokenResponse
+		if res.StatusCode == http.StatusOK {
+			require.NoError(t, json.NewDecoder(res.Body).Decode(&tokens))
+		}
+		return res.StatusCode, tokens
+	}
+
+	first := loginAs(t, srv.URL, "alice")
+
+	code, second := post("/auth/refresh", first.RefreshToken)
+	require.Equal(t, http.StatusOK, code)
+	assert.NotEqual(t, first.RefreshToken, second.RefreshToken)
+
+	code, third := post("/auth/refresh", second.RefreshToken)
+	require.Equal(t, http.StatusOK, code)
+
+	// Replaying a spent token revokes the whole family, including the
+	// token the legitimate client holds now.
+	code, _ = post("/auth/refresh", first.RefreshToken)
+	assert.Equal(t, http.StatusUnauthorized, code)
+	code, _ = post("/auth/refresh", third.RefreshToken)
+	assert.Equal(t, http.StatusUnauthorized, code)
+
+	res := postCredentials(t, srv.URL+"/auth/login", "alice", testPassword)
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	var fresh api.TokenResponse
+	require.NoError(t, json.NewDecoder(res.Body).Decode(&fresh))
+
+	code, rotated := post("/auth/refresh", fresh.RefreshToken)
+	require.Equal(t, http.StatusOK, code, "other sessions are unaffected")
+
+	code, _ = post("/auth/logout", rotated.RefreshToken)
+	assert.Equal(t, http.StatusNoContent, code)
+	code, _ = post("/auth/refresh", rotated.RefreshToken)
+	assert.Equal(t, http.StatusUnauthorized, code)
+
+	code, _ = post("/auth/refresh", "not-a-jwt")
+	assert.Equal(t, http.StatusUnauthorized, code)
+	code, _ = post("/auth/logout", first.AccessToken)
+	assert.Equal(t, http.StatusUnauthorized, code)
+}
+
+func TestRefreshTokenOfAnotherSubject(t *testing.T) {
+	const secret = "test-secret-32-chars-long-for-testing-only"
+	t.Setenv("JWT_SECRET", secret)
+
+	mux := http.NewServeMux()
+	mountAuth(mux)
+	srv := httptest.NewServer(api.WithMiddleware(mux))
+	defer srv.Close()
+
+	post := func(refreshToken string) int {
+		body, _ := json.Marshal(api.RefreshRequest{RefreshToken: refreshToken})
+		res, err := http.Post(srv.URL+"/auth/refresh", "application/json", bytes.NewReader(body))
+		require.NoError(t, err)
+		res.Body.Close()
+		return res.StatusCode
+	}
+
+	alice := loginAs(t, srv.URL, "alice")
+	loginAs(t, srv.URL, "bob")
+	ks, err := auth.NewKeySet(auth.NewHMACKey("", []byte(secret)))
+	require.NoError(t, err)
+	claims, err := ks.Parse(alice.RefreshToken)
+	require.NoError(t, err)
+	claims["sub"] = "bob"
+	forged, err := ks.Sign(claims)
+	require.NoError(t, err)
+
+	// The mismatch is caught before the token is spent, so alice's session
+	// is neither rotated behind her back nor revoked.
+	assert.Equal(t, http.StatusUnauthorized, post(forged))
+	assert.Equal(t, http.StatusOK, post(alice.RefreshToken))
+}
@@ -0,0 +1,209 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Zisimopoulou/platform-go-challenge/internal/api"
+	"github.com/Zisimopoulou/platform-go-challenge/internal/core"
+	"github.com/Zisimopoulou/platform-go-challenge/internal/data"
+	"github.com/Zisimopoulou/platform-go-challenge/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStoreShares(t *testing.T) {
+	for name, newStore := range storeImplementations() {
+		t.Run(name, func(t *testing.T) {
+			store := newStore(t)
+			chart, err := store.Add("alice", insightAsset("chart"))
+			require.NoError(t, err)
+			other, err := store.Add("alice", insightAsset("other"))
+			require.NoError(t, err)
+
+			_, err = store.PutShare(models.Share{OwnerID: "alice", FavoriteID: "missing", UserID: "bob", Permission: models.PermissionRead})
+			assert.ErrorIs(t, err, data.ErrNotFound)
+			_, err = store.PutShare(models.Share{OwnerID: "bob", FavoriteID: chart, UserID: "carol", Permission: models.PermissionRead})
+			assert.ErrorIs(t, err, data.ErrNotFound, "the owner is part of the favorite's identity")
+
+			share, err := store.PutShare(models.Share{OwnerID: "alice", FavoriteID: chart, UserID: "carol", Permission: models.PermissionRead})
+			require.NoError(t, err)
+			assert.False(t, share.CreatedAt.IsZero())
+			_, err = store.PutShare(models.Share{OwnerID: "alice", FavoriteID: chart, UserID: "bob", Permission: models.PermissionRead})
+			require.NoError(t, err)
+			_, err = store.PutShare(models.Share{OwnerID: "alice", FavoriteID: chart, UserID: "bob", Permission: models.PermissionEdit})
+			require.NoError(t, err)
+			_, err = store.PutShare(models.Share{OwnerID: "alice", FavoriteID: other, UserID: "bob", Permission: models.PermissionRead})
+			require.NoError(t, err)
+
+			got, err := store.GetShare("alice", chart, "bob")
+			require.NoError(t, err)
+			assert.Equal(t, models.PermissionEdit, got.Permission)
+			_, err = store.GetShare("alice", chart, "dave")
+			assert.ErrorIs(t, err, data.ErrNotFound)
+
+			shares, err := store.ListShares("alice", chart)
+			require.NoError(t, err)
+			require.Len(t, shares, 2)
+			assert.Equal(t, "bob", shares[0].UserID)
+			assert.Equal(t, "carol", shares[1].UserID)
+			_, err = store.ListShares("alice", "missing")
+			assert.ErrorIs(t, err, data.ErrNotFound)
+
+			shared, err := store.SharedWith("bob")
+			require.NoError(t, err)
+			require.Len(t, shared, 2)
+			assert.Equal(t, other, shared[0].FavoriteID, "most recently shared first")
+			assert.Equal(t, "alice", shared[0].OwnerID)
+			assert.Equal(t, "other", shared[0].Asset.Description)
+			assert.Equal(t, models.PermissionEdit, shared[1].Permission)
+
+			// A failed atomic batch must not lose the shares of a favorite
+			// it deleted along the way.
+			results, err := store.Batch("alice", []data.BatchOp{
+				{Kind: data.BatchDelete, Asset: models.RawAsset{ID: chart}},
+				{Kind: data.BatchDelete, Asset: models.RawAsset{ID: "missing"}},
+			}, true)
+			require.NoError(t, err)
+			assert.ErrorIs(t, results[1].Err, data.ErrNotFound)
+			shares, err = store.ListShares("alice", chart)
+			require.NoError(t, err)
+			assert.Len(t, shares, 2)
+
+			require.NoError(t, store.DeleteShare("alice", other, "bob"))
+			assert.ErrorIs(t, store.DeleteShare("alice", other, "bob"), data.ErrNotFound)
+
+			require.NoError(t, store.Delete("alice", chart, data.AnyVersion))
+			shared, err = store.SharedWith("bob")
+			require.NoError(t, err)
+			assert.Empty(t, shared)
+			_, err = store.GetShare("alice", chart, "carol")
+			assert.ErrorIs(t, err, data.ErrNotFound, "deleting a favorite deletes its shares")
+		})
+	}
+}
+
+func TestJournalReplaysShares(t *testing.T) {
+	dir := t.TempDir()
+	store := openJournaled(t, dir, 0)
+	kept, err := store.Add("alice", insightAsset("kept"))
+	require.NoError(t, err)
+	gone, err := store.Add("alice", insightAsset("gone"))
+	require.NoError(t, err)
+	for _, fav := range []string{kept, gone} {
+		_, err = store.PutShare(models.Share{OwnerID: "alice", FavoriteID: fav, UserID: "bob", Permission: models.PermissionRead})
+		require.NoError(t, err)
+	}
+	_, err = store.PutShare(models.Share{OwnerID: "alice", FavoriteID: kept, UserID: "carol", Permission: models.PermissionRead})
+	require.NoError(t, err)
+	require.NoError(t, store.DeleteShare("alice", kept, "carol"))
+	require.NoError(t, store.Delete("alice", gone, data.AnyVersion))
+
+	check := func(s data.Store) {
+		shared, err := s.SharedWith("bob")
+		require.NoError(t, err)
+		require.Len(t, shared, 1)
+		assert.Equal(t, kept, shared[0].FavoriteID)
+		shared, err = s.SharedWith("carol")
+		require.NoError(t, err)
+		assert.Empty(t, shared)
+	}
+
+	fromJournal := openJournaled(t, dir, 0)
+	check(fromJournal)
+	require.NoError(t, fromJournal.Close())
+
+	fromSnapshot := openJournaled(t, dir, 0)
+	defer fromSnapshot.Close()
+	check(fromSnapshot)
+}
+
+func TestSharingEndpoints(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret-32-chars-long-for-testing-only")
+
+	var events []core.AuditEvent
+	svc := core.NewService(data.NewInMemoryStore(), core.WithAuditLog(core.AuditLogFunc(func(e core.AuditEvent) {
+		events = append(events, e)
+	})))
+	mux := http.NewServeMux()
+	mux.Handle("/users/", http.StripPrefix("/users", api.NewHandler(svc)))
+	mountAuth(mux)
+	srv := httptest.NewServer(api.WithMiddleware(mux))
+	defer srv.Close()
+
+	do := func(token, method, path, body string) *http.Response {
+		req, _ := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Content-Type", "application/json")
+		res, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		t.Cleanup(func() { res.Body.Close() })
+		return res
+	}
+	alice := loginAs(t, srv.URL, "alice").AccessToken
+	bob := loginAs(t, srv.URL, "bob").AccessToken
+	carol := loginAs(t, srv.URL, "carol").AccessToken
+
+	res := do(alice, http.MethodPost, "/users/alice/favorites", `{"type":"insight","description":"shared","payload":{"text":"t"}}`)
+	require.Equal(t, http.StatusCreated, res.StatusCode)
+	var created map[string]string
+	require.NoError(t, json.NewDecoder(res.Body).Decode(&created))
+	favPath := "/users/alice/favorites/" + created["favoriteId"]
+
+	assert.Equal(t, http.StatusForbidden, do(bob, http.MethodGet, favPath, "").StatusCode)
+
+	res = do(alice, http.MethodPut, favPath+"/shares/bob", `{"permission":"read"}`)
+	require.Equal(t, http.StatusCreated, res.StatusCode)
+	var share models.Share
+	require.NoError(t, json.NewDecoder(res.Body).Decode(&share))
+	assert.Equal(t, models.Share{OwnerID: "alice", FavoriteID: created["favoriteId"], UserID: "bob", Permission: models.PermissionRead, CreatedAt: share.CreatedAt}, share)
+	assert.Equal(t, http.StatusCreated, do(alice, http.MethodPut, favPath+"/shares/carol", `{"permission":"edit"}`).StatusCode)
+	assert.Equal(t, http.StatusBadRequest, do(alice, http.MethodPut, favPath+"/shares/bob", `{"permission":"own"}`).StatusCode)
+	assert.Equal(t, http.StatusBadRequest, do(alice, http.MethodPut, favPath+"/shares/alice", `{"permission":"read"}`).StatusCode)
+	assert.Equal(t, http.StatusNotFound, do(alice, http.MethodPut, "/users/alice/favorites/missing/shares/bob", `{"permission":"read"}`).StatusCode)
+
+	// Read grants read; the rest of alice's favorites stay private.
+	assert.Equal(t, http.StatusOK, do(bob, http.MethodGet, favPath, "").StatusCode)
+	assert.Equal(t, models.PermissionRead, events[len(events)-1].Share)
+	assert.Equal(t, http.StatusForbidden, do(bob, http.MethodPatch, favPath, `{"description":"bob was here"}`).StatusCode)
+	assert.Equal(t, http.StatusForbidden, do(bob, http.MethodGet, "/users/alice/favorites", "").StatusCode)
+	assert.Equal(t, http.StatusForbidden, do(bob, http.MethodPut, favPath+"/shares/bob", `{"permission":"edit"}`).StatusCode)
+
+	// Edit grants writes, but deleting and re-sharing stay with the owner.
+	req, _ := http.NewRequest(http.MethodPatch, srv.URL+favPath, strings.NewReader(`{"description":"carol was here"}`))
+	req.Header.Set("Authorization", "Bearer "+carol)
+	req.Header.Set("Content-Type", "application/merge-patch+json")
+	res, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, http.StatusForbidden, do(carol, http.MethodDelete, favPath, "").StatusCode)
+	assert.Equal(t, http.StatusForbidden, do(carol, http.MethodPut, favPath+"/shares/dave", `{"permission":"read"}`).StatusCode)
+
+	res = do(bob, http.MethodGet, "/users/bob/shared", "")
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	var shared struct {
+		Favorites []models.SharedFavorite `json:"favorites"`
+	}
+	require.NoError(t, json.NewDecoder(res.Body).Decode(&shared))
+	require.Len(t, shared.Favorites, 1)
+	assert.Equal(t, "carol was here", shared.Favorites[0].Asset.Description)
+	assert.Equal(t, "alice", shared.Favorites[0].OwnerID)
+	assert.Equal(t, http.StatusForbidden, do(bob, http.MethodGet, "/users/carol/shared", "").StatusCode)
+
+	res = do(alice, http.MethodGet, favPath+"/shares", "")
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	var listing struct {
+		Shares []models.Share `json:"shares"`
+	}
+	require.NoError(t, json.NewDecoder(res.Body).Decode(&listing))
+	require.Len(t, listing.Shares, 2)
+
+	assert.Equal(t, http.StatusOK, do(alice, http.MethodPut, favPath+"/shares/bob", `{"permission":"edit"}`).StatusCode)
+	assert.Equal(t, http.StatusNoContent, do(alice, http.MethodDelete, favPath+"/shares/bob", "").StatusCode)
+	assert.Equal(t, http.StatusNotFound, do(alice, http.MethodDelete, favPath+"/shares/bob", "").StatusCode)
+	assert.Equal(t, http.StatusForbidden, do(bob, http.MethodGet, favPath, "").StatusCode)
+}
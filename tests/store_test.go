@@ -0,0 +1,367 @@
+package tests
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/Zisimopoulou/platform-go-challenge/internal/data"
+	"github.com/Zisimopoulou/platform-go-challenge/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func storeImplementations() map[string]func(t *testing.T) data.Store {
+	return map[string]func(t *testing.T) data.Store{
+		"memory": func(t *testing.T) data.Store {
+			return data.NewInMemoryStore()
+		},
+		"journaled memory": func(t *testing.T) data.Store {
+			store, err := data.NewJournaledInMemoryStore(data.JournalConfig{Dir: t.TempDir(), SnapshotEvery: 3, NoSync: true})
+			require.NoError(t, err)
+			t.Cleanup(func() { store.Close() })
+			return store
+		},
+		"sqlite": func(t *testing.T) data.Store {
+			store, err := data.NewSQLiteStore(filepath.Join(t.TempDir(), "favorites.db"))
+			require.NoError(t, err)
+			t.Cleanup(func() { store.Close() })
+			return store
+		},
+	}
+}
+
+func insightAsset(text string) models.RawAsset {
+	return models.RawAsset{
+		Type:        models.TypeInsight,
+		Description: text,
+		Payload:     models.Insight{Text: text},
+	}
+}
+
+func descriptionsOf(favs []models.Favorite) []string {
+	out := make([]string, 0, len(favs))
+	for _, fav := range favs {
+		out = append(out, fav.Asset.Description)
+	}
+	return out
+}
+
+func TestStoreConformance(t *testing.T) {
+	for name, newStore := range storeImplementations() {
+		t.Run(name, func(t *testing.T) {
+			t.Run("Add and List newest first", func(t *testing.T) {
+				store := newStore(t)
+				var ids []string
+				for i := 1; i <= 5; i++ {
+					id, err := store.Add("alice", insightAsset(fmt.Sprintf("Insight %d", i)))
+					require.NoError(t, err)
+					ids = append(ids, id)
+				}
+				_, err := store.Add("bob", insightAsset("other user"))
+				require.NoError(t, err)
+
+				favs, total, err := store.List("alice", data.ListQuery{Limit: 10})
+				require.NoError(t, err)
+				assert.Equal(t, 5, total)
+				require.Len(t, favs, 5)
+				for i, fav := range favs {
+					assert.Equal(t, ids[len(ids)-1-i], fav.FavoriteID)
+					assert.Equal(t, fav.FavoriteID, fav.Asset.ID)
+					assert.False(t, fav.Asset.CreatedAt.IsZero())
+				}
+				assert.Equal(t, models.TypeInsight, favs[0].Asset.Type)
+				assert.Equal(t, "Insight 5", favs[0].Asset.Description)
+				assert.Equal(t, models.Insight{Text: "Insight 5"}, favs[0].Asset.Payload)
+			})
+
+			t.Run("List paginates", func(t *testing.T) {
+				store := newStore(t)
+				for i := 1; i <= 7; i++ {
+					_, err := store.Add("alice", insightAsset(fmt.Sprintf("Insight %d", i)))
+					require.NoError(t, err)
+				}
+
+				favs, total, err := store.List("alice", data.ListQuery{Limit: 3, Offset: 3})
+				require.NoError(t, err)
+				assert.Equal(t, 7, total)
+				require.Len(t, favs, 3)
+				assert.Equal(t, "Insight 4", favs[0].Asset.Description)
+
+				favs, total, err = store.List("alice", data.ListQuery{Limit: 3, Offset: 6})
+				require.NoError(t, err)
+				assert.Equal(t, 7, total)
+				assert.Len(t, favs, 1)
+
+				favs, total, err = store.List("alice", data.ListQuery{Limit: 3, Offset: 10})
+				require.NoError(t, err)
+				assert.Equal(t, 7, total)
+				assert.Empty(t, favs)
+
+				favs, total, err = store.List("nobody", data.ListQuery{Limit: 3})
+				require.NoError(t, err)
+				assert.Equal(t, 0, total)
+				assert.NotNil(t, favs)
+				assert.Empty(t, favs)
+			})
+
+			t.Run("List seeks by keyset", func(t *testing.T) {
+				store := newStore(t)
+				for i := 1; i <= 6; i++ {
+					_, err := store.Add("alice", insightAsset(fmt.Sprintf("Insight %d", i)))
+					require.NoError(t, err)
+				}
+				all, _, err := store.List("alice", data.ListQuery{Limit: 10})
+				require.NoError(t, err)
+				require.Len(t, all, 6)
+
+				after := data.KeysetOf(all[1].Asset)
+				favs, total, err := store.List("alice", data.ListQuery{Limit: 2, After: &after})
+				require.NoError(t, err)
+				assert.Equal(t, 6, total)
+				require.Len(t, favs, 2)
+				assert.Equal(t, all[2].FavoriteID, favs[0].FavoriteID)
+				assert.Equal(t, all[3].FavoriteID, favs[1].FavoriteID)
+
+				before := data.KeysetOf(all[4].Asset)
+				favs, _, err = store.List("alice", data.ListQuery{Limit: 2, Before: &before})
+				require.NoError(t, err)
+				require.Len(t, favs, 2)
+				assert.Equal(t, all[2].FavoriteID, favs[0].FavoriteID)
+				assert.Equal(t, all[3].FavoriteID, favs[1].FavoriteID)
+
+				before = data.KeysetOf(all[1].Asset)
+				favs, _, err = store.List("alice", data.ListQuery{Limit: 5, Before: &before})
+				require.NoError(t, err)
+				require.Len(t, favs, 1)
+				assert.Equal(t, all[0].FavoriteID, favs[0].FavoriteID)
+
+				require.NoError(t, store.Delete("alice", all[2].FavoriteID, data.AnyVersion))
+				favs, _, err = store.List("alice", data.ListQuery{Limit: 2, After: &after})
+				require.NoError(t, err)
+				require.Len(t, favs, 2)
+				assert.Equal(t, all[3].FavoriteID, favs[0].FavoriteID)
+				assert.Equal(t, all[4].FavoriteID, favs[1].FavoriteID)
+			})
+
+			t.Run("List filters and sorts", func(t *testing.T) {
+				store := newStore(t)
+				descriptions := []string{"banana", "Apple pie", "cherry", "apple", "date"}
+				for i, desc := range descriptions {
+					asset := insightAsset(desc)
+					if i%2 == 0 {
+						asset.Type = models.TypeChart
+					}
+					_, err := store.Add("alice", asset)
+					require.NoError(t, err)
+				}
+
+				favs, total, err := store.List("alice", data.ListQuery{Limit: 10, Filter: data.ListFilter{Types: []models.AssetType{models.TypeChart}}})
+				require.NoError(t, err)
+				assert.Equal(t, 3, total)
+				assert.Equal(t, []string{"date", "cherry", "banana"}, descriptionsOf(favs))
+
+				favs, total, err = store.List("alice", data.ListQuery{Limit: 10, Filter: data.ListFilter{Description: "APPLE"}})
+				require.NoError(t, err)
+				assert.Equal(t, 2, total)
+				assert.Equal(t, []string{"apple", "Apple pie"}, descriptionsOf(favs))
+
+				favs, _, err = store.List("alice", data.ListQuery{Limit: 10, Sort: data.SortByDescription, Ascending: true})
+				require.NoError(t, err)
+				assert.Equal(t, []string{"Apple pie", "apple", "banana", "cherry", "date"}, descriptionsOf(favs))
+
+				favs, _, err = store.List("alice", data.ListQuery{Limit: 10, Ascending: true})
+				require.NoError(t, err)
+				assert.Equal(t, descriptions, descriptionsOf(favs))
+
+				after := data.KeysetOf(favs[0].Asset)
+				q := data.ListQuery{Limit: 2, Sort: data.SortByDescription, After: &after}
+				favs, total, err = store.List("alice", q)
+				require.NoError(t, err)
+				assert.Equal(t, 5, total)
+				assert.Equal(t, []string{"apple", "Apple pie"}, descriptionsOf(favs))
+
+				all, _, err := store.List("alice", data.ListQuery{Limit: 10, Ascending: true})
+				require.NoError(t, err)
+				favs, total, err = store.List("alice", data.ListQuery{Limit: 10, Filter: data.ListFilter{
+					CreatedAfter:  all[1].Asset.CreatedAt,
+					CreatedBefore: all[3].Asset.CreatedAt,
+				}})
+				require.NoError(t, err)
+				assert.Equal(t, 2, total)
+				assert.Equal(t, []string{"cherry", "Apple pie"}, descriptionsOf(favs))
+			})
+
+			t.Run("Description filter ignores case beyond ASCII", func(t *testing.T) {
+				store := newStore(t)
+				for _, desc := range []string{"ΑΘΗΝΑ πωλήσεις", "Πωλήσεις Θεσσαλονίκη", "Ärger im Büro", "costs"} {
+					_, err := store.Add("alice", insightAsset(desc))
+					require.NoError(t, err)
+				}
+
+				favs, total, err := store.List("alice", data.ListQuery{Limit: 10, Filter: data.ListFilter{Description: "ΠΩΛΉΣΕΙ"}})
+				require.NoError(t, err)
+				assert.Equal(t, 2, total)
+				assert.Equal(t, []string{"Πωλήσεις Θεσσαλονίκη", "ΑΘΗΝΑ πωλήσεις"}, descriptionsOf(favs))
+
+				favs, _, err = store.List("alice", data.ListQuery{Limit: 10, Filter: data.ListFilter{Description: "αθηνα"}})
+				require.NoError(t, err)
+				assert.Equal(t, []string{"ΑΘΗΝΑ πωλήσεις"}, descriptionsOf(favs))
+
+				favs, _, err = store.List("alice", data.ListQuery{Limit: 10, Filter: data.ListFilter{Description: "äRGER"}})
+				require.NoError(t, err)
+				assert.Equal(t, []string{"Ärger im Büro"}, descriptionsOf(favs))
+			})
+
+			t.Run("Get", func(t *testing.T) {
+				store := newStore(t)
+				id, err := store.Add("alice", insightAsset("initial"))
+				require.NoError(t, err)
+
+				asset, err := store.Get("alice", id)
+				require.NoError(t, err)
+				assert.Equal(t, id, asset.ID)
+				assert.Equal(t, "initial", asset.Description)
+				assert.Equal(t, asset.CreatedAt, asset.UpdatedAt)
+
+				_, err = store.UpdateDescription("alice", "alice", id, "updated", data.AnyVersion)
+				require.NoError(t, err)
+				updated, err := store.Get("alice", id)
+				require.NoError(t, err)
+				assert.Equal(t, "updated", updated.Description)
+				assert.Equal(t, asset.CreatedAt, updated.CreatedAt)
+				assert.True(t, updated.UpdatedAt.After(asset.UpdatedAt))
+
+				_, err = store.Get("bob", id)
+				assert.ErrorIs(t, err, data.ErrNotFound)
+				_, err = store.Get("alice", "missing")
+				assert.ErrorIs(t, err, data.ErrNotFound)
+			})
+
+			t.Run("Update replaces the asset", func(t *testing.T) {
+				store := newStore(t)
+				id, err := store.Add("alice", insightAsset("initial"))
+				require.NoError(t, err)
+				before, err := store.Get("alice", id)
+				require.NoError(t, err)
+
+				_, err = store.Update("alice", models.RawAsset{
+					ID:          id,
+					Type:        models.TypeChart,
+					Description: "now a chart",
+					Payload:     models.Chart{Title: "T", XAxis: "x", YAxis: "y", Data: []int{1}},
+				}, data.AnyVersion)
+				require.NoError(t, err)
+
+				after, err := store.Get("alice", id)
+				require.NoError(t, err)
+				assert.Equal(t, models.TypeChart, after.Type)
+				assert.Equal(t, "now a chart", after.Description)
+				assert.Equal(t, "T", after.Payload.(models.Chart).Title)
+				assert.Equal(t, before.CreatedAt, after.CreatedAt)
+
+				_, err = store.Update("bob", after, data.AnyVersion)
+				assert.ErrorIs(t, err, data.ErrNotFound)
+			})
+
+			t.Run("UpdateDescription and Delete", func(t *testing.T) {
+				store := newStore(t)
+				id, err := store.Add("alice", insightAsset("initial"))
+				require.NoError(t, err)
+
+				_, err = store.UpdateDescription("alice", "alice", id, "updated", data.AnyVersion)
+				require.NoError(t, err)
+				favs, _, err := store.List("alice", data.ListQuery{Limit: 10})
+				require.NoError(t, err)
+				require.Len(t, favs, 1)
+				assert.Equal(t, "updated", favs[0].Asset.Description)
+
+				_, err = store.UpdateDescription("bob", "bob", id, "nope", data.AnyVersion)
+				assert.ErrorIs(t, err, data.ErrNotFound)
+				assert.ErrorIs(t, store.Delete("bob", id, data.AnyVersion), data.ErrNotFound)
+
+				require.NoError(t, store.Delete("alice", id, data.AnyVersion))
+				assert.ErrorIs(t, store.Delete("alice", id, data.AnyVersion), data.ErrNotFound)
+				_, err = store.UpdateDescription("alice", "alice", id, "gone", data.AnyVersion)
+				assert.ErrorIs(t, err, data.ErrNotFound)
+
+				_, total, err := store.List("alice", data.ListQuery{Limit: 10})
+				require.NoError(t, err)
+				assert.Equal(t, 0, total)
+			})
+
+			t.Run("Versions", func(t *testing.T) {
+				store := newStore(t)
+				id, err := store.Add("alice", insightAsset("initial"))
+				require.NoError(t, err)
+				asset, err := store.Get("alice", id)
+				require.NoError(t, err)
+				assert.Equal(t, int64(1), asset.Version)
+
+				updated, err := store.UpdateDescription("alice", "alice", id, "second", 1)
+				require.NoError(t, err)
+				assert.Equal(t, int64(2), updated.Version)
+
+				_, err = store.UpdateDescription("alice", "alice", id, "stale", 1)
+				assert.ErrorIs(t, err, data.ErrVersionMismatch)
+				asset.Description = "stale"
+				_, err = store.Update("alice", asset, 1)
+				assert.ErrorIs(t, err, data.ErrVersionMismatch)
+				assert.ErrorIs(t, store.Delete("alice", id, 1), data.ErrVersionMismatch)
+
+				updated, err = store.Update("alice", asset, 2)
+				require.NoError(t, err)
+				assert.Equal(t, int64(3), updated.Version)
+				assert.Equal(t, "stale", updated.Description)
+
+				_, err = store.UpdateDescription("alice", "alice", "missing", "x", 1)
+				assert.ErrorIs(t, err, data.ErrNotFound)
+				require.NoError(t, store.Delete("alice", id, 3))
+			})
+
+			t.Run("Users", func(t *testing.T) {
+				store := newStore(t)
+				users, err := store.Users()
+				require.NoError(t, err)
+				assert.Empty(t, users)
+
+				for _, user := range []string{"carol", "alice", "carol"} {
+					_, err := store.Add(user, insightAsset("by "+user))
+					require.NoError(t, err)
+				}
+				id, err := store.Add("bob", insightAsset("gone"))
+				require.NoError(t, err)
+				require.NoError(t, store.Delete("bob", id, data.AnyVersion))
+
+				users, err = store.Users()
+				require.NoError(t, err)
+				assert.Equal(t, []models.UserSummary{{UserID: "alice", Favorites: 1}, {UserID: "carol", Favorites: 2}}, users)
+			})
+		})
+	}
+}
+
+func TestSQLiteStorePersistsAcrossReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "favorites.db")
+
+	store, err := data.NewSQLiteStore(path)
+	require.NoError(t, err)
+	first, err := store.Add("alice", insightAsset("first"))
+	require.NoError(t, err)
+	require.NoError(t, store.Close())
+
+	store, err = data.NewSQLiteStore(path)
+	require.NoError(t, err)
+	defer store.Close()
+
+	second, err := store.Add("alice", insightAsset("second"))
+	require.NoError(t, err)
+	assert.NotEqual(t, first, second)
+
+	favs, total, err := store.List("alice", data.ListQuery{Limit: 10})
+	require.NoError(t, err)
+	assert.Equal(t, 2, total)
+	require.Len(t, favs, 2)
+	assert.Equal(t, second, favs[0].FavoriteID)
+	assert.Equal(t, first, favs[1].FavoriteID)
+}
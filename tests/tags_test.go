@@ -0,0 +1,261 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Zisimopoulou/platform-go-challenge/internal/api"
+	"github.com/Zisimopoulou/platform-go-challenge/internal/core"
+	"github.com/Zisimopoulou/platform-go-challenge/internal/data"
+	"github.com/Zisimopoulou/platform-go-challenge/internal/models"
+	"github.com/Zisimopoulou/platform-go-challenge/internal/validation"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizeTags(t *testing.T) {
+	tags, err := validation.NormalizeTags([]string{" Q3 Review ", "q3-review", "Finance", "team:growth"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"finance", "q3-review", "team:growth"}, tags)
+
+	tags, err = validation.NormalizeTags(nil)
+	require.NoError(t, err)
+	assert.Nil(t, tags)
+
+	for _, bad := range []string{"", "  ", "a/b", "<script>", strings.Repeat("x", validation.MaxTagLen+1)} {
+		_, err := validation.NormalizeTags([]string{bad})
+		assert.ErrorIs(t, err, validation.ErrInvalidTag, bad)
+	}
+
+	many := make([]string, validation.MaxTagsPerFavorite+1)
+	for i := range many {
+		many[i] = fmt.Sprintf("t%d", i)
+	}
+	_, err = validation.NormalizeTags(many)
+	assert.ErrorIs(t, err, validation.ErrTooManyTags)
+
+	asset := insightAsset("tagged")
+	asset.Tags = []string{"B", "a", "b"}
+	require.NoError(t, validation.ValidateAsset(&asset))
+	assert.Equal(t, []string{"a", "b"}, asset.Tags)
+}
+
+func taggedAsset(text string, tags ...string) models.RawAsset {
+	asset := insightAsset(text)
+	asset.Tags = tags
+	return asset
+}
+
+func TestStoreTags(t *testing.T) {
+	for name, newStore := range storeImplementations() {
+		t.Run(name, func(t *testing.T) {
+			store := newStore(t)
+			_, err := store.Add("alice", taggedAsset("both", "finance", "q3"))
+			require.NoError(t, err)
+			financeID, err := store.Add("alice", taggedAsset("finance", "finance"))
+			require.NoError(t, err)
+			_, err = store.Add("alice", taggedAsset("q3", "q3"))
+			require.NoError(t, err)
+			_, err = store.Add("alice", insightAsset("untagged"))
+			require.NoError(t, err)
+			_, err = store.Add("bob", taggedAsset("bob's", "finance"))
+			require.NoError(t, err)
+
+			got, err := store.Get("alice", financeID)
+			require.NoError(t, err)
+			assert.Equal(t, []string{"finance"}, got.Tags)
+
+			list := func(f data.ListFilter) []string {
+				favs, total, err := store.List("alice", data.ListQuery{Limit: 10, Filter: f})
+				require.NoError(t, err)
+				assert.Equal(t, len(favs), total)
+				return descriptionsOf(favs)
+			}
+			assert.Equal(t, []string{"q3", "finance", "both"}, list(data.ListFilter{Tags: []string{"finance", "q3"}}))
+			assert.Equal(t, []string{"both"}, list(data.ListFilter{Tags: []string{"finance", "q3"}, AllTags: true}))
+			assert.Equal(t, []string{"finance", "both"}, list(data.ListFilter{Tags: []string{"finance"}, AllTags: true}))
+			assert.Empty(t, list(data.ListFilter{Tags: []string{"missing"}}))
+			assert.Equal(t, []string{"finance"}, list(data.ListFilter{Tags: []string{"finance"}, Description: "fin"}))
+
+			tags, err := store.Tags("alice")
+			require.NoError(t, err)
+			assert.Equal(t, []models.TagCount{{Tag: "finance", Count: 2}, {Tag: "q3", Count: 2}}, tags)
+
+			got.Tags = []string{"archive"}
+			_, err = store.Update("alice", got, data.AnyVersion)
+			require.NoError(t, err)
+			tags, err = store.Tags("alice")
+			require.NoError(t, err)
+			assert.Equal(t, []models.TagCount{{Tag: "q3", Count: 2}, {Tag: "archive", Count: 1}, {Tag: "finance", Count: 1}}, tags)
+			assert.Equal(t, []string{"finance"}, list(data.ListFilter{Tags: []string{"archive"}}))
+
+			require.NoError(t, store.Delete("alice", financeID, data.AnyVersion))
+			tags, err = store.Tags("alice")
+			require.NoError(t, err)
+			assert.Equal(t, []models.TagCount{{Tag: "q3", Count: 2}, {Tag: "finance", Count: 1}}, tags)
+
+			tags, err = store.Tags("carol")
+			require.NoError(t, err)
+			assert.Empty(t, tags)
+		})
+	}
+}
+
+func TestStoreTagLimitPerUser(t *testing.T) {
+	for name, newStore := range storeImplementations() {
+		t.Run(name, func(t *testing.T) {
+			store := newStore(t)
+			tags := make([]string, data.MaxTagsPerUser)
+			for i := range tags {
+				tags[i] = fmt.Sprintf("t%03d", i)
+			}
+			id, err := store.Add("alice", taggedAsset("full", tags...))
+			require.NoError(t, err)
+
+			_, err = store.Add("alice", taggedAsset("one more", "t000", "new"))
+			assert.ErrorIs(t, err, data.ErrTagLimit)
+			_, err = store.Add("alice", taggedAsset("reuse", "t000", "t499"))
+			assert.NoError(t, err, "existing tags do not count against the limit")
+			_, err = store.Add("bob", taggedAsset("other user", "new"))
+			assert.NoError(t, err)
+
+			results, err := store.Batch("alice", []data.BatchOp{
+				{Kind: data.BatchUpdate, Asset: models.RawAsset{ID: id, Type: models.TypeInsight, Payload: models.Insight{Text: "x"}, Tags: []string{"new"}}},
+			}, true)
+			require.NoError(t, err)
+			assert.ErrorIs(t, results[0].Err, data.ErrTagLimit)
+		})
+	}
+}
+
+func TestJournalReplaysTags(t *testing.T) {
+	dir := t.TempDir()
+	store := openJournaled(t, dir, 0)
+	id, err := store.Add("alice", taggedAsset("a", "finance", "q3"))
+	require.NoError(t, err)
+	_, err = store.Add("alice", taggedAsset("b", "q3"))
+	require.NoError(t, err)
+	asset, err := store.Get("alice", id)
+	require.NoError(t, err)
+	asset.Tags = []string{"finance"}
+	_, err = store.Update("alice", asset, data.AnyVersion)
+	require.NoError(t, err)
+
+	want := []models.TagCount{{Tag: "finance", Count: 1}, {Tag: "q3", Count: 1}}
+	fromJournal := openJournaled(t, dir, 0)
+	tags, err := fromJournal.Tags("alice")
+	require.NoError(t, err)
+	assert.Equal(t, want, tags)
+	require.NoError(t, fromJournal.Close())
+
+	fromSnapshot := openJournaled(t, dir, 0)
+	defer fromSnapshot.Close()
+	tags, err = fromSnapshot.Tags("alice")
+	require.NoError(t, err)
+	assert.Equal(t, want, tags)
+}
+
+func TestTagEndpoints(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret-32-chars-long-for-testing-only")
+
+	svc := core.NewService(data.NewInMemoryStore())
+	mux := http.NewServeMux()
+	mux.Handle("/users/", http.StripPrefix("/users", api.NewHandler(svc)))
+	mountAuth(mux)
+	srv := httptest.NewServer(api.WithMiddleware(mux))
+	defer srv.Close()
+
+	token := loginAs(t, srv.URL, "alice").AccessToken
+	do := func(method, path, body string, header ...string) *http.Response {
+		req, _ := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Content-Type", "application/json")
+		for i := 0; i+1 < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		res, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		t.Cleanup(func() { res.Body.Close() })
+		return res
+	}
+	add := func(desc, tags string) string {
+		res := do(http.MethodPost, "/users/alice/favorites", `{"type":"insight","description":"`+desc+`","tags":`+tags+`,"payload":{"text":"t"}}`)
+		require.Equal(t, http.StatusCreated, res.StatusCode)
+		var created map[string]string
+		require.NoError(t, json.NewDecoder(res.Body).Decode(&created))
+		return created["favoriteId"]
+	}
+	decodeFav := func(res *http.Response) models.Favorite {
+		var fav models.Favorite
+		require.NoError(t, json.NewDecoder(res.Body).Decode(&fav))
+		return fav
+	}
+
+	first := add("first", `["Finance", "Q3 Review"]`)
+	second := add("second", `["q3-review"]`)
+	res := do(http.MethodPost, "/users/alice/favorites", `{"type":"insight","tags":["no/slashes"],"payload":{"text":"t"}}`)
+	assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+
+	res = do(http.MethodPost, "/users/alice/favorites/"+second+"/tags", `{"tags":["Urgent","finance"]}`)
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, `"2"`, res.Header.Get("ETag"))
+	assert.Equal(t, []string{"finance", "q3-review", "urgent"}, decodeFav(res).Asset.Tags)
+
+	// Adding tags it already has leaves the favorite untouched.
+	res = do(http.MethodPost, "/users/alice/favorites/"+second+"/tags", `{"tags":["urgent"]}`)
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, `"2"`, res.Header.Get("ETag"))
+
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodPost, "/users/alice/favorites/"+second+"/tags", `{"tags":[]}`).StatusCode)
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodPost, "/users/alice/favorites/"+second+"/tags", `{"tags":["a b/c"]}`).StatusCode)
+	assert.Equal(t, http.StatusNotFound, do(http.MethodPost, "/users/alice/favorites/missing/tags", `{"tags":["x"]}`).StatusCode)
+	assert.Equal(t, http.StatusPreconditionFailed, do(http.MethodDelete, "/users/alice/favorites/"+second+"/tags/urgent", "", "If-Match", `"1"`).StatusCode)
+
+	res = do(http.MethodDelete, "/users/alice/favorites/"+second+"/tags/Urgent", "", "If-Match", `"2"`)
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, []string{"finance", "q3-review"}, decodeFav(res).Asset.Tags)
+
+	res = do(http.MethodGet, "/users/alice/tags", "")
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	var listing struct {
+		Tags []models.TagCount `json:"tags"`
+	}
+	require.NoError(t, json.NewDecoder(res.Body).Decode(&listing))
+	assert.Equal(t, []models.TagCount{{Tag: "finance", Count: 2}, {Tag: "q3-review", Count: 2}}, listing.Tags)
+
+	res = do(http.MethodPatch, "/users/alice/favorites/"+first, `{"tags":["archive"]}`, "Content-Type", "application/merge-patch+json")
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, []string{"archive"}, decodeFav(res).Asset.Tags)
+
+	list := func(query string) []string {
+		res := do(http.MethodGet, "/users/alice/favorites?"+query, "")
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		var page models.PaginatedFavorites
+		require.NoError(t, json.NewDecoder(res.Body).Decode(&page))
+		return descriptionsOf(page.Favorites)
+	}
+	assert.Equal(t, []string{"second", "first"}, list("tags=archive,Finance"))
+	assert.Equal(t, []string{"second"}, list("tags=finance&tags=q3-review&tagMatch=all"))
+	assert.Empty(t, list("tags=archive,finance&tagMatch=all"))
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodGet, "/users/alice/favorites?tagMatch=some", "").StatusCode)
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodGet, "/users/alice/favorites?tags=a/b", "").StatusCode)
+
+	// A filter is not a favorite: it may name more tags than one can carry.
+	many := []string{"finance"}
+	for i := range 30 {
+		many = append(many, fmt.Sprintf("unused-%d", i))
+	}
+	assert.Equal(t, []string{"second"}, list("tags="+strings.Join(many, ",")))
+	for i := range 100 {
+		many = append(many, fmt.Sprintf("more-%d", i))
+	}
+	res = do(http.MethodGet, "/users/alice/favorites?tags="+strings.Join(many, ","), "")
+	assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+	body, _ := io.ReadAll(res.Body)
+	assert.Contains(t, string(body), "at most 100 tags can be filtered on")
+}
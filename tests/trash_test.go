@@ -0,0 +1,197 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Zisimopoulou/platform-go-challenge/internal/api"
+	"github.com/Zisimopoulou/platform-go-challenge/internal/core"
+	"github.com/Zisimopoulou/platform-go-challenge/internal/data"
+	"github.com/Zisimopoulou/platform-go-challenge/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStoreTrash(t *testing.T) {
+	for name, newStore := range storeImplementations() {
+		t.Run(name, func(t *testing.T) {
+			store := newStore(t)
+			a, err := store.Add("alice", taggedAsset("a", "finance"))
+			require.NoError(t, err)
+			b, err := store.Add("alice", insightAsset("b"))
+			require.NoError(t, err)
+			_, err = store.Add("alice", insightAsset("c"))
+			require.NoError(t, err)
+			_, err = store.PutShare(models.Share{OwnerID: "alice", FavoriteID: a, UserID: "bob", Permission: models.PermissionRead})
+			require.NoError(t, err)
+			work, err := store.CreateCollection("alice", "work")
+			require.NoError(t, err)
+			require.NoError(t, store.AddToCollection("alice", work.ID, a))
+
+			require.NoError(t, store.Delete("alice", a, data.AnyVersion))
+			require.NoError(t, store.Delete("alice", b, data.AnyVersion))
+
+			_, err = store.Get("alice", a)
+			assert.ErrorIs(t, err, data.ErrNotFound)
+			assert.ErrorIs(t, store.Delete("alice", a, data.AnyVersion), data.ErrNotFound)
+			favs, total, err := store.List("alice", data.ListQuery{Limit: 10})
+			require.NoError(t, err)
+			assert.Equal(t, 1, total)
+			assert.Equal(t, []string{"c"}, descriptionsOf(favs))
+			tags, err := store.Tags("alice")
+			require.NoError(t, err)
+			assert.Empty(t, tags)
+			shared, err := store.SharedWith("bob")
+			require.NoError(t, err)
+			assert.Empty(t, shared)
+			_, err = store.GetShare("alice", a, "bob")
+			assert.ErrorIs(t, err, data.ErrNotFound)
+			work, err = store.GetCollection("alice", work.ID)
+			require.NoError(t, err)
+			assert.Equal(t, 0, work.Favorites)
+
+			trashed, total, err := store.ListTrash("alice", 1, 0)
+			require.NoError(t, err)
+			assert.Equal(t, 2, total)
+			require.Len(t, trashed, 1)
+			assert.Equal(t, b, trashed[0].FavoriteID, "most recently deleted first")
+			require.NotNil(t, trashed[0].Asset.DeletedAt)
+			trashed, _, err = store.ListTrash("alice", 1, 1)
+			require.NoError(t, err)
+			require.Len(t, trashed, 1)
+			assert.Equal(t, a, trashed[0].FavoriteID)
+			trashed, total, err = store.ListTrash("bob", 10, 0)
+			require.NoError(t, err)
+			assert.Equal(t, 0, total)
+			assert.Empty(t, trashed)
+
+			restored, err := store.Restore("alice", a)
+			require.NoError(t, err)
+			assert.Nil(t, restored.DeletedAt)
+			assert.Equal(t, int64(3), restored.Version)
+			_, err = store.Restore("alice", a)
+			assert.ErrorIs(t, err, data.ErrNotFound)
+			_, err = store.Restore("bob", b)
+			assert.ErrorIs(t, err, data.ErrNotFound)
+
+			got, err := store.Get("alice", a)
+			require.NoError(t, err)
+			assert.Equal(t, []string{"finance"}, got.Tags)
+			_, err = store.GetShare("alice", a, "bob")
+			assert.NoError(t, err, "shares come back with the favorite")
+			work, err = store.GetCollection("alice", work.ID)
+			require.NoError(t, err)
+			assert.Equal(t, 1, work.Favorites)
+
+			assert.ErrorIs(t, store.Purge("alice", a), data.ErrNotFound, "live favorites cannot be purged")
+			assert.ErrorIs(t, store.Purge("alice", "missing"), data.ErrNotFound)
+			require.NoError(t, store.Delete("alice", a, data.AnyVersion))
+			require.NoError(t, store.Purge("alice", a))
+			_, err = store.Restore("alice", a)
+			assert.ErrorIs(t, err, data.ErrNotFound)
+			_, err = store.ListShares("alice", a)
+			assert.ErrorIs(t, err, data.ErrNotFound)
+
+			n, err := store.PurgeTrash(time.Now().Add(-time.Hour))
+			require.NoError(t, err)
+			assert.Equal(t, 0, n, "b was deleted too recently")
+			n, err = store.PurgeTrash(time.Now().Add(time.Second))
+			require.NoError(t, err)
+			assert.Equal(t, 1, n)
+			_, total, err = store.ListTrash("alice", 10, 0)
+			require.NoError(t, err)
+			assert.Equal(t, 0, total)
+		})
+	}
+}
+
+func TestJournalReplaysTrash(t *testing.T) {
+	dir := t.TempDir()
+	store := openJournaled(t, dir, 0)
+	a, err := store.Add("alice", insightAsset("a"))
+	require.NoError(t, err)
+	b, err := store.Add("alice", insightAsset("b"))
+	require.NoError(t, err)
+	c, err := store.Add("alice", insightAsset("c"))
+	require.NoError(t, err)
+	require.NoError(t, store.Delete("alice", a, data.AnyVersion))
+	require.NoError(t, store.Delete("alice", b, data.AnyVersion))
+	require.NoError(t, store.Delete("alice", c, data.AnyVersion))
+	_, err = store.Restore("alice", b)
+	require.NoError(t, err)
+	require.NoError(t, store.Purge("alice", c))
+
+	check := func(s data.Store) {
+		favs, _, err := s.List("alice", data.ListQuery{Limit: 10})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"b"}, descriptionsOf(favs))
+		trashed, total, err := s.ListTrash("alice", 10, 0)
+		require.NoError(t, err)
+		assert.Equal(t, 1, total)
+		assert.Equal(t, []string{"a"}, descriptionsOf(trashed))
+	}
+	fromJournal := openJournaled(t, dir, 0)
+	check(fromJournal)
+	require.NoError(t, fromJournal.Close())
+
+	fromSnapshot := openJournaled(t, dir, 0)
+	defer fromSnapshot.Close()
+	check(fromSnapshot)
+}
+
+func TestTrashEndpoints(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret-32-chars-long-for-testing-only")
+
+	svc := core.NewService(data.NewInMemoryStore())
+	mux := http.NewServeMux()
+	mux.Handle("/users/", http.StripPrefix("/users", api.NewHandler(svc)))
+	mountAuth(mux)
+	srv := httptest.NewServer(api.WithMiddleware(mux))
+	defer srv.Close()
+
+	token := loginAs(t, srv.URL, "alice").AccessToken
+	do := func(method, path, body string) *http.Response {
+		req, _ := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Content-Type", "application/json")
+		res, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		t.Cleanup(func() { res.Body.Close() })
+		return res
+	}
+	res := do(http.MethodPost, "/users/alice/favorites", `{"type":"insight","description":"doomed","payload":{"text":"t"}}`)
+	require.Equal(t, http.StatusCreated, res.StatusCode)
+	var created map[string]string
+	require.NoError(t, json.NewDecoder(res.Body).Decode(&created))
+	id := created["favoriteId"]
+
+	require.Equal(t, http.StatusNoContent, do(http.MethodDelete, "/users/alice/favorites/"+id, "").StatusCode)
+	assert.Equal(t, http.StatusNotFound, do(http.MethodGet, "/users/alice/favorites/"+id, "").StatusCode)
+
+	res = do(http.MethodGet, "/users/alice/trash", "")
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	var page models.PaginatedFavorites
+	require.NoError(t, json.NewDecoder(res.Body).Decode(&page))
+	assert.Equal(t, 1, page.TotalCount)
+	require.Len(t, page.Favorites, 1)
+	assert.NotNil(t, page.Favorites[0].Asset.DeletedAt)
+
+	res = do(http.MethodPost, "/users/alice/trash/"+id+"/restore", "")
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, `"3"`, res.Header.Get("ETag"))
+	assert.Equal(t, http.StatusNotFound, do(http.MethodPost, "/users/alice/trash/"+id+"/restore", "").StatusCode)
+	assert.Equal(t, http.StatusOK, do(http.MethodGet, "/users/alice/favorites/"+id, "").StatusCode)
+
+	assert.Equal(t, http.StatusNotFound, do(http.MethodDelete, "/users/alice/trash/"+id, "").StatusCode)
+	require.Equal(t, http.StatusNoContent, do(http.MethodDelete, "/users/alice/favorites/"+id, "").StatusCode)
+	assert.Equal(t, http.StatusNoContent, do(http.MethodDelete, "/users/alice/trash/"+id, "").StatusCode)
+	assert.Equal(t, http.StatusNotFound, do(http.MethodPost, "/users/alice/trash/"+id+"/restore", "").StatusCode)
+
+	n, err := svc.PurgeTrash(0)
+	require.NoError(t, err)
+	assert.Equal(t, 0, n)
+}